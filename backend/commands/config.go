@@ -0,0 +1,79 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"pocketvue/config"
+
+	"github.com/pocketbase/pocketbase"
+	"github.com/spf13/cobra"
+)
+
+// RegisterConfigCommand registers the "config" command and its "check" subcommand
+func RegisterConfigCommand(app *pocketbase.PocketBase) {
+	configCmd := &cobra.Command{
+		Use:   "config",
+		Short: "Inspect the application configuration",
+	}
+
+	configCmd.AddCommand(&cobra.Command{
+		Use:          "check",
+		Short:        "Print the effective configuration and validate it for the current environment",
+		SilenceUsage: true,
+		Run: func(cmd *cobra.Command, args []string) {
+			// PocketBase ignores command errors, so exit explicitly for scripts and CI
+			if err := checkConfig(); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+		},
+	})
+
+	app.RootCmd.AddCommand(configCmd)
+}
+
+// checkConfig prints every setting with its source, secrets redacted, and fails on invalid configuration
+// The app is already bootstrapped at this point, so the app_settings values are applied
+func checkConfig() error {
+	if path, explicit := config.ConfigFilePath(); explicit {
+		fmt.Printf("Config file: %s\n\n", path)
+	} else if _, err := os.Stat(path); err == nil {
+		fmt.Printf("Config file: %s\n\n", path)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "KEY\tVALUE\tSOURCE")
+	for _, value := range config.Effective() {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", value.Key, value.Redacted(), value.Source)
+	}
+	w.Flush()
+
+	fmt.Println()
+	for _, warning := range config.Warnings() {
+		fmt.Printf("Warning: %s\n", warning)
+	}
+
+	err := config.Validate()
+	if err == nil {
+		fmt.Printf("Configuration is valid for the %s environment\n", config.Current().AppEnv)
+		return nil
+	}
+
+	for _, e := range unwrapErrors(err) {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", e)
+	}
+
+	return fmt.Errorf("configuration is invalid for the %s environment", config.Current().AppEnv)
+}
+
+// unwrapErrors splits an errors.Join error into its parts
+func unwrapErrors(err error) []error {
+	var joined interface{ Unwrap() []error }
+	if errors.As(err, &joined) {
+		return joined.Unwrap()
+	}
+	return []error{err}
+}
@@ -3,10 +3,14 @@ package config
 import (
 	"fmt"
 	"log"
-	"os"
+	"strconv"
+	"sync/atomic"
+	"time"
 )
 
-var (
+// Config is a snapshot of the resolved configuration
+// A snapshot is never modified, every reload publishes a new one, read it through Current
+type Config struct {
 	// FrontendURL is the base URL for the frontend application
 	FrontendURL string
 
@@ -21,67 +25,147 @@ var (
 
 	// AppEnv is the application environment (development, production, etc.)
 	AppEnv string
-)
 
-// Init loads and validates configuration from environment variables
-func Init() error {
-	// Load FrontendURL with default for development
-	FrontendURL = getEnv("FRONTEND_URL", "http://localhost:3000")
-	if FrontendURL == "" {
-		log.Printf("Warning: FRONTEND_URL not set, using default: http://localhost:3000")
-		FrontendURL = "http://localhost:3000"
-	}
+	// SeatOverageMode controls what happens when a member is added beyond the purchased seats
+	// "block" rejects the member, "increase" raises the seat quantity in Polar
+	SeatOverageMode string
 
-	// Load Polar configuration
-	PolarAccessToken = os.Getenv("POLAR_ACCESS_TOKEN")
-	if PolarAccessToken == "" {
-		log.Printf("Warning: POLAR_ACCESS_TOKEN not set")
-	}
+	// FreeWorkspaceSeats is the seat limit for workspaces without a seat-based subscription (0 means unlimited)
+	FreeWorkspaceSeats int
 
-	PolarWebhookSecret = os.Getenv("POLAR_WEBHOOK_SECRET")
-	if PolarWebhookSecret == "" {
-		log.Printf("Warning: POLAR_WEBHOOK_SECRET not set")
-	}
+	// PolarCustomerDeletion controls what happens to the Polar customer of a deleted user
+	// "anonymize" keeps the customer with its personal data removed, "delete" deletes it
+	PolarCustomerDeletion string
+
+	// PolarTimeout limits how long a single Polar API call may take
+	PolarTimeout time.Duration
+
+	// PolarMaxRetries is the number of retries of idempotent Polar API calls that failed transiently
+	PolarMaxRetries int
+
+	// PolarBreakerThreshold is the number of consecutive Polar failures that opens the circuit breaker
+	PolarBreakerThreshold int
+
+	// PolarBreakerCooldown is how long Polar calls fail fast once the circuit breaker is open
+	PolarBreakerCooldown time.Duration
 
-	PolarEnvironment = getEnv("POLAR_ENVIRONMENT", "sandbox")
-	AppEnv = getEnv("APP_ENV", "development")
+	// WorkspaceInviteTTL is how long a workspace invite link stays valid
+	WorkspaceInviteTTL time.Duration
 
-	return nil
+	// WorkspaceInviteHourlyLimit is the number of invite emails a user may send per hour (0 means unlimited)
+	WorkspaceInviteHourlyLimit int
+
+	// WorkspaceSlugCooldown is how long a previous workspace slug stays reserved for its workspace
+	WorkspaceSlugCooldown time.Duration
+
+	// WorkspaceTransferTTL is how long a workspace ownership transfer link stays valid
+	WorkspaceTransferTTL time.Duration
+
+	// WorkspaceRetention is how long a deleted workspace can be restored before it is purged
+	WorkspaceRetention time.Duration
+
+	// AuditLogRetention is how long audit log entries are kept (0 keeps them forever)
+	AuditLogRetention time.Duration
+
+	// FreeLogoMaxDimension is the largest width or height of logos uploaded to workspaces without a subscription
+	FreeLogoMaxDimension int
 }
 
-// GetPolarServer returns the Polar server name based on environment configuration
-func GetPolarServer() string {
-	if PolarEnvironment == "production" || AppEnv == "production" {
-		return "production"
+// current holds the snapshot in effect, it is replaced by apply
+var current atomic.Pointer[Config]
+
+// Current returns the configuration snapshot in effect
+// Read several values from the same snapshot when they must be consistent with each other
+func Current() *Config {
+	if cfg := current.Load(); cfg != nil {
+		return cfg
 	}
-	return "sandbox"
+	return &Config{}
 }
 
-// ValidateRequired checks that required configuration values are set
-// Returns an error if any required values are missing
-func ValidateRequired() error {
-	required := map[string]string{
-		"POLAR_ACCESS_TOKEN": PolarAccessToken,
-	}
+// Polar customer deletion modes
+const (
+	PolarCustomerAnonymize = "anonymize"
+	PolarCustomerDelete    = "delete"
+)
+
+// Seat overage modes
+const (
+	SeatOverageBlock    = "block"
+	SeatOverageIncrease = "increase"
+)
+
+// Init loads the configuration from the defaults, the optional config file and environment variables
+// Values stored in the app_settings collection are applied later through SetSettings
+func Init() error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	values, err := loadFile(ConfigFilePath())
+	fileValues = values
+	fileErr = err
+	apply()
+
+	return err
+}
 
-	for name, value := range required {
-		if value == "" {
-			return fmt.Errorf("required environment variable %s is not set", name)
+// apply resolves every setting through the configuration layers and publishes a new snapshot
+// Invalid values fall back to their default and are reported by Validate
+// Callers must hold mu
+func apply() {
+	resolved = make(map[string]Value, len(Settings))
+	problems = nil
+
+	for _, setting := range Settings {
+		value := resolve(setting)
+		if validate, ok := validators[setting.Key]; ok && value.Value != "" {
+			if err := validate(value.Value); err != nil {
+				log.Printf("Warning: invalid %s from %s, using default %q: %v", setting.Key, value.Source, setting.Default, err)
+				problems = append(problems, fmt.Errorf("%s (from %s): %w", setting.Key, value.Source, err))
+				value = Value{Key: setting.Key, Value: setting.Default, Source: SourceDefault, Secret: setting.Secret}
+			}
 		}
+		resolved[setting.Key] = value
 	}
 
-	return nil
+	current.Store(&Config{
+		FrontendURL:                resolved["FRONTEND_URL"].Value,
+		PolarAccessToken:           resolved["POLAR_ACCESS_TOKEN"].Value,
+		PolarWebhookSecret:         resolved["POLAR_WEBHOOK_SECRET"].Value,
+		PolarEnvironment:           resolved["POLAR_ENVIRONMENT"].Value,
+		AppEnv:                     resolved["APP_ENV"].Value,
+		SeatOverageMode:            resolved["SEAT_OVERAGE_MODE"].Value,
+		FreeWorkspaceSeats:         resolvedInt("FREE_WORKSPACE_SEATS"),
+		PolarCustomerDeletion:      resolved["POLAR_CUSTOMER_DELETION"].Value,
+		PolarTimeout:               time.Duration(resolvedInt("POLAR_TIMEOUT_SECONDS")) * time.Second,
+		PolarMaxRetries:            resolvedInt("POLAR_MAX_RETRIES"),
+		PolarBreakerThreshold:      resolvedInt("POLAR_BREAKER_THRESHOLD"),
+		PolarBreakerCooldown:       time.Duration(resolvedInt("POLAR_BREAKER_COOLDOWN_SECONDS")) * time.Second,
+		WorkspaceInviteTTL:         time.Duration(resolvedInt("WORKSPACE_INVITE_TTL_HOURS")) * time.Hour,
+		WorkspaceInviteHourlyLimit: resolvedInt("WORKSPACE_INVITE_HOURLY_LIMIT"),
+		WorkspaceSlugCooldown:      time.Duration(resolvedInt("WORKSPACE_SLUG_COOLDOWN_DAYS")) * 24 * time.Hour,
+		WorkspaceTransferTTL:       time.Duration(resolvedInt("WORKSPACE_TRANSFER_TTL_HOURS")) * time.Hour,
+		WorkspaceRetention:         time.Duration(resolvedInt("WORKSPACE_RETENTION_DAYS")) * 24 * time.Hour,
+		AuditLogRetention:          time.Duration(resolvedInt("AUDIT_LOG_RETENTION_DAYS")) * 24 * time.Hour,
+		FreeLogoMaxDimension:       resolvedInt("FREE_LOGO_MAX_DIMENSION"),
+	})
 }
 
-// getEnv retrieves an environment variable or returns a default value
-func getEnv(key, defaultValue string) string {
-	value := os.Getenv(key)
-	if value == "" {
-		return defaultValue
-	}
+// resolvedInt returns a resolved integer setting, values are validated before they are assigned
+func resolvedInt(key string) int {
+	value, _ := strconv.Atoi(resolved[key].Value)
 	return value
 }
 
+// PolarServer returns the Polar server name based on environment configuration
+func (c *Config) PolarServer() string {
+	if c.PolarEnvironment == "production" || c.AppEnv == "production" {
+		return "production"
+	}
+	return "sandbox"
+}
 
-
-
+// GetPolarServer returns the Polar server name of the current configuration
+func GetPolarServer() string {
+	return Current().PolarServer()
+}
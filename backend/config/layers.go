@@ -0,0 +1,233 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Configuration sources from lowest to highest precedence
+const (
+	SourceDefault  = "default"
+	SourceFile     = "file"
+	SourceEnv      = "env"
+	SourceSettings = "settings"
+)
+
+// defaultConfigFile is read when CONFIG_FILE is not set, it is optional
+const defaultConfigFile = "pocketvue.json"
+
+// Setting describes a configuration key
+type Setting struct {
+	Key     string
+	Default string
+	Secret  bool
+}
+
+// Settings lists every configuration key in display order
+var Settings = []Setting{
+	{Key: "APP_ENV", Default: "development"},
+	{Key: "FRONTEND_URL", Default: "http://localhost:3000"},
+	{Key: "POLAR_ENVIRONMENT", Default: "sandbox"},
+	{Key: "POLAR_ACCESS_TOKEN", Secret: true},
+	{Key: "POLAR_WEBHOOK_SECRET", Secret: true},
+	{Key: "POLAR_CUSTOMER_DELETION", Default: PolarCustomerAnonymize},
+	{Key: "POLAR_TIMEOUT_SECONDS", Default: "10"},
+	{Key: "POLAR_MAX_RETRIES", Default: "2"},
+	{Key: "POLAR_BREAKER_THRESHOLD", Default: "5"},
+	{Key: "POLAR_BREAKER_COOLDOWN_SECONDS", Default: "30"},
+	{Key: "SEAT_OVERAGE_MODE", Default: SeatOverageBlock},
+	{Key: "FREE_WORKSPACE_SEATS", Default: "0"},
+	{Key: "WORKSPACE_INVITE_TTL_HOURS", Default: "168"},
+	{Key: "WORKSPACE_INVITE_HOURLY_LIMIT", Default: "20"},
+	{Key: "WORKSPACE_SLUG_COOLDOWN_DAYS", Default: "90"},
+	{Key: "WORKSPACE_TRANSFER_TTL_HOURS", Default: "72"},
+	{Key: "WORKSPACE_RETENTION_DAYS", Default: "30"},
+	{Key: "AUDIT_LOG_RETENTION_DAYS", Default: "365"},
+	{Key: "FREE_LOGO_MAX_DIMENSION", Default: "1024"},
+}
+
+// validators check the values of typed settings, other settings accept any string
+var validators = map[string]func(string) error{
+	"FRONTEND_URL":                   validateURL,
+	"POLAR_CUSTOMER_DELETION":        oneOf(PolarCustomerAnonymize, PolarCustomerDelete),
+	"POLAR_TIMEOUT_SECONDS":          nonNegativeInt,
+	"POLAR_MAX_RETRIES":              nonNegativeInt,
+	"POLAR_BREAKER_THRESHOLD":        nonNegativeInt,
+	"POLAR_BREAKER_COOLDOWN_SECONDS": nonNegativeInt,
+	"SEAT_OVERAGE_MODE":              oneOf(SeatOverageBlock, SeatOverageIncrease),
+	"FREE_WORKSPACE_SEATS":           nonNegativeInt,
+	"WORKSPACE_INVITE_TTL_HOURS":     positiveInt,
+	"WORKSPACE_INVITE_HOURLY_LIMIT":  nonNegativeInt,
+	"WORKSPACE_SLUG_COOLDOWN_DAYS":   nonNegativeInt,
+	"WORKSPACE_TRANSFER_TTL_HOURS":   positiveInt,
+	"WORKSPACE_RETENTION_DAYS":       nonNegativeInt,
+	"AUDIT_LOG_RETENTION_DAYS":       nonNegativeInt,
+	"FREE_LOGO_MAX_DIMENSION":        positiveInt,
+}
+
+// Value is the effective value of a setting and the layer it comes from
+type Value struct {
+	Key    string
+	Value  string
+	Source string
+	Secret bool
+}
+
+// Redacted returns the value safe for display, secrets only reveal whether they are set
+func (v Value) Redacted() string {
+	if v.Value == "" {
+		return "(not set)"
+	}
+	if v.Secret {
+		return "********"
+	}
+	return v.Value
+}
+
+var (
+	mu             sync.Mutex
+	fileValues     map[string]string
+	settingsValues map[string]string
+	resolved       map[string]Value
+	problems       []error
+	fileErr        error
+)
+
+// SetSettings applies the values stored in the app_settings collection on top of the other layers
+func SetSettings(values map[string]string) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	settingsValues = values
+	apply()
+}
+
+// Effective returns the effective value of every setting in display order
+func Effective() []Value {
+	mu.Lock()
+	defer mu.Unlock()
+
+	values := make([]Value, 0, len(Settings))
+	for _, setting := range Settings {
+		values = append(values, resolved[setting.Key])
+	}
+	return values
+}
+
+// ValidateValue checks a single setting value, it is used to validate records of the app_settings collection
+func ValidateValue(key, value string) error {
+	if !isKnownSetting(key) {
+		return fmt.Errorf("unknown setting %s", key)
+	}
+	if validate, ok := validators[key]; ok && value != "" {
+		return validate(value)
+	}
+	return nil
+}
+
+// ConfigFilePath returns the path of the config file and whether it was set explicitly
+func ConfigFilePath() (string, bool) {
+	if path := os.Getenv("CONFIG_FILE"); path != "" {
+		return path, true
+	}
+	return defaultConfigFile, false
+}
+
+// resolve returns the value of a setting from the highest layer that sets it
+func resolve(setting Setting) Value {
+	value := Value{Key: setting.Key, Value: setting.Default, Source: SourceDefault, Secret: setting.Secret}
+
+	if v := fileValues[setting.Key]; v != "" {
+		value.Value, value.Source = v, SourceFile
+	}
+	if v := os.Getenv(setting.Key); v != "" {
+		value.Value, value.Source = v, SourceEnv
+	}
+	if v := settingsValues[setting.Key]; v != "" {
+		value.Value, value.Source = v, SourceSettings
+	}
+
+	return value
+}
+
+// loadFile reads a JSON object of settings keyed like the environment variables
+// A missing file is only an error when its path was set explicitly through CONFIG_FILE
+func loadFile(path string, explicit bool) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) && !explicit {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read config file %s: %w", path, err)
+	}
+
+	var raw map[string]any
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	values := make(map[string]string, len(raw))
+	for key, value := range raw {
+		if !isKnownSetting(key) {
+			return nil, fmt.Errorf("unknown setting %s in config file %s", key, path)
+		}
+		switch v := value.(type) {
+		case string:
+			values[key] = v
+		case float64:
+			values[key] = strconv.FormatFloat(v, 'f', -1, 64)
+		case bool:
+			values[key] = strconv.FormatBool(v)
+		case nil:
+		default:
+			return nil, fmt.Errorf("setting %s in config file %s must be a string, number or boolean", key, path)
+		}
+	}
+
+	return values, nil
+}
+
+// isKnownSetting reports whether the key is listed in Settings
+func isKnownSetting(key string) bool {
+	for _, setting := range Settings {
+		if setting.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// oneOf returns a validator accepting only the given values
+func oneOf(allowed ...string) func(string) error {
+	return func(value string) error {
+		for _, a := range allowed {
+			if value == a {
+				return nil
+			}
+		}
+		return fmt.Errorf("must be one of %s, got %q", strings.Join(allowed, ", "), value)
+	}
+}
+
+// nonNegativeInt accepts integers greater than or equal to zero
+func nonNegativeInt(value string) error {
+	parsed, err := strconv.Atoi(value)
+	if err != nil || parsed < 0 {
+		return fmt.Errorf("must be a non-negative integer, got %q", value)
+	}
+	return nil
+}
+
+// positiveInt accepts integers greater than zero
+func positiveInt(value string) error {
+	parsed, err := strconv.Atoi(value)
+	if err != nil || parsed < 1 {
+		return fmt.Errorf("must be a positive integer, got %q", value)
+	}
+	return nil
+}
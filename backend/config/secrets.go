@@ -0,0 +1,85 @@
+package config
+
+import (
+	"crypto/hkdf"
+	"crypto/sha256"
+	"errors"
+	"os"
+	"strings"
+
+	"github.com/pocketbase/pocketbase/tools/security"
+)
+
+// encryptedPrefix marks setting values that are encrypted at rest
+const encryptedPrefix = "enc:v1:"
+
+// settingsKeyInfo binds the derived key to its purpose so the master key can be reused for other keys
+const settingsKeyInfo = "pocketvue app_settings encryption"
+
+// ErrMissingMasterKey is returned when a secret setting is stored or read without SETTINGS_MASTER_KEY
+var ErrMissingMasterKey = errors.New("SETTINGS_MASTER_KEY is not set")
+
+// IsSecretSetting reports whether the value of a setting must be encrypted at rest
+func IsSecretSetting(key string) bool {
+	for _, setting := range Settings {
+		if setting.Key == key {
+			return setting.Secret
+		}
+	}
+	return false
+}
+
+// IsEncrypted reports whether a stored setting value is already encrypted
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, encryptedPrefix)
+}
+
+// EncryptSetting encrypts a secret setting value with a key derived from SETTINGS_MASTER_KEY
+func EncryptSetting(value string) (string, error) {
+	key, err := settingsKey()
+	if err != nil {
+		return "", err
+	}
+
+	cipherText, err := security.Encrypt([]byte(value), key)
+	if err != nil {
+		return "", err
+	}
+
+	return encryptedPrefix + cipherText, nil
+}
+
+// DecryptSetting decrypts a value produced by EncryptSetting, values without the prefix are returned as is
+func DecryptSetting(value string) (string, error) {
+	if !IsEncrypted(value) {
+		return value, nil
+	}
+
+	key, err := settingsKey()
+	if err != nil {
+		return "", err
+	}
+
+	plainText, err := security.Decrypt(strings.TrimPrefix(value, encryptedPrefix), key)
+	if err != nil {
+		return "", err
+	}
+
+	return string(plainText), nil
+}
+
+// settingsKey derives the 32 byte AES key of the app_settings secrets from the master key
+// The master key is only ever read from the environment so it is never stored next to the data it protects
+func settingsKey() (string, error) {
+	masterKey := os.Getenv("SETTINGS_MASTER_KEY")
+	if masterKey == "" {
+		return "", ErrMissingMasterKey
+	}
+
+	key, err := hkdf.Key(sha256.New, []byte(masterKey), nil, settingsKeyInfo, 32)
+	if err != nil {
+		return "", err
+	}
+
+	return string(key), nil
+}
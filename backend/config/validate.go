@@ -0,0 +1,70 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+)
+
+// IsProduction reports whether the app runs in the production environment
+func (c *Config) IsProduction() bool {
+	return c.AppEnv == "production"
+}
+
+// IsProduction reports whether the app runs in the production environment with the current configuration
+func IsProduction() bool {
+	return Current().IsProduction()
+}
+
+// Validate checks the effective configuration for the current environment
+// Production requires an HTTPS FRONTEND_URL, a Polar access token and a webhook secret
+func Validate() error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	errs := append([]error{}, problems...)
+	if fileErr != nil {
+		errs = append(errs, fileErr)
+	}
+
+	cfg := Current()
+	if cfg.IsProduction() {
+		if u, err := url.Parse(cfg.FrontendURL); err == nil && u.Scheme != "https" {
+			errs = append(errs, fmt.Errorf("FRONTEND_URL must use https in production, got %q", cfg.FrontendURL))
+		}
+		if cfg.PolarAccessToken == "" {
+			errs = append(errs, errors.New("POLAR_ACCESS_TOKEN is required in production"))
+		}
+		if cfg.PolarWebhookSecret == "" {
+			errs = append(errs, errors.New("POLAR_WEBHOOK_SECRET is required in production"))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Warnings returns configuration issues that are tolerated outside production
+func Warnings() []string {
+	cfg := Current()
+	if cfg.IsProduction() {
+		return nil
+	}
+
+	var warnings []string
+	if cfg.PolarAccessToken == "" {
+		warnings = append(warnings, "POLAR_ACCESS_TOKEN is not set, payments are disabled")
+	}
+	if cfg.PolarWebhookSecret == "" {
+		warnings = append(warnings, "POLAR_WEBHOOK_SECRET is not set, Polar webhooks will be rejected")
+	}
+	return warnings
+}
+
+// validateURL accepts absolute http and https URLs
+func validateURL(value string) error {
+	u, err := url.Parse(value)
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return fmt.Errorf("must be an absolute http or https URL, got %q", value)
+	}
+	return nil
+}
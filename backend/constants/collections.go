@@ -2,7 +2,23 @@ package constants
 
 // Database collection names
 const (
-	CollectionWorkspaces    = "workspaces"
-	CollectionUsers         = "users"
-	CollectionPolarProducts = "polar_products"
+	CollectionWorkspaces           = "workspaces"
+	CollectionUsers                = "users"
+	CollectionNotes                = "notes"
+	CollectionPolarProducts        = "polar_products"
+	CollectionOrders               = "orders"
+	CollectionWorkspaceMembers     = "workspace_members"
+	CollectionSubscriptions        = "subscriptions"
+	CollectionCheckouts            = "checkouts"
+	CollectionPolarSyncJobs        = "polar_sync_jobs"
+	CollectionAppSettings          = "app_settings"
+	CollectionWorkspaceInvites     = "workspace_invites"
+	CollectionWorkspaceSlugHistory = "workspace_slug_history"
+	CollectionWorkspaceTransfers   = "workspace_transfers"
+	CollectionAuditLogs            = "audit_logs"
+	CollectionWorkspaceAPIKeys     = "workspace_api_keys"
+	CollectionWorkspaceWebhooks    = "workspace_webhooks"
+	CollectionWebhookDeliveries    = "webhook_deliveries"
+	CollectionWorkspaceTemplates   = "workspace_templates"
+	CollectionWorkspaceCloneJobs   = "workspace_clone_jobs"
 )
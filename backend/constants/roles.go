@@ -0,0 +1,9 @@
+package constants
+
+// Workspace member roles stored in the workspace_members.role field
+const (
+	WorkspaceRoleOwner  = "owner"
+	WorkspaceRoleAdmin  = "admin"
+	WorkspaceRoleMember = "member"
+	WorkspaceRoleViewer = "viewer"
+)
@@ -0,0 +1,33 @@
+package constants
+
+// Workspace slug constraints, slugs are lowercase words of letters and digits joined by single hyphens
+const (
+	WorkspaceSlugMinLength = 3
+	WorkspaceSlugMaxLength = 48
+	WorkspaceSlugPattern   = `^[a-z0-9]+(?:-[a-z0-9]+)*$`
+)
+
+// ReservedWorkspaceSlugs cannot be used as workspace slugs because they collide with
+// frontend routes and system subdomains or are offensive
+// Keep in sync with frontend/app/constants/blockedSlugs.ts
+var ReservedWorkspaceSlugs = []string{
+	// App routes
+	"new-workspace", "register", "login", "logout", "invite",
+
+	// App/System reserved
+	"admin", "super-admin", "superadmin", "api", "app", "www", "mail", "email", "my",
+	"support", "help", "blog", "docs", "status", "cdn", "assets", "static", "files",
+	"dashboard", "settings", "account", "billing", "pricing", "about", "contact", "terms",
+	"privacy", "security", "legal", "careers", "jobs", "press", "media", "news", "public",
+	"private", "internal", "system", "root", "config", "setup", "install", "upgrade",
+	"maintenance", "test", "testing", "staging", "dev", "development", "prod", "production",
+	"demo", "sandbox", "preview",
+
+	// Profanity words
+	"fuck", "shit", "bitch", "ass", "asshole", "bastard", "damn", "hell", "crap", "piss",
+	"cock", "dick", "pussy", "cunt", "tits", "boobs", "whore", "slut", "fag", "faggot",
+	"nigger", "nigga", "retard", "gay", "lesbian", "homo", "dyke", "tranny", "nazi",
+	"hitler", "terrorist", "bomb", "kill", "murder", "rape", "sex", "porn", "xxx", "nude",
+	"naked", "fetish", "kinky", "drugs", "weed", "cocaine", "heroin", "meth", "crack",
+	"alcohol", "beer", "wine", "vodka", "whiskey",
+}
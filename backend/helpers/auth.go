@@ -4,9 +4,18 @@ import (
 	"github.com/pocketbase/pocketbase/core"
 )
 
+// APIKeyUserRequestKey is the request store key of the user a workspace API key acts for
+// It is only set on routes that accept API keys
+const APIKeyUserRequestKey = "apiKeyUser"
+
 // GetAuthenticatedUser extracts and validates the authorization token from request headers
+// On routes that accept API keys, the user a valid key acts for is returned instead
 // Returns the authenticated user record or throws an error
 func GetAuthenticatedUser(e *core.RequestEvent) (*core.Record, error) {
+	if user, ok := e.Get(APIKeyUserRequestKey).(*core.Record); ok {
+		return user, nil
+	}
+
 	// Extract and validate auth token
 	authHeader := e.Request.Header.Get("Authorization")
 	if authHeader == "" {
@@ -21,3 +30,18 @@ func GetAuthenticatedUser(e *core.RequestEvent) (*core.Record, error) {
 
 	return user, nil
 }
+
+// GetAuthenticatedSuperuser extracts the authorization token and ensures it belongs to a superuser
+// Returns the authenticated superuser record or throws an error
+func GetAuthenticatedSuperuser(e *core.RequestEvent) (*core.Record, error) {
+	authRecord, err := GetAuthenticatedUser(e)
+	if err != nil {
+		return nil, err
+	}
+
+	if !authRecord.IsSuperuser() {
+		return nil, e.ForbiddenError("Only superusers can access this resource", nil)
+	}
+
+	return authRecord, nil
+}
@@ -1,8 +1,12 @@
 package helpers
 
 import (
+	"database/sql"
+	"errors"
 	"fmt"
+	"pocketvue/constants"
 
+	"github.com/pocketbase/dbx"
 	"github.com/pocketbase/pocketbase/core"
 )
 
@@ -59,3 +63,54 @@ func FindAllRecords(app core.App, collectionName string) ([]*core.Record, error)
 	}
 	return records, nil
 }
+
+// FindWorkspaceBySlug finds a workspace by its slug and returns a descriptive error
+// Slugs a workspace used before a rename keep resolving to it, see ResolveWorkspaceSlug
+func FindWorkspaceBySlug(app core.App, slug string) (*core.Record, error) {
+	record, _, err := ResolveWorkspaceSlug(app, slug)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find workspace with slug %s: %w", slug, err)
+	}
+	return record, nil
+}
+
+// ResolveWorkspaceSlug finds the workspace using a slug, or the workspace that used it most recently
+// The returned bool is true when the slug is a previous slug of the workspace
+// Returns sql.ErrNoRows if no workspace uses or used the slug, or if that workspace is deleted
+func ResolveWorkspaceSlug(app core.App, slug string) (*core.Record, bool, error) {
+	record, err := app.FindFirstRecordByFilter(
+		constants.CollectionWorkspaces,
+		"slug = {:slug} && deleted_at = ''",
+		dbx.Params{"slug": slug},
+	)
+	if err == nil {
+		return record, false, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, false, err
+	}
+
+	history, err := app.FindRecordsByFilter(
+		constants.CollectionWorkspaceSlugHistory,
+		"slug = {:slug}",
+		"-created",
+		1,
+		0,
+		dbx.Params{"slug": slug},
+	)
+	if err != nil {
+		return nil, false, err
+	}
+	if len(history) == 0 {
+		return nil, false, sql.ErrNoRows
+	}
+
+	record, err = app.FindRecordById(constants.CollectionWorkspaces, history[0].GetString("workspace"))
+	if err != nil {
+		return nil, false, err
+	}
+	if IsWorkspaceDeleted(record) {
+		return nil, false, sql.ErrNoRows
+	}
+	return record, true, nil
+}
@@ -0,0 +1,68 @@
+package helpers
+
+import (
+	"strconv"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// Pagination is the page requested through the page and perPage query parameters
+type Pagination struct {
+	Page    int
+	PerPage int
+}
+
+// Offset returns the number of items before the requested page
+func (p Pagination) Offset() int {
+	return (p.Page - 1) * p.PerPage
+}
+
+// ListResult is a page of items, shaped like the list responses of the PocketBase records API
+type ListResult[T any] struct {
+	Page       int `json:"page"`
+	PerPage    int `json:"perPage"`
+	TotalItems int `json:"totalItems"`
+	TotalPages int `json:"totalPages"`
+	Items      []T `json:"items"`
+}
+
+// GetPagination reads the page and perPage query parameters
+// Missing or invalid values fall back to the first page and defaultPerPage, perPage is capped at maxPerPage
+func GetPagination(e *core.RequestEvent, defaultPerPage, maxPerPage int) Pagination {
+	query := e.Request.URL.Query()
+
+	page, err := strconv.Atoi(query.Get("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	perPage, err := strconv.Atoi(query.Get("perPage"))
+	if err != nil || perPage < 1 {
+		perPage = defaultPerPage
+	}
+	if perPage > maxPerPage {
+		perPage = maxPerPage
+	}
+
+	return Pagination{Page: page, PerPage: perPage}
+}
+
+// NewListResult builds the page of a list from its items and the total number of items
+func NewListResult[T any](pagination Pagination, items []T, totalItems int) ListResult[T] {
+	if items == nil {
+		items = []T{}
+	}
+
+	totalPages := 0
+	if totalItems > 0 {
+		totalPages = (totalItems + pagination.PerPage - 1) / pagination.PerPage
+	}
+
+	return ListResult[T]{
+		Page:       pagination.Page,
+		PerPage:    pagination.PerPage,
+		TotalItems: totalItems,
+		TotalPages: totalPages,
+		Items:      items,
+	}
+}
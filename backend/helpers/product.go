@@ -0,0 +1,31 @@
+package helpers
+
+import (
+	"encoding/json"
+	"fmt"
+	"pocketvue/types"
+	"strings"
+)
+
+// ParseProductFeatures decodes and validates the features JSON of a product
+// An empty value returns an empty slice, every feature must have a label
+func ParseProductFeatures(raw []byte) ([]types.ProductFeature, error) {
+	features := []types.ProductFeature{}
+
+	trimmed := strings.TrimSpace(string(raw))
+	if trimmed == "" || trimmed == "null" {
+		return features, nil
+	}
+
+	if err := json.Unmarshal(raw, &features); err != nil {
+		return nil, fmt.Errorf("features must be a JSON array of {\"icon\", \"label\"} objects: %w", err)
+	}
+
+	for i, feature := range features {
+		if strings.TrimSpace(feature.Label) == "" {
+			return nil, fmt.Errorf("feature %d is missing a label", i)
+		}
+	}
+
+	return features, nil
+}
@@ -8,7 +8,7 @@ import (
 
 // BuildFrontendURL constructs a frontend URL with the given path
 func BuildFrontendURL(path string) string {
-	baseURL := config.FrontendURL
+	baseURL := config.Current().FrontendURL
 	// Ensure baseURL doesn't end with /
 	if len(baseURL) > 0 && baseURL[len(baseURL)-1] == '/' {
 		baseURL = baseURL[:len(baseURL)-1]
@@ -29,15 +29,19 @@ func BuildWorkspaceURL(workspaceSlug, path string) string {
 	return BuildFrontendURL("/" + workspaceSlug + path)
 }
 
+// checkoutIDPlaceholder is replaced by Polar with the checkout ID when redirecting to the success URL
+const checkoutIDPlaceholder = "{CHECKOUT_ID}"
+
 // BuildCheckoutSuccessURL constructs a checkout success URL
+// The URL carries the checkout ID so the success page can poll GET /api/checkout/{id}/status
 func BuildCheckoutSuccessURL(workspaceSlug, returnPath string) string {
 	if workspaceSlug != "" {
 		if returnPath == "" {
 			returnPath = "/dashboard"
 		}
-		return BuildWorkspaceURL(workspaceSlug, returnPath+"?checkout=success")
+		return BuildWorkspaceURL(workspaceSlug, returnPath+"?checkout=success&checkout_id="+checkoutIDPlaceholder)
 	}
-	return BuildFrontendURL("/checkout/success")
+	return BuildFrontendURL("/checkout/success?checkout_id=" + checkoutIDPlaceholder)
 }
 
 // BuildCheckoutReturnURL constructs a checkout return URL
@@ -65,14 +69,15 @@ func BuildCustomerPortalReturnURL(workspaceSlug, returnPath string) string {
 // ValidateFrontendURL checks if FrontendURL is properly configured
 // Returns an error if FrontendURL is empty or is the default localhost value in production
 func ValidateFrontendURL() error {
-	if config.FrontendURL == "" {
+	cfg := config.Current()
+	if cfg.FrontendURL == "" {
 		return errors.New("FRONTEND_URL environment variable is not set")
 	}
 
 	// In production, don't allow the default localhost URL
-	if config.AppEnv == "production" || config.PolarEnvironment == "production" {
+	if cfg.AppEnv == "production" || cfg.PolarEnvironment == "production" {
 		defaultURL := "http://localhost:3000"
-		if strings.TrimSpace(config.FrontendURL) == defaultURL {
+		if strings.TrimSpace(cfg.FrontendURL) == defaultURL {
 			return errors.New("FRONTEND_URL must be set to a production URL (cannot use default localhost:3000)")
 		}
 	}
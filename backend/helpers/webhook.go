@@ -44,7 +44,7 @@ func ExtractWebhookHeaders(headers http.Header) WebhookHeaders {
 
 // VerifyWebhookSignature verifies the webhook signature using Standard Webhooks
 func VerifyWebhookSignature(payload []byte, headers http.Header) error {
-	secret := config.PolarWebhookSecret
+	secret := config.Current().PolarWebhookSecret
 	if secret == "" {
 		return fmt.Errorf("POLAR_WEBHOOK_SECRET not configured")
 	}
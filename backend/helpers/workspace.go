@@ -0,0 +1,60 @@
+package helpers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"pocketvue/constants"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// workspaceRoleRanks orders workspace roles from least to most privileged
+var workspaceRoleRanks = map[string]int{
+	constants.WorkspaceRoleViewer: 1,
+	constants.WorkspaceRoleMember: 2,
+	constants.WorkspaceRoleAdmin:  3,
+	constants.WorkspaceRoleOwner:  4,
+}
+
+// FindWorkspaceMember finds the membership of a user in a workspace
+// Returns nil without an error if the user is not a member
+func FindWorkspaceMember(app core.App, workspaceID, userID string) (*core.Record, error) {
+	member, err := app.FindFirstRecordByFilter(
+		constants.CollectionWorkspaceMembers,
+		"workspace = {:workspace} && user = {:user}",
+		dbx.Params{"workspace": workspaceID, "user": userID},
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to find membership of user %s in workspace %s: %w", userID, workspaceID, err)
+	}
+	return member, nil
+}
+
+// GetWorkspaceRole returns the role of a user in a workspace, or an empty string if the user is not a member
+func GetWorkspaceRole(app core.App, workspaceID, userID string) (string, error) {
+	member, err := FindWorkspaceMember(app, workspaceID, userID)
+	if err != nil || member == nil {
+		return "", err
+	}
+	return member.GetString("role"), nil
+}
+
+// HasWorkspaceRole reports whether a user has at least the given role in a workspace
+// For example HasWorkspaceRole(app, id, userID, constants.WorkspaceRoleAdmin) accepts admins and owners
+func HasWorkspaceRole(app core.App, workspaceID, userID, minRole string) bool {
+	role, err := GetWorkspaceRole(app, workspaceID, userID)
+	if err != nil || role == "" {
+		return false
+	}
+	return workspaceRoleRanks[role] >= workspaceRoleRanks[minRole]
+}
+
+// IsWorkspaceDeleted reports whether a workspace is soft deleted and waiting to be purged
+func IsWorkspaceDeleted(workspace *core.Record) bool {
+	return !workspace.GetDateTime("deleted_at").IsZero()
+}
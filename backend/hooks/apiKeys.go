@@ -0,0 +1,26 @@
+package hooks
+
+import (
+	"pocketvue/constants"
+	"pocketvue/services"
+
+	"github.com/pocketbase/pocketbase"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// RegisterAPIKeyHooks keeps requests authenticated with a workspace API key
+// to the notes of the key's workspace
+func RegisterAPIKeyHooks(app *pocketbase.PocketBase) {
+	checkWorkspace := func(e *core.RecordRequestEvent) error {
+		key, ok := e.Get(services.APIKeyRequestKey).(*core.Record)
+		if ok && e.Record.GetString("workspace") != key.GetString("workspace") {
+			return e.NotFoundError("", nil)
+		}
+		return e.Next()
+	}
+
+	app.OnRecordViewRequest(constants.CollectionNotes).BindFunc(checkWorkspace)
+	app.OnRecordCreateRequest(constants.CollectionNotes).BindFunc(checkWorkspace)
+	app.OnRecordUpdateRequest(constants.CollectionNotes).BindFunc(checkWorkspace)
+	app.OnRecordDeleteRequest(constants.CollectionNotes).BindFunc(checkWorkspace)
+}
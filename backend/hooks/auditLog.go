@@ -0,0 +1,70 @@
+package hooks
+
+import (
+	"log"
+
+	"pocketvue/constants"
+	"pocketvue/services"
+
+	"github.com/pocketbase/pocketbase"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// RegisterAuditLogHooks writes changes of workspaces, notes, members and subscriptions to the audit log
+// and registers a cron job that prunes entries older than the retention period
+// It must be registered before other hooks that end requests of the audited collections early
+func RegisterAuditLogHooks(app *pocketbase.PocketBase) {
+	collections := services.AuditedCollections()
+
+	// Requests through the records API remember who is changing the record,
+	// custom routes save with services.AuditContext instead
+	trackRequest := func(e *core.RecordRequestEvent) error {
+		defer services.TrackAuditActor(e.RequestEvent, e.Record)()
+		return e.Next()
+	}
+	app.OnRecordCreateRequest(collections...).BindFunc(trackRequest)
+	app.OnRecordUpdateRequest(collections...).BindFunc(trackRequest)
+	app.OnRecordDeleteRequest(collections...).BindFunc(trackRequest)
+
+	// Entries are written in the transaction of the change so they are rolled back with it
+	app.OnRecordCreate(collections...).BindFunc(func(e *core.RecordEvent) error {
+		if err := e.Next(); err != nil {
+			return err
+		}
+		logAuditEntry(e, services.AuditEventCreate, nil)
+		return nil
+	})
+
+	app.OnRecordUpdate(collections...).BindFunc(func(e *core.RecordEvent) error {
+		if err := e.Next(); err != nil {
+			return err
+		}
+		logAuditEntry(e, services.AuditEventUpdate, e.Record.Original())
+		return nil
+	})
+
+	app.OnRecordDelete(collections...).BindFunc(func(e *core.RecordEvent) error {
+		if err := e.Next(); err != nil {
+			return err
+		}
+		// The audit log of a workspace is deleted with it
+		if e.Record.Collection().Name != constants.CollectionWorkspaces {
+			logAuditEntry(e, services.AuditEventDelete, nil)
+		}
+		return nil
+	})
+
+	auditService := services.NewAuditService(app)
+
+	app.Cron().MustAdd("pruneAuditLogs", "15 3 * * *", func() {
+		auditService.PruneExpired()
+	})
+}
+
+// logAuditEntry writes the audit entry of a record event
+// A failing entry is logged and does not undo the change
+func logAuditEntry(e *core.RecordEvent, event string, original *core.Record) {
+	if err := services.NewAuditService(e.App).Log(e.Context, e.Record, event, original); err != nil {
+		log.Printf("Error writing audit log for %s %s: %v", e.Record.Collection().Name, e.Record.Id, err)
+	}
+}
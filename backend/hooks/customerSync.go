@@ -0,0 +1,44 @@
+package hooks
+
+import (
+	"log"
+
+	"pocketvue/services"
+
+	"github.com/pocketbase/pocketbase"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// RegisterCustomerSyncHooks registers hooks that propagate user profile changes and deletions to Polar
+func RegisterCustomerSyncHooks(app *pocketbase.PocketBase) {
+	syncService := services.NewPolarSyncService(app)
+
+	app.OnRecordAfterUpdateSuccess("users").BindFunc(func(e *core.RecordEvent) error {
+		original := e.Record.Original()
+		email := e.Record.GetString("email")
+		name := e.Record.GetString("name")
+
+		// Ignore saves that do not touch the profile (e.g. storing the Polar customer id)
+		if original.GetString("email") == email && original.GetString("name") == name {
+			return e.Next()
+		}
+
+		if err := syncService.Enqueue(services.SyncActionUpdateCustomer, e.Record.Id); err != nil {
+			log.Printf("Error queueing Polar profile update for user %s: %v", e.Record.Id, err)
+		}
+
+		return e.Next()
+	})
+
+	app.OnRecordAfterDeleteSuccess("users").BindFunc(func(e *core.RecordEvent) error {
+		if err := syncService.Enqueue(services.SyncActionDeleteCustomer, e.Record.Id); err != nil {
+			log.Printf("Error queueing Polar customer deletion for user %s: %v", e.Record.Id, err)
+		}
+
+		return e.Next()
+	})
+
+	app.Cron().MustAdd("processPolarSyncJobs", "* * * * *", func() {
+		syncService.ProcessPending()
+	})
+}
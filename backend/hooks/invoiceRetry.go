@@ -0,0 +1,16 @@
+package hooks
+
+import (
+	"pocketvue/services"
+
+	"github.com/pocketbase/pocketbase"
+)
+
+// RegisterInvoiceRetryJob registers a cron job that retries invoices still being generated by Polar
+func RegisterInvoiceRetryJob(app *pocketbase.PocketBase) {
+	invoiceService := services.NewInvoiceService(app)
+
+	app.Cron().MustAdd("retryPendingInvoices", "*/5 * * * *", func() {
+		invoiceService.RetryPendingInvoices()
+	})
+}
@@ -0,0 +1,23 @@
+package hooks
+
+import (
+	"pocketvue/constants"
+	"pocketvue/helpers"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/pocketbase/pocketbase"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// RegisterProductHooks registers a hook that validates the features JSON of products edited in the dashboard
+func RegisterProductHooks(app *pocketbase.PocketBase) {
+	app.OnRecordValidate(constants.CollectionPolarProducts).BindFunc(func(e *core.RecordEvent) error {
+		if _, err := helpers.ParseProductFeatures([]byte(e.Record.GetString("features"))); err != nil {
+			return validation.Errors{
+				"features": validation.NewError("validation_invalid_features", err.Error()),
+			}
+		}
+
+		return e.Next()
+	})
+}
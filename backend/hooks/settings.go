@@ -0,0 +1,103 @@
+package hooks
+
+import (
+	"fmt"
+	"log"
+
+	"pocketvue/config"
+	"pocketvue/constants"
+	"pocketvue/services"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/pocketbase/pocketbase"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// RegisterSettingsHooks registers hooks that layer the app_settings collection on top of the configuration
+// Settings are loaded on bootstrap and reloaded whenever a superuser edits them
+func RegisterSettingsHooks(app *pocketbase.PocketBase) {
+	settingsService := services.NewSettingsService(app)
+
+	reload := func() {
+		if err := settingsService.Reload(); err != nil {
+			log.Printf("Warning: failed to load app settings: %v", err)
+		}
+	}
+
+	app.OnBootstrap().BindFunc(func(e *core.BootstrapEvent) error {
+		if err := e.Next(); err != nil {
+			return err
+		}
+
+		reload()
+		return nil
+	})
+
+	// Refuse to serve a production app with an invalid configuration
+	app.OnServe().BindFunc(func(se *core.ServeEvent) error {
+		if err := config.Validate(); err != nil {
+			if config.IsProduction() {
+				return fmt.Errorf("invalid configuration: %w", err)
+			}
+			log.Printf("Warning: invalid configuration: %v", err)
+		}
+		for _, warning := range config.Warnings() {
+			log.Printf("Warning: %s", warning)
+		}
+
+		return se.Next()
+	})
+
+	app.OnRecordValidate(constants.CollectionAppSettings).BindFunc(func(e *core.RecordEvent) error {
+		if err := config.ValidateValue(e.Record.GetString("key"), e.Record.GetString("value")); err != nil {
+			return validation.Errors{
+				"value": validation.NewError("validation_invalid_setting", err.Error()),
+			}
+		}
+
+		return e.Next()
+	})
+
+	// Encrypt secrets before they are persisted, validation already ran on the plain value
+	encryptSecret := func(e *core.RecordEvent) error {
+		if err := encryptSettingValue(e.Record); err != nil {
+			return validation.Errors{
+				"value": validation.NewError("validation_setting_encryption", err.Error()),
+			}
+		}
+		return e.Next()
+	}
+	app.OnRecordCreate(constants.CollectionAppSettings).BindFunc(encryptSecret)
+	app.OnRecordUpdate(constants.CollectionAppSettings).BindFunc(encryptSecret)
+
+	app.OnRecordAfterCreateSuccess(constants.CollectionAppSettings).BindFunc(func(e *core.RecordEvent) error {
+		reload()
+		return e.Next()
+	})
+
+	app.OnRecordAfterUpdateSuccess(constants.CollectionAppSettings).BindFunc(func(e *core.RecordEvent) error {
+		reload()
+		return e.Next()
+	})
+
+	app.OnRecordAfterDeleteSuccess(constants.CollectionAppSettings).BindFunc(func(e *core.RecordEvent) error {
+		reload()
+		return e.Next()
+	})
+}
+
+// encryptSettingValue encrypts the value of a secret setting unless it is empty or already encrypted
+func encryptSettingValue(record *core.Record) error {
+	value := record.GetString("value")
+	if !config.IsSecretSetting(record.GetString("key")) || value == "" || config.IsEncrypted(value) {
+		return nil
+	}
+
+	encrypted, err := config.EncryptSetting(value)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt %s: %w", record.GetString("key"), err)
+	}
+
+	record.Set("value", encrypted)
+	return nil
+}
@@ -0,0 +1,21 @@
+package hooks
+
+import (
+	"pocketvue/services"
+
+	"github.com/pocketbase/pocketbase"
+)
+
+// RegisterWorkspaceCloneJobs registers cron jobs that resume interrupted workspace clones
+// and prune finished clone jobs
+func RegisterWorkspaceCloneJobs(app *pocketbase.PocketBase) {
+	cloneService := services.NewCloneService(app)
+
+	app.Cron().MustAdd("resumeStaleWorkspaceClones", "*/5 * * * *", func() {
+		cloneService.ProcessStale()
+	})
+
+	app.Cron().MustAdd("pruneWorkspaceCloneJobs", "50 3 * * *", func() {
+		cloneService.PruneJobs()
+	})
+}
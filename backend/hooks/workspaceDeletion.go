@@ -0,0 +1,40 @@
+package hooks
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"pocketvue/constants"
+	"pocketvue/services"
+
+	"github.com/pocketbase/pocketbase"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// RegisterWorkspaceDeletionHooks turns workspace delete requests into soft deletes
+// and registers a cron job that purges deleted workspaces after the retention window
+func RegisterWorkspaceDeletionHooks(app *pocketbase.PocketBase) {
+	app.OnRecordDeleteRequest(constants.CollectionWorkspaces).BindFunc(func(e *core.RecordRequestEvent) error {
+		// Superusers delete for good from the dashboard
+		if e.HasSuperuserAuth() {
+			return e.Next()
+		}
+
+		if err := services.NewWorkspaceDeletionService(e.App).SoftDelete(services.AuditContext(e.RequestEvent), e.Record, e.Auth); err != nil {
+			if errors.Is(err, services.ErrWorkspaceDeleted) {
+				return e.NotFoundError("", nil)
+			}
+			log.Printf("Error deleting workspace %s: %v", e.Record.Id, err)
+			return e.InternalServerError("Failed to delete the workspace.", nil)
+		}
+
+		return e.NoContent(http.StatusNoContent)
+	})
+
+	deletionService := services.NewWorkspaceDeletionService(app)
+
+	app.Cron().MustAdd("purgeDeletedWorkspaces", "30 * * * *", func() {
+		deletionService.PurgeExpired()
+	})
+}
@@ -0,0 +1,30 @@
+package hooks
+
+import (
+	"pocketvue/constants"
+	"pocketvue/services"
+
+	"github.com/pocketbase/pocketbase"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// RegisterWorkspaceDomainHooks registers hooks that keep the custom domain lookup cache
+// in sync with workspace changes
+func RegisterWorkspaceDomainHooks(app *pocketbase.PocketBase) {
+	app.OnRecordAfterUpdateSuccess(constants.CollectionWorkspaces).BindFunc(func(e *core.RecordEvent) error {
+		original := e.Record.Original()
+		if original.GetString("custom_domain") != e.Record.GetString("custom_domain") ||
+			original.GetBool("domain_verified") != e.Record.GetBool("domain_verified") ||
+			original.GetString("deleted_at") != e.Record.GetString("deleted_at") {
+			services.InvalidateDomainCache()
+		}
+		return e.Next()
+	})
+
+	app.OnRecordAfterDeleteSuccess(constants.CollectionWorkspaces).BindFunc(func(e *core.RecordEvent) error {
+		if e.Record.GetString("custom_domain") != "" {
+			services.InvalidateDomainCache()
+		}
+		return e.Next()
+	})
+}
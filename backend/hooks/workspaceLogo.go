@@ -0,0 +1,49 @@
+package hooks
+
+import (
+	"errors"
+
+	"pocketvue/constants"
+	"pocketvue/services"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/pocketbase/pocketbase"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// RegisterWorkspaceLogoHooks registers hooks that check and normalize uploaded workspace logos
+// before they are stored, for uploads through the API as well as imports
+func RegisterWorkspaceLogoHooks(app *pocketbase.PocketBase) {
+	processLogo := func(e *core.RecordEvent) error {
+		if err := processWorkspaceLogo(e); err != nil {
+			return err
+		}
+		return e.Next()
+	}
+
+	app.OnRecordCreate(constants.CollectionWorkspaces).BindFunc(processLogo)
+	app.OnRecordUpdate(constants.CollectionWorkspaces).BindFunc(processLogo)
+}
+
+// processWorkspaceLogo replaces a newly uploaded logo with its normalized version
+// Logos that cannot be used are reported as validation errors of the logo field
+func processWorkspaceLogo(e *core.RecordEvent) error {
+	files := e.Record.GetUnsavedFiles("logo")
+	if len(files) == 0 {
+		return nil
+	}
+
+	logo, err := services.NewLogoService(e.App).Process(e.Record, files[0])
+	if err != nil {
+		var logoErr *services.LogoError
+		if errors.As(err, &logoErr) {
+			return validation.Errors{
+				"logo": validation.NewError(logoErr.Code, logoErr.Message),
+			}
+		}
+		return err
+	}
+
+	e.Record.Set("logo", logo)
+	return nil
+}
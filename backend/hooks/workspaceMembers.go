@@ -0,0 +1,107 @@
+package hooks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"pocketvue/constants"
+	"pocketvue/services"
+
+	"github.com/pocketbase/pocketbase"
+	"github.com/pocketbase/pocketbase/apis"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// RegisterWorkspaceMemberHooks registers hooks that add workspace creators as owner members
+// and enforce workspace seat limits when members are added
+func RegisterWorkspaceMemberHooks(app *pocketbase.PocketBase) {
+	// The owner membership is created in the transaction of the workspace, a workspace is never left without owner
+	app.OnRecordCreateExecute(constants.CollectionWorkspaces).BindFunc(func(e *core.RecordEvent) error {
+		originalApp := e.App
+		err := e.App.RunInTransaction(func(txApp core.App) error {
+			e.App = txApp
+
+			if err := e.Next(); err != nil {
+				return err
+			}
+
+			if err := addWorkspaceOwner(services.InheritAuditActor(e.Context, e.Record), txApp, e.Record); err != nil {
+				return fmt.Errorf("failed to add owner member to workspace %s: %w", e.Record.Id, err)
+			}
+			return nil
+		})
+		e.App = originalApp
+
+		return err
+	})
+
+	app.OnRecordCreateExecute(constants.CollectionWorkspaceMembers).BindFunc(func(e *core.RecordEvent) error {
+		if services.SkipsSeatReservation(e.Context) {
+			return e.Next()
+		}
+
+		// The seats are counted in the transaction of the insert so concurrent invites cannot share the last seat
+		originalApp := e.App
+		err := e.App.RunInTransaction(func(txApp core.App) error {
+			e.App = txApp
+
+			workspace, err := txApp.FindRecordById(constants.CollectionWorkspaces, e.Record.GetString("workspace"))
+			if err != nil {
+				return apis.NewNotFoundError("Workspace not found.", err)
+			}
+
+			extraSeat, err := services.NewSeatService(txApp).CheckSeat(workspace)
+			if err != nil {
+				var limitErr *services.SeatLimitError
+				if errors.As(err, &limitErr) {
+					return apis.NewApiError(http.StatusPaymentRequired, "No seats available. Purchase more seats to add members.", map[string]any{
+						"used":  limitErr.Used,
+						"limit": limitErr.Limit,
+					})
+				}
+				return err
+			}
+
+			if err := e.Next(); err != nil {
+				return err
+			}
+
+			// The extra seat is billed by the Polar sync queue, which retries until Polar accepts it
+			if extraSeat {
+				_, err = services.NewPolarSyncService(txApp).Queue(services.SyncActionUpdateSeats, workspace.Id)
+				return err
+			}
+			return nil
+		})
+		e.App = originalApp
+
+		return err
+	})
+
+	app.OnRecordAfterCreateSuccess(constants.CollectionWorkspaceMembers).BindFunc(func(e *core.RecordEvent) error {
+		if !services.SkipsSeatReservation(e.Context) {
+			services.NewPolarSyncService(e.App).ProcessPendingFor(e.Record.GetString("workspace"))
+		}
+
+		return e.Next()
+	})
+}
+
+// addWorkspaceOwner creates the owner membership of a new workspace
+// It is saved with the context of the workspace so the audit log attributes both to the same actor,
+// and does not count against the seats
+func addWorkspaceOwner(ctx context.Context, app core.App, workspace *core.Record) error {
+	collection, err := app.FindCollectionByNameOrId(constants.CollectionWorkspaceMembers)
+	if err != nil {
+		return err
+	}
+
+	member := core.NewRecord(collection)
+	member.Set("workspace", workspace.Id)
+	member.Set("user", workspace.GetString("user"))
+	member.Set("role", constants.WorkspaceRoleOwner)
+
+	return app.SaveWithContext(services.WithoutSeatReservation(ctx), member)
+}
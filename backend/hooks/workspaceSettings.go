@@ -0,0 +1,42 @@
+package hooks
+
+import (
+	"errors"
+
+	"pocketvue/constants"
+	"pocketvue/services"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/pocketbase/pocketbase"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// RegisterWorkspaceSettingsHooks registers a hook that validates the settings JSON of workspaces on every save
+// and stores it migrated to the current version with the defaults of missing settings filled in
+func RegisterWorkspaceSettingsHooks(app *pocketbase.PocketBase) {
+	app.OnRecordValidate(constants.CollectionWorkspaces).BindFunc(func(e *core.RecordEvent) error {
+		raw := e.Record.GetString("settings")
+		if raw == "" || raw == "null" {
+			return e.Next()
+		}
+
+		settings, err := services.ParseWorkspaceSettings([]byte(raw))
+		if err != nil {
+			// Stored settings that no longer parse, e.g. saved by a newer release, do not block other changes
+			if !e.Record.IsNew() && raw == e.Record.Original().GetString("settings") {
+				return e.Next()
+			}
+
+			var errs validation.Errors
+			if errors.As(err, &errs) {
+				return validation.Errors{"settings": errs}
+			}
+			return validation.Errors{
+				"settings": validation.NewError("validation_invalid_settings", err.Error()),
+			}
+		}
+
+		e.Record.Set("settings", settings)
+		return e.Next()
+	})
+}
@@ -0,0 +1,71 @@
+package hooks
+
+import (
+	"log"
+
+	"pocketvue/constants"
+	"pocketvue/services"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/pocketbase/pocketbase"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// RegisterWorkspaceSlugHooks registers hooks that normalize workspace slugs, reject
+// malformed, reserved or already used slugs on create and update requests
+// and keep the previous slugs of renamed workspaces in the slug history
+func RegisterWorkspaceSlugHooks(app *pocketbase.PocketBase) {
+	app.OnRecordCreateRequest(constants.CollectionWorkspaces).BindFunc(func(e *core.RecordRequestEvent) error {
+		if err := validateWorkspaceSlug(e); err != nil {
+			return err
+		}
+		return e.Next()
+	})
+
+	app.OnRecordUpdateRequest(constants.CollectionWorkspaces).BindFunc(func(e *core.RecordRequestEvent) error {
+		// Existing slugs predating the current rules stay valid as long as they are not changed
+		if e.Record.GetString("slug") == e.Record.Original().GetString("slug") {
+			return e.Next()
+		}
+
+		if err := validateWorkspaceSlug(e); err != nil {
+			return err
+		}
+		return e.Next()
+	})
+
+	// A failed history write fails the save, which rolls back renames made through the API
+	app.OnRecordUpdate(constants.CollectionWorkspaces).BindFunc(func(e *core.RecordEvent) error {
+		previousSlug := e.Record.Original().GetString("slug")
+
+		if err := e.Next(); err != nil {
+			return err
+		}
+
+		newSlug := e.Record.GetString("slug")
+		if newSlug == previousSlug {
+			return nil
+		}
+
+		return services.NewSlugService(e.App).RecordRename(e.Record.Id, previousSlug, newSlug)
+	})
+}
+
+// validateWorkspaceSlug normalizes the slug of the request record and checks it
+func validateWorkspaceSlug(e *core.RecordRequestEvent) error {
+	slug := services.NormalizeSlug(e.Record.GetString("slug"))
+	e.Record.Set("slug", slug)
+
+	slugErr, err := services.NewSlugService(e.App).Check(slug, e.Record.Id)
+	if err != nil {
+		log.Printf("Error checking workspace slug %q: %v", slug, err)
+		return e.InternalServerError("Failed to validate the workspace slug.", nil)
+	}
+	if slugErr != nil {
+		return e.BadRequestError("Invalid workspace slug.", validation.Errors{
+			"slug": validation.NewError(slugErr.Code, slugErr.Message),
+		})
+	}
+
+	return nil
+}
@@ -0,0 +1,79 @@
+package hooks
+
+import (
+	"log"
+
+	"pocketvue/constants"
+	"pocketvue/helpers"
+	"pocketvue/services"
+
+	"github.com/pocketbase/pocketbase"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// RegisterWorkspaceWebhookHooks sends note, member and workspace changes to the webhooks of their workspace
+// and registers cron jobs that retry failed deliveries and prune the delivery log
+// Events are only sent once the change is committed
+func RegisterWorkspaceWebhookHooks(app *pocketbase.PocketBase) {
+	webhookService := services.NewWorkspaceWebhookService(app)
+
+	dispatch := func(record *core.Record, workspaceID, event string) {
+		if err := webhookService.Dispatch(workspaceID, event, record.PublicExport()); err != nil {
+			log.Printf("Error dispatching %s webhooks for %s %s: %v", event, record.Collection().Name, record.Id, err)
+		}
+	}
+
+	recordEvents := map[string][3]string{
+		constants.CollectionNotes: {
+			services.WebhookEventNoteCreated,
+			services.WebhookEventNoteUpdated,
+			services.WebhookEventNoteDeleted,
+		},
+		constants.CollectionWorkspaceMembers: {
+			services.WebhookEventMemberAdded,
+			services.WebhookEventMemberUpdated,
+			services.WebhookEventMemberRemoved,
+		},
+	}
+
+	for collection, events := range recordEvents {
+		app.OnRecordAfterCreateSuccess(collection).BindFunc(func(e *core.RecordEvent) error {
+			dispatch(e.Record, e.Record.GetString("workspace"), events[0])
+			return e.Next()
+		})
+
+		app.OnRecordAfterUpdateSuccess(collection).BindFunc(func(e *core.RecordEvent) error {
+			dispatch(e.Record, e.Record.GetString("workspace"), events[1])
+			return e.Next()
+		})
+
+		app.OnRecordAfterDeleteSuccess(collection).BindFunc(func(e *core.RecordEvent) error {
+			dispatch(e.Record, e.Record.GetString("workspace"), events[2])
+			return e.Next()
+		})
+	}
+
+	// Soft deleting and restoring a workspace are sent as their own events
+	app.OnRecordAfterUpdateSuccess(constants.CollectionWorkspaces).BindFunc(func(e *core.RecordEvent) error {
+		event := services.WebhookEventWorkspaceUpdated
+		wasDeleted := helpers.IsWorkspaceDeleted(e.Record.Original())
+		isDeleted := helpers.IsWorkspaceDeleted(e.Record)
+		switch {
+		case isDeleted && !wasDeleted:
+			event = services.WebhookEventWorkspaceDeleted
+		case wasDeleted && !isDeleted:
+			event = services.WebhookEventWorkspaceRestored
+		}
+
+		dispatch(e.Record, e.Record.Id, event)
+		return e.Next()
+	})
+
+	app.Cron().MustAdd("processWebhookDeliveries", "* * * * *", func() {
+		webhookService.ProcessPending()
+	})
+
+	app.Cron().MustAdd("pruneWebhookDeliveries", "45 3 * * *", func() {
+		webhookService.PruneDeliveries()
+	})
+}
@@ -4,9 +4,11 @@ import (
 	"log"
 	"os"
 	"strings"
+	"pocketvue/commands"
 	"pocketvue/config"
 	"pocketvue/hooks"
 	"pocketvue/routes"
+	"pocketvue/services"
 	"pocketvue/ui"
 
 	"github.com/joho/godotenv"
@@ -40,9 +42,28 @@ func main() {
 	})
 
 	// Register hooks
+	hooks.RegisterAuditLogHooks(app)
+	hooks.RegisterAPIKeyHooks(app)
 	hooks.RegisterUserCreatedHook(app)
+	hooks.RegisterInvoiceRetryJob(app)
+	hooks.RegisterWorkspaceMemberHooks(app)
+	hooks.RegisterWorkspaceSlugHooks(app)
+	hooks.RegisterWorkspaceDomainHooks(app)
+	hooks.RegisterWorkspaceLogoHooks(app)
+	hooks.RegisterWorkspaceSettingsHooks(app)
+	hooks.RegisterWorkspaceDeletionHooks(app)
+	hooks.RegisterWorkspaceWebhookHooks(app)
+	hooks.RegisterWorkspaceCloneJobs(app)
+	hooks.RegisterProductHooks(app)
+	hooks.RegisterCustomerSyncHooks(app)
+	hooks.RegisterSettingsHooks(app)
+
+	// Register commands
+	commands.RegisterConfigCommand(app)
 
 	app.OnServe().BindFunc(func(se *core.ServeEvent) error {
+		se.Router.BindFunc(routes.ResolveCustomDomain)
+		se.Router.BindFunc(routes.AuthenticateAPIKey)
 		se.Router.GET("/{path...}", apis.Static(ui.DistDirFS, true)).
 			BindFunc(func(e *core.RequestEvent) error {
 				if e.Request.URL.Path != "/" {
@@ -51,11 +72,53 @@ func main() {
 				return e.Next()
 			}).
 			Bind(apis.Gzip())
-		se.Router.GET("/api/workspaces", routes.GetAllWorkspaces) // this is a test endpoint
+		se.Router.GET("/api/workspaces", routes.ListWorkspaces)
+		se.Router.GET("/api/workspaces/slug-available", routes.CheckSlugAvailability)
+		se.Router.GET("/api/workspaces/resolve", routes.ResolveWorkspaceSlug)
+		se.Router.GET("/api/workspaces/deleted", routes.ListDeletedWorkspaces)
+		se.Router.POST("/api/workspaces/{id}/restore", routes.RestoreWorkspace)
+		se.Router.GET("/api/workspaces/{id}/export", routes.ExportWorkspace).
+			BindFunc(routes.RequireAPIKeyScope(services.APIKeyScopeNotesRead, services.APIKeyScopeWorkspaceRead))
+		se.Router.POST("/api/workspaces/import", routes.ImportWorkspace)
 		se.Router.GET("/api/products", routes.GetProducts)
 		se.Router.POST("/api/checkout", routes.CreateCheckoutSession)
+		se.Router.GET("/api/checkout/{id}/status", routes.GetCheckoutStatus)
 		se.Router.POST("/api/customer-portal", routes.CreateCustomerPortalSession)
 		se.Router.POST("/api/polar-webhook", routes.HandlePolarWebhook)
+		se.Router.GET("/api/orders/{id}/invoice", routes.GetOrderInvoice)
+		se.Router.GET("/api/admin/metrics/billing", routes.GetBillingMetrics)
+		se.Router.POST("/api/workspaces/{id}/invites", routes.CreateWorkspaceInvite)
+		se.Router.GET("/api/workspaces/{id}/invites", routes.ListWorkspaceInvites)
+		se.Router.POST("/api/workspaces/{id}/invites/{inviteId}/resend", routes.ResendWorkspaceInvite)
+		se.Router.DELETE("/api/workspaces/{id}/invites/{inviteId}", routes.RevokeWorkspaceInvite)
+		se.Router.GET("/api/invites/preview", routes.PreviewInvite)
+		se.Router.POST("/api/invites/accept", routes.AcceptInvite)
+		se.Router.GET("/api/workspaces/{id}/transfer", routes.GetWorkspaceTransfer)
+		se.Router.POST("/api/workspaces/{id}/transfer", routes.CreateWorkspaceTransfer)
+		se.Router.DELETE("/api/workspaces/{id}/transfer", routes.CancelWorkspaceTransfer)
+		se.Router.GET("/api/transfers/preview", routes.PreviewTransfer)
+		se.Router.POST("/api/transfers/accept", routes.AcceptTransfer)
+		se.Router.GET("/api/workspaces/{id}/domain", routes.GetWorkspaceDomain)
+		se.Router.PUT("/api/workspaces/{id}/domain", routes.SetWorkspaceDomain)
+		se.Router.POST("/api/workspaces/{id}/domain/verify", routes.VerifyWorkspaceDomain)
+		se.Router.DELETE("/api/workspaces/{id}/domain", routes.DeleteWorkspaceDomain)
+		se.Router.GET("/api/domains/current", routes.GetCurrentDomain)
+		se.Router.GET("/api/workspaces/{id}/audit", routes.ListWorkspaceAudit)
+		se.Router.GET("/api/workspaces/{id}/settings", routes.GetWorkspaceSettings)
+		se.Router.PATCH("/api/workspaces/{id}/settings", routes.UpdateWorkspaceSettings)
+		se.Router.GET("/api/workspaces/{id}/api-keys", routes.ListWorkspaceAPIKeys)
+		se.Router.POST("/api/workspaces/{id}/api-keys", routes.CreateWorkspaceAPIKey)
+		se.Router.DELETE("/api/workspaces/{id}/api-keys/{keyId}", routes.RevokeWorkspaceAPIKey)
+		se.Router.GET("/api/workspaces/{id}/webhooks", routes.ListWorkspaceWebhooks)
+		se.Router.POST("/api/workspaces/{id}/webhooks", routes.CreateWorkspaceWebhook)
+		se.Router.PATCH("/api/workspaces/{id}/webhooks/{webhookId}", routes.UpdateWorkspaceWebhook)
+		se.Router.DELETE("/api/workspaces/{id}/webhooks/{webhookId}", routes.DeleteWorkspaceWebhook)
+		se.Router.POST("/api/workspaces/{id}/webhooks/{webhookId}/test", routes.TestWorkspaceWebhook)
+		se.Router.GET("/api/workspaces/{id}/webhooks/{webhookId}/deliveries", routes.ListWebhookDeliveries)
+		se.Router.POST("/api/workspaces/{id}/clone", routes.CloneWorkspace)
+		se.Router.GET("/api/workspace-clones/{jobId}", routes.GetWorkspaceCloneJob)
+		se.Router.GET("/api/workspace-templates", routes.ListWorkspaceTemplates)
+		se.Router.POST("/api/workspace-templates/{id}/use", routes.UseWorkspaceTemplate)
 		return se.Next()
 	})
 
@@ -0,0 +1,265 @@
+package migrations
+
+import (
+	"encoding/json"
+
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	m.Register(func(app core.App) error {
+		jsonData := `{
+			"createRule": null,
+			"deleteRule": null,
+			"fields": [
+				{
+					"autogeneratePattern": "",
+					"hidden": false,
+					"id": "text3208210256",
+					"max": 36,
+					"min": 36,
+					"name": "id",
+					"pattern": "^[a-f0-9]{8}-[a-f0-9]{4}-[a-f0-9]{4}-[a-f0-9]{4}-[a-f0-9]{12}$",
+					"presentable": false,
+					"primaryKey": true,
+					"required": true,
+					"system": true,
+					"type": "text"
+				},
+				{
+					"cascadeDelete": true,
+					"collectionId": "_pb_users_auth_",
+					"hidden": false,
+					"id": "relation2375276105",
+					"maxSelect": 1,
+					"minSelect": 0,
+					"name": "user",
+					"presentable": false,
+					"required": true,
+					"system": false,
+					"type": "relation"
+				},
+				{
+					"cascadeDelete": false,
+					"collectionId": "pbc_2170078043",
+					"hidden": false,
+					"id": "relation2375286809",
+					"maxSelect": 1,
+					"minSelect": 0,
+					"name": "workspace",
+					"presentable": false,
+					"required": false,
+					"system": false,
+					"type": "relation"
+				},
+				{
+					"autogeneratePattern": "",
+					"hidden": false,
+					"id": "text2063623452",
+					"max": 0,
+					"min": 0,
+					"name": "status",
+					"pattern": "",
+					"presentable": false,
+					"primaryKey": false,
+					"required": false,
+					"system": false,
+					"type": "text"
+				},
+				{
+					"autogeneratePattern": "",
+					"hidden": false,
+					"id": "text2120395612",
+					"max": 0,
+					"min": 0,
+					"name": "billing_reason",
+					"pattern": "",
+					"presentable": false,
+					"primaryKey": false,
+					"required": false,
+					"system": false,
+					"type": "text"
+				},
+				{
+					"autogeneratePattern": "",
+					"hidden": false,
+					"id": "text1166304858",
+					"max": 0,
+					"min": 0,
+					"name": "product_id",
+					"pattern": "",
+					"presentable": false,
+					"primaryKey": false,
+					"required": false,
+					"system": false,
+					"type": "text"
+				},
+				{
+					"autogeneratePattern": "",
+					"hidden": false,
+					"id": "text2585298908",
+					"max": 0,
+					"min": 0,
+					"name": "subscription_id",
+					"pattern": "",
+					"presentable": false,
+					"primaryKey": false,
+					"required": false,
+					"system": false,
+					"type": "text"
+				},
+				{
+					"hidden": false,
+					"id": "number1186288468",
+					"max": null,
+					"min": null,
+					"name": "total_amount",
+					"onlyInt": false,
+					"presentable": false,
+					"required": false,
+					"system": false,
+					"type": "number"
+				},
+				{
+					"autogeneratePattern": "",
+					"hidden": false,
+					"id": "text1767278655",
+					"max": 0,
+					"min": 0,
+					"name": "currency",
+					"pattern": "",
+					"presentable": false,
+					"primaryKey": false,
+					"required": false,
+					"system": false,
+					"type": "text"
+				},
+				{
+					"hidden": false,
+					"id": "date3735298188",
+					"max": "",
+					"min": "",
+					"name": "paid_at",
+					"presentable": false,
+					"required": false,
+					"system": false,
+					"type": "date"
+				},
+				{
+					"hidden": false,
+					"id": "file2422544196",
+					"maxSelect": 1,
+					"maxSize": 10485760,
+					"mimeTypes": [
+						"application/pdf"
+					],
+					"name": "invoice",
+					"presentable": false,
+					"protected": true,
+					"required": false,
+					"system": false,
+					"thumbs": null,
+					"type": "file"
+				},
+				{
+					"hidden": false,
+					"id": "select3224827983",
+					"maxSelect": 1,
+					"name": "invoice_status",
+					"presentable": false,
+					"required": false,
+					"system": false,
+					"type": "select",
+					"values": [
+						"pending",
+						"ready",
+						"failed"
+					]
+				},
+				{
+					"hidden": false,
+					"id": "number2700066108",
+					"max": null,
+					"min": 0,
+					"name": "invoice_attempts",
+					"onlyInt": true,
+					"presentable": false,
+					"required": false,
+					"system": false,
+					"type": "number"
+				},
+				{
+					"hidden": false,
+					"id": "date1705314224",
+					"max": "",
+					"min": "",
+					"name": "invoice_next_attempt",
+					"presentable": false,
+					"required": false,
+					"system": false,
+					"type": "date"
+				},
+				{
+					"autogeneratePattern": "",
+					"hidden": false,
+					"id": "text1611132100",
+					"max": 0,
+					"min": 0,
+					"name": "invoice_error",
+					"pattern": "",
+					"presentable": false,
+					"primaryKey": false,
+					"required": false,
+					"system": false,
+					"type": "text"
+				},
+				{
+					"hidden": false,
+					"id": "autodate2990389176",
+					"name": "created",
+					"onCreate": true,
+					"onUpdate": false,
+					"presentable": false,
+					"system": false,
+					"type": "autodate"
+				},
+				{
+					"hidden": false,
+					"id": "autodate3332085495",
+					"name": "updated",
+					"onCreate": true,
+					"onUpdate": true,
+					"presentable": false,
+					"system": false,
+					"type": "autodate"
+				}
+			],
+			"id": "pbc_3845127662",
+			"indexes": [
+				"CREATE INDEX ` + "`" + `idx_orders_user` + "`" + ` ON ` + "`" + `orders` + "`" + ` (` + "`" + `user` + "`" + `)",
+				"CREATE INDEX ` + "`" + `idx_orders_invoice_status` + "`" + ` ON ` + "`" + `orders` + "`" + ` (` + "`" + `invoice_status` + "`" + `, ` + "`" + `invoice_next_attempt` + "`" + `)"
+			],
+			"listRule": "user = @request.auth.id && @request.auth.banned != true",
+			"name": "orders",
+			"system": false,
+			"type": "base",
+			"updateRule": null,
+			"viewRule": "user = @request.auth.id && @request.auth.banned != true"
+		}`
+
+		collection := &core.Collection{}
+		if err := json.Unmarshal([]byte(jsonData), &collection); err != nil {
+			return err
+		}
+
+		return app.Save(collection)
+	}, func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("pbc_3845127662")
+		if err != nil {
+			return err
+		}
+
+		return app.Delete(collection)
+	})
+}
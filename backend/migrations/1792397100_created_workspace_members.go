@@ -0,0 +1,103 @@
+package migrations
+
+import (
+	"encoding/json"
+
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	m.Register(func(app core.App) error {
+		jsonData := `{
+			"createRule": "workspace.user = @request.auth.id && @request.auth.banned != true",
+			"deleteRule": "(workspace.user = @request.auth.id || user = @request.auth.id) && @request.auth.banned != true",
+			"fields": [
+				{
+					"autogeneratePattern": "[a-z0-9]{15}",
+					"hidden": false,
+					"id": "text3208210256",
+					"max": 15,
+					"min": 15,
+					"name": "id",
+					"pattern": "^[a-z0-9]+$",
+					"presentable": false,
+					"primaryKey": true,
+					"required": true,
+					"system": true,
+					"type": "text"
+				},
+				{
+					"cascadeDelete": true,
+					"collectionId": "pbc_2170078043",
+					"hidden": false,
+					"id": "relation2375286809",
+					"maxSelect": 1,
+					"minSelect": 0,
+					"name": "workspace",
+					"presentable": false,
+					"required": true,
+					"system": false,
+					"type": "relation"
+				},
+				{
+					"cascadeDelete": true,
+					"collectionId": "_pb_users_auth_",
+					"hidden": false,
+					"id": "relation2375276105",
+					"maxSelect": 1,
+					"minSelect": 0,
+					"name": "user",
+					"presentable": false,
+					"required": true,
+					"system": false,
+					"type": "relation"
+				},
+				{
+					"hidden": false,
+					"id": "autodate2990389176",
+					"name": "created",
+					"onCreate": true,
+					"onUpdate": false,
+					"presentable": false,
+					"system": false,
+					"type": "autodate"
+				},
+				{
+					"hidden": false,
+					"id": "autodate3332085495",
+					"name": "updated",
+					"onCreate": true,
+					"onUpdate": true,
+					"presentable": false,
+					"system": false,
+					"type": "autodate"
+				}
+			],
+			"id": "pbc_2644326900",
+			"indexes": [
+				"CREATE UNIQUE INDEX ` + "`" + `idx_workspace_members_workspace_user` + "`" + ` ON ` + "`" + `workspace_members` + "`" + ` (` + "`" + `workspace` + "`" + `, ` + "`" + `user` + "`" + `)"
+			],
+			"listRule": "(workspace.user = @request.auth.id || user = @request.auth.id) && @request.auth.banned != true",
+			"name": "workspace_members",
+			"system": false,
+			"type": "base",
+			"updateRule": null,
+			"viewRule": "(workspace.user = @request.auth.id || user = @request.auth.id) && @request.auth.banned != true"
+		}`
+
+		collection := &core.Collection{}
+		if err := json.Unmarshal([]byte(jsonData), &collection); err != nil {
+			return err
+		}
+
+		return app.Save(collection)
+	}, func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("pbc_2644326900")
+		if err != nil {
+			return err
+		}
+
+		return app.Delete(collection)
+	})
+}
@@ -0,0 +1,115 @@
+package migrations
+
+import (
+	"encoding/json"
+
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	m.Register(func(app core.App) error {
+		jsonData := `{
+			"createRule": null,
+			"deleteRule": null,
+			"fields": [
+				{
+					"autogeneratePattern": "[a-z0-9]{15}",
+					"hidden": false,
+					"id": "text3208210256",
+					"max": 15,
+					"min": 15,
+					"name": "id",
+					"pattern": "^[a-z0-9]+$",
+					"presentable": false,
+					"primaryKey": true,
+					"required": true,
+					"system": true,
+					"type": "text"
+				},
+				{
+					"hidden": false,
+					"id": "select2324736937",
+					"maxSelect": 1,
+					"name": "key",
+					"presentable": false,
+					"required": true,
+					"system": false,
+					"type": "select",
+					"values": [
+						"APP_ENV",
+						"FRONTEND_URL",
+						"POLAR_ENVIRONMENT",
+						"POLAR_ACCESS_TOKEN",
+						"POLAR_WEBHOOK_SECRET",
+						"POLAR_CUSTOMER_DELETION",
+						"POLAR_TIMEOUT_SECONDS",
+						"POLAR_MAX_RETRIES",
+						"POLAR_BREAKER_THRESHOLD",
+						"POLAR_BREAKER_COOLDOWN_SECONDS",
+						"SEAT_OVERAGE_MODE",
+						"FREE_WORKSPACE_SEATS"
+					]
+				},
+				{
+					"autogeneratePattern": "",
+					"hidden": false,
+					"id": "text494360628",
+					"max": 0,
+					"min": 0,
+					"name": "value",
+					"pattern": "",
+					"presentable": false,
+					"primaryKey": false,
+					"required": false,
+					"system": false,
+					"type": "text"
+				},
+				{
+					"hidden": false,
+					"id": "autodate2990389176",
+					"name": "created",
+					"onCreate": true,
+					"onUpdate": false,
+					"presentable": false,
+					"system": false,
+					"type": "autodate"
+				},
+				{
+					"hidden": false,
+					"id": "autodate3332085495",
+					"name": "updated",
+					"onCreate": true,
+					"onUpdate": true,
+					"presentable": false,
+					"system": false,
+					"type": "autodate"
+				}
+			],
+			"id": "pbc_3989160040",
+			"indexes": [
+				"CREATE UNIQUE INDEX ` + "`" + `idx_app_settings_key` + "`" + ` ON ` + "`" + `app_settings` + "`" + ` (` + "`" + `key` + "`" + `)"
+			],
+			"listRule": null,
+			"name": "app_settings",
+			"system": false,
+			"type": "base",
+			"updateRule": null,
+			"viewRule": null
+		}`
+
+		collection := &core.Collection{}
+		if err := json.Unmarshal([]byte(jsonData), &collection); err != nil {
+			return err
+		}
+
+		return app.Save(collection)
+	}, func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("pbc_3989160040")
+		if err != nil {
+			return err
+		}
+
+		return app.Delete(collection)
+	})
+}
@@ -0,0 +1,71 @@
+package migrations
+
+import (
+	"encoding/json"
+
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	m.Register(func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("pbc_2644326900")
+		if err != nil {
+			return err
+		}
+
+		// update collection data
+		if err := json.Unmarshal([]byte(`{
+			"createRule": "@request.auth.banned != true && @request.body.role != \"owner\" && (workspace.user = @request.auth.id || (@collection.workspace_members:member.workspace ?= workspace && @collection.workspace_members:member.user ?= @request.auth.id && (@collection.workspace_members:member.role ?= \"owner\" || @collection.workspace_members:member.role ?= \"admin\")))",
+			"deleteRule": "@request.auth.banned != true && role != \"owner\" && (user = @request.auth.id || workspace.user = @request.auth.id || (@collection.workspace_members:member.workspace ?= workspace && @collection.workspace_members:member.user ?= @request.auth.id && (@collection.workspace_members:member.role ?= \"owner\" || @collection.workspace_members:member.role ?= \"admin\")))",
+			"listRule": "@request.auth.banned != true && (workspace.user = @request.auth.id || (@collection.workspace_members:member.workspace ?= workspace && @collection.workspace_members:member.user ?= @request.auth.id))",
+			"updateRule": "@request.auth.banned != true && role != \"owner\" && (@request.body.role:isset = false || @request.body.role != \"owner\") && (@request.body.workspace:isset = false || @request.body.workspace = workspace) && (@request.body.user:isset = false || @request.body.user = user) && (workspace.user = @request.auth.id || (@collection.workspace_members:member.workspace ?= workspace && @collection.workspace_members:member.user ?= @request.auth.id && (@collection.workspace_members:member.role ?= \"owner\" || @collection.workspace_members:member.role ?= \"admin\")))",
+			"viewRule": "@request.auth.banned != true && (workspace.user = @request.auth.id || (@collection.workspace_members:member.workspace ?= workspace && @collection.workspace_members:member.user ?= @request.auth.id))"
+		}`), &collection); err != nil {
+			return err
+		}
+
+		// add field
+		if err := collection.Fields.AddMarshaledJSONAt(3, []byte(`{
+			"hidden": false,
+			"id": "select1466534506",
+			"maxSelect": 1,
+			"name": "role",
+			"presentable": false,
+			"required": true,
+			"system": false,
+			"type": "select",
+			"values": [
+				"owner",
+				"admin",
+				"member",
+				"viewer"
+			]
+		}`)); err != nil {
+			return err
+		}
+
+		return app.Save(collection)
+	}, func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("pbc_2644326900")
+		if err != nil {
+			return err
+		}
+
+		// update collection data
+		if err := json.Unmarshal([]byte(`{
+			"createRule": "workspace.user = @request.auth.id && @request.auth.banned != true",
+			"deleteRule": "(workspace.user = @request.auth.id || user = @request.auth.id) && @request.auth.banned != true",
+			"listRule": "(workspace.user = @request.auth.id || user = @request.auth.id) && @request.auth.banned != true",
+			"updateRule": null,
+			"viewRule": "(workspace.user = @request.auth.id || user = @request.auth.id) && @request.auth.banned != true"
+		}`), &collection); err != nil {
+			return err
+		}
+
+		// remove field
+		collection.Fields.RemoveById("select1466534506")
+
+		return app.Save(collection)
+	})
+}
@@ -0,0 +1,44 @@
+package migrations
+
+import (
+	"encoding/json"
+
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	m.Register(func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("pbc_2170078043")
+		if err != nil {
+			return err
+		}
+
+		// update collection data
+		if err := json.Unmarshal([]byte(`{
+			"listRule": "@request.auth.banned != true && (user = @request.auth.id || (@collection.workspace_members:member.workspace ?= id && @collection.workspace_members:member.user ?= @request.auth.id))",
+			"updateRule": "@request.auth.banned != true && @request.body.seats:isset = false && @request.body.subscription_id:isset = false && (@request.body.user:isset = false || @request.body.user = user) && (user = @request.auth.id || (@collection.workspace_members:member.workspace ?= id && @collection.workspace_members:member.user ?= @request.auth.id && (@collection.workspace_members:member.role ?= \"owner\" || @collection.workspace_members:member.role ?= \"admin\")))",
+			"viewRule": "@request.auth.banned != true && (user = @request.auth.id || (@collection.workspace_members:member.workspace ?= id && @collection.workspace_members:member.user ?= @request.auth.id))"
+		}`), &collection); err != nil {
+			return err
+		}
+
+		return app.Save(collection)
+	}, func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("pbc_2170078043")
+		if err != nil {
+			return err
+		}
+
+		// update collection data
+		if err := json.Unmarshal([]byte(`{
+			"listRule": "user = @request.auth.id && @request.auth.banned != true",
+			"updateRule": "user = @request.auth.id && @request.auth.banned != true && @request.body.seats:isset = false && @request.body.subscription_id:isset = false",
+			"viewRule": "user = @request.auth.id && @request.auth.banned != true"
+		}`), &collection); err != nil {
+			return err
+		}
+
+		return app.Save(collection)
+	})
+}
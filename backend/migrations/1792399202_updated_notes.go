@@ -0,0 +1,48 @@
+package migrations
+
+import (
+	"encoding/json"
+
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	m.Register(func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("pbc_3395098727")
+		if err != nil {
+			return err
+		}
+
+		// update collection data
+		if err := json.Unmarshal([]byte(`{
+			"createRule": "@request.auth.banned != true && user = @request.auth.id && (workspace.user = @request.auth.id || (@collection.workspace_members:member.workspace ?= workspace && @collection.workspace_members:member.user ?= @request.auth.id && (@collection.workspace_members:member.role ?= \"owner\" || @collection.workspace_members:member.role ?= \"admin\" || @collection.workspace_members:member.role ?= \"member\")))",
+			"deleteRule": "@request.auth.banned != true && (workspace.user = @request.auth.id || (@collection.workspace_members:member.workspace ?= workspace && @collection.workspace_members:member.user ?= @request.auth.id && (@collection.workspace_members:member.role ?= \"owner\" || @collection.workspace_members:member.role ?= \"admin\")) || (user = @request.auth.id && @collection.workspace_members:member.workspace ?= workspace && @collection.workspace_members:member.user ?= @request.auth.id && (@collection.workspace_members:member.role ?= \"member\")))",
+			"listRule": "@request.auth.banned != true && (workspace.user = @request.auth.id || (@collection.workspace_members:member.workspace ?= workspace && @collection.workspace_members:member.user ?= @request.auth.id))",
+			"updateRule": "@request.auth.banned != true && (@request.body.user:isset = false || @request.body.user = user) && (@request.body.workspace:isset = false || @request.body.workspace = workspace) && (workspace.user = @request.auth.id || (@collection.workspace_members:member.workspace ?= workspace && @collection.workspace_members:member.user ?= @request.auth.id && (@collection.workspace_members:member.role ?= \"owner\" || @collection.workspace_members:member.role ?= \"admin\")) || (user = @request.auth.id && @collection.workspace_members:member.workspace ?= workspace && @collection.workspace_members:member.user ?= @request.auth.id && (@collection.workspace_members:member.role ?= \"member\")))",
+			"viewRule": "@request.auth.banned != true && (workspace.user = @request.auth.id || (@collection.workspace_members:member.workspace ?= workspace && @collection.workspace_members:member.user ?= @request.auth.id))"
+		}`), &collection); err != nil {
+			return err
+		}
+
+		return app.Save(collection)
+	}, func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("pbc_3395098727")
+		if err != nil {
+			return err
+		}
+
+		// update collection data
+		if err := json.Unmarshal([]byte(`{
+			"createRule": "user = @request.auth.id && @request.auth.banned != true",
+			"deleteRule": "user = @request.auth.id && @request.auth.banned != true",
+			"listRule": "user = @request.auth.id && @request.auth.banned != true",
+			"updateRule": "user = @request.auth.id && @request.auth.banned != true",
+			"viewRule": "user = @request.auth.id && @request.auth.banned != true"
+		}`), &collection); err != nil {
+			return err
+		}
+
+		return app.Save(collection)
+	})
+}
@@ -0,0 +1,65 @@
+package migrations
+
+import (
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// Turns the owner of every existing workspace into an owner member
+// Plain queries are used so the seat limit hooks do not run during the migration
+func init() {
+	m.Register(func(app core.App) error {
+		// Members added before roles existed are regular members
+		if _, err := app.DB().NewQuery("UPDATE {{workspace_members}} SET [[role]] = 'member' WHERE [[role]] = ''").Execute(); err != nil {
+			return err
+		}
+
+		// Owners already listed as members get the owner role
+		if _, err := app.DB().NewQuery(`
+			UPDATE {{workspace_members}} SET [[role]] = 'owner'
+			WHERE [[user]] = (SELECT [[w.user]] FROM {{workspaces}} w WHERE [[w.id]] = {{workspace_members}}.[[workspace]])
+		`).Execute(); err != nil {
+			return err
+		}
+
+		var owners []struct {
+			Workspace string `db:"workspace"`
+			User      string `db:"user"`
+		}
+		err := app.DB().NewQuery(`
+			SELECT [[w.id]] AS workspace, [[w.user]] AS user FROM {{workspaces}} w
+			WHERE [[w.user]] != '' AND NOT EXISTS (
+				SELECT 1 FROM {{workspace_members}} m WHERE [[m.workspace]] = [[w.id]] AND [[m.user]] = [[w.user]]
+			)
+		`).All(&owners)
+		if err != nil {
+			return err
+		}
+
+		now := types.NowDateTime().String()
+		for _, owner := range owners {
+			_, err := app.DB().Insert("workspace_members", dbx.Params{
+				"id":        core.GenerateDefaultRandomId(),
+				"workspace": owner.Workspace,
+				"user":      owner.User,
+				"role":      "owner",
+				"created":   now,
+				"updated":   now,
+			}).Execute()
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}, func(app core.App) error {
+		// Owners are tracked by workspaces.user again once roles are removed
+		_, err := app.DB().NewQuery(`
+			DELETE FROM {{workspace_members}}
+			WHERE [[role]] = 'owner' AND [[user]] = (SELECT [[w.user]] FROM {{workspaces}} w WHERE [[w.id]] = {{workspace_members}}.[[workspace]])
+		`).Execute()
+		return err
+	})
+}
@@ -0,0 +1,125 @@
+package migrations
+
+import (
+	"encoding/json"
+
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	m.Register(func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("pbc_2170078043")
+		if err != nil {
+			return err
+		}
+
+		// update collection data
+		if err := json.Unmarshal([]byte(`{
+			"createRule": "user = @request.auth.id && @request.auth.banned != true && @request.body.seats:isset = false && @request.body.subscription_id:isset = false && @request.body.custom_domain:isset = false && @request.body.domain_verified:isset = false && @request.body.domain_verification_token:isset = false && @request.body.domain_verified_at:isset = false",
+			"indexes": [
+				"CREATE UNIQUE INDEX ` + "`" + `unique_workspace_slug` + "`" + ` ON ` + "`" + `workspaces` + "`" + ` (` + "`" + `slug` + "`" + ` COLLATE NOCASE)",
+				"CREATE UNIQUE INDEX ` + "`" + `unique_workspace_custom_domain` + "`" + ` ON ` + "`" + `workspaces` + "`" + ` (` + "`" + `custom_domain` + "`" + `) WHERE ` + "`" + `custom_domain` + "`" + ` != ''"
+			],
+			"updateRule": "@request.auth.banned != true && @request.body.seats:isset = false && @request.body.subscription_id:isset = false && @request.body.custom_domain:isset = false && @request.body.domain_verified:isset = false && @request.body.domain_verification_token:isset = false && @request.body.domain_verified_at:isset = false && (@request.body.user:isset = false || @request.body.user = user) && (user = @request.auth.id || (@collection.workspace_members:member.workspace ?= id && @collection.workspace_members:member.user ?= @request.auth.id && (@collection.workspace_members:member.role ?= \"owner\" || @collection.workspace_members:member.role ?= \"admin\")))"
+		}`), &collection); err != nil {
+			return err
+		}
+
+		// add field
+		if err := collection.Fields.AddMarshaledJSONAt(8, []byte(`{
+			"autogeneratePattern": "",
+			"hidden": false,
+			"id": "text2466564623",
+			"max": 253,
+			"min": 0,
+			"name": "custom_domain",
+			"pattern": "",
+			"presentable": false,
+			"primaryKey": false,
+			"required": false,
+			"system": false,
+			"type": "text"
+		}`)); err != nil {
+			return err
+		}
+
+		// add field
+		if err := collection.Fields.AddMarshaledJSONAt(9, []byte(`{
+			"hidden": false,
+			"id": "bool483110998",
+			"name": "domain_verified",
+			"presentable": false,
+			"required": false,
+			"system": false,
+			"type": "bool"
+		}`)); err != nil {
+			return err
+		}
+
+		// add field
+		if err := collection.Fields.AddMarshaledJSONAt(10, []byte(`{
+			"autogeneratePattern": "",
+			"hidden": true,
+			"id": "text3218178347",
+			"max": 0,
+			"min": 0,
+			"name": "domain_verification_token",
+			"pattern": "",
+			"presentable": false,
+			"primaryKey": false,
+			"required": false,
+			"system": false,
+			"type": "text"
+		}`)); err != nil {
+			return err
+		}
+
+		// add field
+		if err := collection.Fields.AddMarshaledJSONAt(11, []byte(`{
+			"hidden": false,
+			"id": "date862828738",
+			"max": "",
+			"min": "",
+			"name": "domain_verified_at",
+			"presentable": false,
+			"required": false,
+			"system": false,
+			"type": "date"
+		}`)); err != nil {
+			return err
+		}
+
+		return app.Save(collection)
+	}, func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("pbc_2170078043")
+		if err != nil {
+			return err
+		}
+
+		// update collection data
+		if err := json.Unmarshal([]byte(`{
+			"createRule": "user = @request.auth.id && @request.auth.banned != true && @request.body.seats:isset = false && @request.body.subscription_id:isset = false",
+			"indexes": [
+				"CREATE UNIQUE INDEX ` + "`" + `unique_workspace_slug` + "`" + ` ON ` + "`" + `workspaces` + "`" + ` (` + "`" + `slug` + "`" + ` COLLATE NOCASE)"
+			],
+			"updateRule": "@request.auth.banned != true && @request.body.seats:isset = false && @request.body.subscription_id:isset = false && (@request.body.user:isset = false || @request.body.user = user) && (user = @request.auth.id || (@collection.workspace_members:member.workspace ?= id && @collection.workspace_members:member.user ?= @request.auth.id && (@collection.workspace_members:member.role ?= \"owner\" || @collection.workspace_members:member.role ?= \"admin\")))"
+		}`), &collection); err != nil {
+			return err
+		}
+
+		// remove field
+		collection.Fields.RemoveById("text2466564623")
+
+		// remove field
+		collection.Fields.RemoveById("bool483110998")
+
+		// remove field
+		collection.Fields.RemoveById("text3218178347")
+
+		// remove field
+		collection.Fields.RemoveById("date862828738")
+
+		return app.Save(collection)
+	})
+}
@@ -0,0 +1,86 @@
+package migrations
+
+import (
+	"encoding/json"
+
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	m.Register(func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("pbc_2170078043")
+		if err != nil {
+			return err
+		}
+
+		// update collection data
+		if err := json.Unmarshal([]byte(`{
+			"createRule": "user = @request.auth.id && @request.auth.banned != true && @request.body.seats:isset = false && @request.body.subscription_id:isset = false && @request.body.custom_domain:isset = false && @request.body.domain_verified:isset = false && @request.body.domain_verification_token:isset = false && @request.body.domain_verified_at:isset = false && @request.body.deleted_at:isset = false && @request.body.deleted_by:isset = false",
+			"deleteRule": "user = @request.auth.id && @request.auth.banned != true && deleted_at = \"\"",
+			"listRule": "@request.auth.banned != true && deleted_at = \"\" && (user = @request.auth.id || (@collection.workspace_members:member.workspace ?= id && @collection.workspace_members:member.user ?= @request.auth.id))",
+			"updateRule": "@request.auth.banned != true && deleted_at = \"\" && @request.body.seats:isset = false && @request.body.subscription_id:isset = false && @request.body.custom_domain:isset = false && @request.body.domain_verified:isset = false && @request.body.domain_verification_token:isset = false && @request.body.domain_verified_at:isset = false && @request.body.deleted_at:isset = false && @request.body.deleted_by:isset = false && (@request.body.user:isset = false || @request.body.user = user) && (user = @request.auth.id || (@collection.workspace_members:member.workspace ?= id && @collection.workspace_members:member.user ?= @request.auth.id && (@collection.workspace_members:member.role ?= \"owner\" || @collection.workspace_members:member.role ?= \"admin\")))",
+			"viewRule": "@request.auth.banned != true && deleted_at = \"\" && (user = @request.auth.id || (@collection.workspace_members:member.workspace ?= id && @collection.workspace_members:member.user ?= @request.auth.id))"
+		}`), &collection); err != nil {
+			return err
+		}
+
+		// add field
+		if err := collection.Fields.AddMarshaledJSONAt(12, []byte(`{
+			"hidden": false,
+			"id": "date1257476049",
+			"max": "",
+			"min": "",
+			"name": "deleted_at",
+			"presentable": false,
+			"required": false,
+			"system": false,
+			"type": "date"
+		}`)); err != nil {
+			return err
+		}
+
+		// add field
+		if err := collection.Fields.AddMarshaledJSONAt(13, []byte(`{
+			"cascadeDelete": false,
+			"collectionId": "_pb_users_auth_",
+			"hidden": false,
+			"id": "relation527409327",
+			"maxSelect": 1,
+			"minSelect": 0,
+			"name": "deleted_by",
+			"presentable": false,
+			"required": false,
+			"system": false,
+			"type": "relation"
+		}`)); err != nil {
+			return err
+		}
+
+		return app.Save(collection)
+	}, func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("pbc_2170078043")
+		if err != nil {
+			return err
+		}
+
+		// update collection data
+		if err := json.Unmarshal([]byte(`{
+			"createRule": "user = @request.auth.id && @request.auth.banned != true && @request.body.seats:isset = false && @request.body.subscription_id:isset = false && @request.body.custom_domain:isset = false && @request.body.domain_verified:isset = false && @request.body.domain_verification_token:isset = false && @request.body.domain_verified_at:isset = false",
+			"deleteRule": "user = @request.auth.id && @request.auth.banned != true",
+			"listRule": "@request.auth.banned != true && (user = @request.auth.id || (@collection.workspace_members:member.workspace ?= id && @collection.workspace_members:member.user ?= @request.auth.id))",
+			"updateRule": "@request.auth.banned != true && @request.body.seats:isset = false && @request.body.subscription_id:isset = false && @request.body.custom_domain:isset = false && @request.body.domain_verified:isset = false && @request.body.domain_verification_token:isset = false && @request.body.domain_verified_at:isset = false && (@request.body.user:isset = false || @request.body.user = user) && (user = @request.auth.id || (@collection.workspace_members:member.workspace ?= id && @collection.workspace_members:member.user ?= @request.auth.id && (@collection.workspace_members:member.role ?= \"owner\" || @collection.workspace_members:member.role ?= \"admin\")))",
+			"viewRule": "@request.auth.banned != true && (user = @request.auth.id || (@collection.workspace_members:member.workspace ?= id && @collection.workspace_members:member.user ?= @request.auth.id))"
+		}`), &collection); err != nil {
+			return err
+		}
+
+		// remove field
+		collection.Fields.RemoveById("date1257476049")
+
+		// remove field
+		collection.Fields.RemoveById("relation527409327")
+
+		return app.Save(collection)
+	})
+}
@@ -0,0 +1,89 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	m.Register(func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("pbc_3989160040")
+		if err != nil {
+			return err
+		}
+
+		// update field
+		if err := collection.Fields.AddMarshaledJSONAt(1, []byte(`{
+			"hidden": false,
+			"id": "select2324736937",
+			"maxSelect": 1,
+			"name": "key",
+			"presentable": false,
+			"required": true,
+			"system": false,
+			"type": "select",
+			"values": [
+				"APP_ENV",
+				"FRONTEND_URL",
+				"POLAR_ENVIRONMENT",
+				"POLAR_ACCESS_TOKEN",
+				"POLAR_WEBHOOK_SECRET",
+				"POLAR_CUSTOMER_DELETION",
+				"POLAR_TIMEOUT_SECONDS",
+				"POLAR_MAX_RETRIES",
+				"POLAR_BREAKER_THRESHOLD",
+				"POLAR_BREAKER_COOLDOWN_SECONDS",
+				"SEAT_OVERAGE_MODE",
+				"FREE_WORKSPACE_SEATS",
+				"WORKSPACE_INVITE_TTL_HOURS",
+				"WORKSPACE_INVITE_HOURLY_LIMIT",
+				"WORKSPACE_SLUG_COOLDOWN_DAYS",
+				"WORKSPACE_TRANSFER_TTL_HOURS",
+				"WORKSPACE_RETENTION_DAYS"
+			]
+		}`)); err != nil {
+			return err
+		}
+
+		return app.Save(collection)
+	}, func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("pbc_3989160040")
+		if err != nil {
+			return err
+		}
+
+		// update field
+		if err := collection.Fields.AddMarshaledJSONAt(1, []byte(`{
+			"hidden": false,
+			"id": "select2324736937",
+			"maxSelect": 1,
+			"name": "key",
+			"presentable": false,
+			"required": true,
+			"system": false,
+			"type": "select",
+			"values": [
+				"APP_ENV",
+				"FRONTEND_URL",
+				"POLAR_ENVIRONMENT",
+				"POLAR_ACCESS_TOKEN",
+				"POLAR_WEBHOOK_SECRET",
+				"POLAR_CUSTOMER_DELETION",
+				"POLAR_TIMEOUT_SECONDS",
+				"POLAR_MAX_RETRIES",
+				"POLAR_BREAKER_THRESHOLD",
+				"POLAR_BREAKER_COOLDOWN_SECONDS",
+				"SEAT_OVERAGE_MODE",
+				"FREE_WORKSPACE_SEATS",
+				"WORKSPACE_INVITE_TTL_HOURS",
+				"WORKSPACE_INVITE_HOURLY_LIMIT",
+				"WORKSPACE_SLUG_COOLDOWN_DAYS",
+				"WORKSPACE_TRANSFER_TTL_HOURS"
+			]
+		}`)); err != nil {
+			return err
+		}
+
+		return app.Save(collection)
+	})
+}
@@ -0,0 +1,74 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	m.Register(func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("pbc_2170078043")
+		if err != nil {
+			return err
+		}
+
+		// update field
+		if err := collection.Fields.AddMarshaledJSONAt(3, []byte(`{
+			"hidden": false,
+			"id": "file3834550803",
+			"maxSelect": 1,
+			"maxSize": 2000000,
+			"mimeTypes": [
+				"image/png",
+				"image/jpeg",
+				"image/webp",
+				"image/svg+xml"
+			],
+			"name": "logo",
+			"presentable": false,
+			"protected": false,
+			"required": false,
+			"system": false,
+			"thumbs": [
+				"192x192f",
+				"64x64f",
+				"32x32f"
+			],
+			"type": "file"
+		}`)); err != nil {
+			return err
+		}
+
+		return app.Save(collection)
+	}, func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("pbc_2170078043")
+		if err != nil {
+			return err
+		}
+
+		// update field
+		if err := collection.Fields.AddMarshaledJSONAt(3, []byte(`{
+			"hidden": false,
+			"id": "file3834550803",
+			"maxSelect": 1,
+			"maxSize": 2000000,
+			"mimeTypes": [
+				"image/png",
+				"image/jpeg",
+				"image/webp",
+				"image/svg+xml"
+			],
+			"name": "logo",
+			"presentable": false,
+			"protected": false,
+			"required": false,
+			"system": false,
+			"thumbs": [],
+			"type": "file"
+		}`)); err != nil {
+			return err
+		}
+
+		return app.Save(collection)
+	})
+}
@@ -0,0 +1,32 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	m.Register(func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("pbc_2170078043")
+		if err != nil {
+			return err
+		}
+
+		// replace index, only verified domains are unique
+		collection.RemoveIndex("unique_workspace_custom_domain")
+		collection.AddIndex("unique_workspace_verified_domain", true, "`custom_domain`", "`custom_domain` != '' AND `domain_verified` = TRUE")
+
+		return app.Save(collection)
+	}, func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("pbc_2170078043")
+		if err != nil {
+			return err
+		}
+
+		// restore index
+		collection.RemoveIndex("unique_workspace_verified_domain")
+		collection.AddIndex("unique_workspace_custom_domain", true, "`custom_domain`", "`custom_domain` != ''")
+
+		return app.Save(collection)
+	})
+}
@@ -0,0 +1,271 @@
+package routes
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"pocketvue/constants"
+	"pocketvue/helpers"
+	"pocketvue/services"
+	"strings"
+
+	"github.com/ganigeorgiev/fexpr"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// apiKeyUserKey is the request store key of the user of a valid API key
+// until a route accepting API keys moves it to helpers.APIKeyUserRequestKey
+const apiKeyUserKey = "apiKeyPendingUser"
+
+// manageAPIKeysForbidden is returned to members who may not manage API keys
+const manageAPIKeysForbidden = "Only workspace owners and admins can manage API keys"
+
+// CreateAPIKeyRequest represents the request body for creating an API key
+// ExpiresAt is optional, keys without it do not expire
+type CreateAPIKeyRequest struct {
+	Name      string   `json:"name"`
+	Scopes    []string `json:"scopes"`
+	ExpiresAt string   `json:"expires_at"`
+}
+
+// APIKeyResponse represents a workspace API key, the key itself is never returned after creation
+type APIKeyResponse struct {
+	ID         string   `json:"id"`
+	Name       string   `json:"name"`
+	Prefix     string   `json:"prefix"`
+	Scopes     []string `json:"scopes"`
+	CreatedBy  string   `json:"created_by"`
+	ExpiresAt  string   `json:"expires_at"`
+	LastUsedAt string   `json:"last_used_at"`
+	RevokedAt  string   `json:"revoked_at"`
+	Created    string   `json:"created"`
+}
+
+// CreatedAPIKeyResponse represents a new API key together with the key, shown only once
+type CreatedAPIKeyResponse struct {
+	APIKeyResponse
+	Key string `json:"key"`
+}
+
+// ListWorkspaceAPIKeys lists the API keys of a workspace, including revoked ones
+func ListWorkspaceAPIKeys(e *core.RequestEvent) error {
+	_, workspace, err := findManagedWorkspace(e, manageAPIKeysForbidden)
+	if err != nil {
+		return err
+	}
+
+	keys, err := services.NewAPIKeyService(e.App).List(workspace.Id)
+	if err != nil {
+		log.Printf("Error listing API keys of workspace %s: %v", workspace.Id, err)
+		return helpers.JSONInternalServerError(e, "failed to list API keys")
+	}
+
+	response := make([]APIKeyResponse, 0, len(keys))
+	for _, key := range keys {
+		response = append(response, newAPIKeyResponse(key))
+	}
+
+	return helpers.JSONSuccess(e, response)
+}
+
+// CreateWorkspaceAPIKey issues an API key for a workspace
+// The key is part of this response only, it is stored hashed
+func CreateWorkspaceAPIKey(e *core.RequestEvent) error {
+	user, workspace, err := findManagedWorkspace(e, manageAPIKeysForbidden)
+	if err != nil {
+		return err
+	}
+
+	var req CreateAPIKeyRequest
+	if err := json.NewDecoder(e.Request.Body).Decode(&req); err != nil {
+		log.Printf("Error parsing API key request: %v", err)
+		return helpers.JSONBadRequest(e, "invalid request body")
+	}
+
+	var expiresAt types.DateTime
+	if req.ExpiresAt != "" {
+		expiresAt, err = types.ParseDateTime(req.ExpiresAt)
+		if err != nil || expiresAt.IsZero() {
+			return helpers.JSONBadRequest(e, "expires_at must be a valid date")
+		}
+	}
+
+	key, secret, err := services.NewAPIKeyService(e.App).Create(workspace, user, req.Name, req.Scopes, expiresAt)
+	if err != nil {
+		return apiKeyErrorResponse(e, err, "failed to create API key")
+	}
+
+	return e.JSON(http.StatusCreated, CreatedAPIKeyResponse{
+		APIKeyResponse: newAPIKeyResponse(key),
+		Key:            secret,
+	})
+}
+
+// RevokeWorkspaceAPIKey revokes an API key of a workspace
+func RevokeWorkspaceAPIKey(e *core.RequestEvent) error {
+	user, workspace, err := findManagedWorkspace(e, manageAPIKeysForbidden)
+	if err != nil {
+		return err
+	}
+
+	key, err := e.App.FindRecordById(constants.CollectionWorkspaceAPIKeys, e.Request.PathValue("keyId"))
+	if err != nil || key.GetString("workspace") != workspace.Id {
+		return helpers.JSONNotFound(e, "API key not found")
+	}
+
+	if err := services.NewAPIKeyService(e.App).Revoke(key, user); err != nil {
+		return apiKeyErrorResponse(e, err, "failed to revoke API key")
+	}
+
+	return e.NoContent(http.StatusNoContent)
+}
+
+// AuthenticateAPIKey authenticates requests carrying a workspace API key
+// in the Authorization header (optionally with the Bearer scheme) or in the X-API-Key header
+// Requests to the records API of notes act as the key's creator, limited to the key's workspace and scopes
+// Custom routes only accept keys when they are wrapped with RequireAPIKeyScope
+func AuthenticateAPIKey(e *core.RequestEvent) error {
+	secret := apiKeyFromRequest(e.Request)
+	if secret == "" || e.Auth != nil {
+		return e.Next()
+	}
+
+	key, user, err := services.NewAPIKeyService(e.App).Authenticate(secret)
+	if err != nil {
+		if errors.Is(err, services.ErrAPIKeyInvalid) {
+			return e.UnauthorizedError("Invalid or expired API key.", nil)
+		}
+		log.Printf("Error authenticating API key: %v", err)
+		return helpers.JSONInternalServerError(e, "failed to authenticate API key")
+	}
+
+	e.Set(services.APIKeyRequestKey, key)
+	e.Set(apiKeyUserKey, user)
+
+	if !isNotesRecordsRequest(e) {
+		return e.Next()
+	}
+
+	scope := services.APIKeyScopeNotesWrite
+	if e.Request.Method == http.MethodGet || e.Request.Method == http.MethodHead {
+		scope = services.APIKeyScopeNotesRead
+	}
+	if !services.HasAPIKeyScope(key, scope) {
+		return e.ForbiddenError(fmt.Sprintf("This API key is missing the %s scope.", scope), nil)
+	}
+
+	// Lists only return notes of the key's workspace, single records are checked by the API key hooks
+	if e.Request.Method == http.MethodGet && e.Request.PathValue("id") == "" {
+		query := e.Request.URL.Query()
+		filter, err := workspaceNotesFilter(key.GetString("workspace"), query.Get("filter"))
+		if err != nil {
+			return e.BadRequestError("Invalid filter.", err)
+		}
+		query.Set("filter", filter)
+		e.Request.URL.RawQuery = query.Encode()
+	}
+
+	e.Auth = user
+	return e.Next()
+}
+
+// RequireAPIKeyScope lets a custom route accept API keys with all of the scopes for the workspace in its path
+// Requests without an API key are passed through unchanged
+func RequireAPIKeyScope(scopes ...string) func(e *core.RequestEvent) error {
+	return func(e *core.RequestEvent) error {
+		key, ok := e.Get(services.APIKeyRequestKey).(*core.Record)
+		if !ok {
+			return e.Next()
+		}
+
+		if key.GetString("workspace") != e.Request.PathValue("id") {
+			return e.ForbiddenError("This API key belongs to another workspace.", nil)
+		}
+		for _, scope := range scopes {
+			if !services.HasAPIKeyScope(key, scope) {
+				return e.ForbiddenError(fmt.Sprintf("This API key is missing the %s scope.", scope), nil)
+			}
+		}
+
+		e.Set(helpers.APIKeyUserRequestKey, e.Get(apiKeyUserKey))
+		return e.Next()
+	}
+}
+
+// workspaceNotesFilter limits the filter of a notes list request to the notes of a workspace
+// The filter is rejected unless the result parses as the workspace condition and one group joined with &&,
+// so a filter cannot close the group it is wrapped in and escape the workspace condition
+func workspaceNotesFilter(workspaceID, filter string) (string, error) {
+	scoped := fmt.Sprintf("workspace = %q", workspaceID)
+	if strings.TrimSpace(filter) == "" {
+		return scoped, nil
+	}
+
+	scoped += " && (" + filter + ")"
+	groups, err := fexpr.Parse(scoped)
+	if err != nil {
+		return "", err
+	}
+	if len(groups) != 2 || groups[1].Join != fexpr.JoinAnd {
+		return "", errors.New("the filter must be a single expression")
+	}
+
+	return scoped, nil
+}
+
+// apiKeyFromRequest returns the API key sent with a request, if any
+func apiKeyFromRequest(r *http.Request) string {
+	if key := strings.TrimSpace(r.Header.Get("X-API-Key")); key != "" {
+		return key
+	}
+
+	key := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if strings.HasPrefix(key, services.APIKeyPrefix) {
+		return key
+	}
+	return ""
+}
+
+// isNotesRecordsRequest reports whether a request goes to the records API of the notes collection
+func isNotesRecordsRequest(e *core.RequestEvent) bool {
+	collectionParam := e.Request.PathValue("collection")
+	if collectionParam == "" || !strings.Contains(e.Request.URL.Path, "/records") {
+		return false
+	}
+
+	collection, err := e.App.FindCachedCollectionByNameOrId(collectionParam)
+	return err == nil && collection.Name == constants.CollectionNotes
+}
+
+// apiKeyErrorResponse maps an error from the API key service to a response
+func apiKeyErrorResponse(e *core.RequestEvent, err error, fallback string) error {
+	switch {
+	case errors.Is(err, services.ErrAPIKeyName),
+		errors.Is(err, services.ErrAPIKeyScopes),
+		errors.Is(err, services.ErrAPIKeyExpiry):
+		return helpers.JSONBadRequest(e, err.Error())
+	case errors.Is(err, services.ErrAPIKeyLimit), errors.Is(err, services.ErrAPIKeyRevoked):
+		return helpers.JSONError(e, http.StatusConflict, err.Error())
+	}
+
+	log.Printf("Error handling API key: %v", err)
+	return helpers.JSONInternalServerError(e, fallback)
+}
+
+// newAPIKeyResponse converts an API key record to its API representation
+func newAPIKeyResponse(key *core.Record) APIKeyResponse {
+	return APIKeyResponse{
+		ID:         key.Id,
+		Name:       key.GetString("name"),
+		Prefix:     key.GetString("prefix"),
+		Scopes:     key.GetStringSlice("scopes"),
+		CreatedBy:  key.GetString("created_by"),
+		ExpiresAt:  key.GetDateTime("expires_at").String(),
+		LastUsedAt: key.GetDateTime("last_used_at").String(),
+		RevokedAt:  key.GetDateTime("revoked_at").String(),
+		Created:    key.GetDateTime("created").String(),
+	}
+}
@@ -0,0 +1,59 @@
+package routes
+
+import (
+	"testing"
+
+	"github.com/ganigeorgiev/fexpr"
+)
+
+func TestWorkspaceNotesFilter(t *testing.T) {
+	tests := []struct {
+		name    string
+		filter  string
+		wantErr bool
+	}{
+		{name: "empty", filter: ""},
+		{name: "simple", filter: `title ~ "plan"`},
+		{name: "or", filter: `title ~ "a" || title ~ "b"`},
+		{name: "grouped", filter: `(title ~ "a") || (title ~ "b")`},
+		{name: "parentheses in string", filter: `title = "1=1) || (1=1"`},
+		{name: "trailing comment", filter: `(1=1) //`},
+		{name: "group escape", filter: `1=1) || (1=1`, wantErr: true},
+		{name: "group escape with comment", filter: `1=1) || (1=1 //`, wantErr: true},
+		{name: "unclosed group", filter: `(1=1`, wantErr: true},
+		{name: "stray closing parenthesis", filter: `1=1)`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter, err := workspaceNotesFilter("ws1", tt.filter)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected %q to be rejected, got filter %q", tt.filter, filter)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error for %q: %v", tt.filter, err)
+			}
+
+			// The workspace condition must stay a top level condition joined with && to the rest
+			groups, err := fexpr.Parse(filter)
+			if err != nil {
+				t.Fatalf("filter %q does not parse: %v", filter, err)
+			}
+			if tt.filter == "" {
+				if len(groups) != 1 {
+					t.Fatalf("expected only the workspace condition, got %d groups in %q", len(groups), filter)
+				}
+				return
+			}
+			if len(groups) != 2 || groups[1].Join != fexpr.JoinAnd {
+				t.Fatalf("filter %q escapes the workspace condition", filter)
+			}
+			if expr, ok := groups[0].Item.(fexpr.Expr); !ok || expr.Left.Literal != "workspace" || expr.Right.Literal != "ws1" {
+				t.Fatalf("filter %q does not start with the workspace condition", filter)
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package routes
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"pocketvue/helpers"
+	"pocketvue/services"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/router"
+)
+
+// exportForbidden is returned to members who may not export a workspace
+const exportForbidden = "Only workspace owners and admins can export the workspace"
+
+// ExportWorkspace streams a zip archive of a workspace
+// Only owners and admins may export since the archive contains the member list
+func ExportWorkspace(e *core.RequestEvent) error {
+	_, workspace, err := findManagedWorkspace(e, exportForbidden)
+	if err != nil {
+		return err
+	}
+
+	filename := fmt.Sprintf("%s-%s.zip", workspace.GetString("slug"), time.Now().UTC().Format("20060102"))
+	e.Response.Header().Set("Content-Type", "application/zip")
+	e.Response.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	e.Response.WriteHeader(http.StatusOK)
+
+	// Headers are already sent, a failure can only cut the download short
+	if err := services.NewArchiveService(e.App).Export(e.Response, workspace); err != nil {
+		log.Printf("Error exporting workspace %s: %v", workspace.Id, err)
+	}
+	return nil
+}
+
+// ImportWorkspace creates a workspace owned by the authenticated user from an uploaded archive
+// The archive is sent as the "file" field of a multipart form
+func ImportWorkspace(e *core.RequestEvent) error {
+	user, err := helpers.GetAuthenticatedUser(e)
+	if err != nil {
+		return err
+	}
+	if user.GetBool("banned") {
+		return e.ForbiddenError("Your account cannot create workspaces.", nil)
+	}
+
+	file, header, err := e.Request.FormFile("file")
+	if err != nil {
+		return helpers.JSONBadRequest(e, "an archive file is required")
+	}
+	defer file.Close()
+
+	result, err := services.NewArchiveService(e.App).Import(services.AuditContext(e), file, header.Size, user)
+	if err != nil {
+		var apiErr *router.ApiError
+		switch {
+		case errors.Is(err, services.ErrArchiveInvalid), errors.Is(err, services.ErrArchiveUnsupportedVersion):
+			return helpers.JSONBadRequest(e, err.Error())
+		case errors.As(err, &apiErr):
+			return apiErr
+		}
+		log.Printf("Error importing workspace for user %s: %v", user.Id, err)
+		return helpers.JSONInternalServerError(e, "failed to import workspace")
+	}
+
+	return e.JSON(http.StatusCreated, result)
+}
@@ -0,0 +1,181 @@
+package routes
+
+import (
+	"fmt"
+	"log"
+	"pocketvue/constants"
+	"pocketvue/helpers"
+	"strings"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// viewAuditForbidden is returned to members who may not read the audit log
+const viewAuditForbidden = "Only workspace owners and admins can view the audit log"
+
+const (
+	// auditDefaultPerPage is the number of audit entries returned per page by default
+	auditDefaultPerPage = 50
+
+	// auditMaxPerPage is the maximum number of audit entries returned per page
+	auditMaxPerPage = 200
+)
+
+// AuditActorResponse represents the user behind an audit entry
+// ID is empty when the user was deleted or the change was made by a superuser
+type AuditActorResponse struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Email string `json:"email"`
+}
+
+// AuditLogResponse represents an audit log entry, Actor is null for changes made by the system
+type AuditLogResponse struct {
+	ID         string              `json:"id"`
+	Action     string              `json:"action"`
+	TargetType string              `json:"target_type"`
+	TargetID   string              `json:"target_id"`
+	Actor      *AuditActorResponse `json:"actor"`
+	IP         string              `json:"ip"`
+	UserAgent  string              `json:"user_agent"`
+	Changes    types.JSONRaw       `json:"changes"`
+	Created    string              `json:"created"`
+}
+
+// ListWorkspaceAudit lists the audit log of a workspace, newest first
+// Entries can be filtered with the action (comma separated), target_type, target_id, actor, from and to query parameters
+func ListWorkspaceAudit(e *core.RequestEvent) error {
+	_, workspace, err := findManagedWorkspace(e, viewAuditForbidden)
+	if err != nil {
+		return err
+	}
+
+	filters, err := auditFilters(e, workspace.Id)
+	if err != nil {
+		return helpers.JSONBadRequest(e, err.Error())
+	}
+	pagination := helpers.GetPagination(e, auditDefaultPerPage, auditMaxPerPage)
+
+	var total int
+	if err := e.App.RecordQuery(constants.CollectionAuditLogs).
+		Select("COUNT(*)").
+		AndWhere(filters).
+		Row(&total); err != nil {
+		log.Printf("Error counting audit log of workspace %s: %v", workspace.Id, err)
+		return helpers.JSONInternalServerError(e, "failed to list audit log")
+	}
+
+	var entries []*core.Record
+	if err := e.App.RecordQuery(constants.CollectionAuditLogs).
+		AndWhere(filters).
+		OrderBy("created DESC", "id DESC").
+		Limit(int64(pagination.PerPage)).
+		Offset(int64(pagination.Offset())).
+		All(&entries); err != nil {
+		log.Printf("Error listing audit log of workspace %s: %v", workspace.Id, err)
+		return helpers.JSONInternalServerError(e, "failed to list audit log")
+	}
+
+	actors := findAuditActors(e.App, entries)
+	items := make([]AuditLogResponse, 0, len(entries))
+	for _, entry := range entries {
+		items = append(items, newAuditLogResponse(entry, actors))
+	}
+
+	return helpers.JSONSuccess(e, helpers.NewListResult(pagination, items, total))
+}
+
+// auditFilters builds the conditions of an audit log query from the query parameters
+func auditFilters(e *core.RequestEvent, workspaceID string) (dbx.Expression, error) {
+	query := e.Request.URL.Query()
+	conditions := []dbx.Expression{dbx.HashExp{"workspace": workspaceID}}
+
+	if action := query.Get("action"); action != "" {
+		actions := []any{}
+		for _, value := range strings.Split(action, ",") {
+			if value = strings.TrimSpace(value); value != "" {
+				actions = append(actions, value)
+			}
+		}
+		conditions = append(conditions, dbx.In("action", actions...))
+	}
+
+	for _, param := range []string{"target_type", "target_id", "actor"} {
+		if value := query.Get(param); value != "" {
+			conditions = append(conditions, dbx.HashExp{param: value})
+		}
+	}
+
+	for param, operator := range map[string]string{"from": ">=", "to": "<="} {
+		value := query.Get(param)
+		if value == "" {
+			continue
+		}
+		date, err := types.ParseDateTime(value)
+		if err != nil || date.IsZero() {
+			return nil, fmt.Errorf("%s must be a valid date", param)
+		}
+		conditions = append(conditions, dbx.NewExp(
+			"created "+operator+" {:"+param+"}",
+			dbx.Params{param: date.String()},
+		))
+	}
+
+	return dbx.And(conditions...), nil
+}
+
+// findAuditActors loads the users behind audit entries, keyed by ID
+func findAuditActors(app core.App, entries []*core.Record) map[string]*core.Record {
+	ids := []string{}
+	for _, entry := range entries {
+		if actor := entry.GetString("actor"); actor != "" {
+			ids = append(ids, actor)
+		}
+	}
+
+	actors := map[string]*core.Record{}
+	if len(ids) == 0 {
+		return actors
+	}
+
+	users, err := app.FindRecordsByIds(constants.CollectionUsers, ids)
+	if err != nil {
+		log.Printf("Error loading audit log actors: %v", err)
+		return actors
+	}
+	for _, user := range users {
+		actors[user.Id] = user
+	}
+	return actors
+}
+
+// newAuditLogResponse converts an audit log record to its API representation
+func newAuditLogResponse(entry *core.Record, actors map[string]*core.Record) AuditLogResponse {
+	response := AuditLogResponse{
+		ID:         entry.Id,
+		Action:     entry.GetString("action"),
+		TargetType: entry.GetString("target_type"),
+		TargetID:   entry.GetString("target_id"),
+		IP:         entry.GetString("ip"),
+		UserAgent:  entry.GetString("user_agent"),
+		Created:    entry.GetDateTime("created").String(),
+	}
+
+	if changes, ok := entry.Get("changes").(types.JSONRaw); ok {
+		response.Changes = changes
+	}
+
+	if user, ok := actors[entry.GetString("actor")]; ok {
+		response.Actor = &AuditActorResponse{
+			ID:    user.Id,
+			Name:  user.GetString("name"),
+			Email: user.Email(),
+		}
+	} else if email := entry.GetString("actor_email"); email != "" {
+		response.Actor = &AuditActorResponse{Email: email}
+	}
+
+	return response
+}
@@ -2,7 +2,10 @@ package routes
 
 import (
 	"encoding/json"
+	"errors"
 	"log"
+	"net/http"
+	"pocketvue/constants"
 	"pocketvue/helpers"
 	"pocketvue/services"
 
@@ -14,13 +17,23 @@ type CreateCheckoutRequest struct {
 	Products      []string `json:"products"`
 	WorkspaceSlug string   `json:"workspace_slug"` // Optional: for workspace-specific success URL
 	ReturnPath    string   `json:"return_path"`    // Optional: custom return path (defaults to /dashboard)
+	Seats         int      `json:"seats"`          // Optional: number of seats for seat-based plans
 }
 
 // CreateCheckoutResponse represents the response for a successful checkout creation
 type CreateCheckoutResponse struct {
+	ID  string `json:"id"`
 	URL string `json:"url"`
 }
 
+// CheckoutStatusResponse represents the tracked status of a checkout
+type CheckoutStatusResponse struct {
+	ID        string `json:"id"`
+	Status    string `json:"status"`
+	Completed bool   `json:"completed"`
+	ExpiresAt string `json:"expires_at,omitempty"`
+}
+
 // CreateCustomerPortalRequest represents the request body for creating a customer portal session
 type CreateCustomerPortalRequest struct {
 	WorkspaceSlug string `json:"workspace_slug"` // Optional: for workspace-specific return URL
@@ -51,6 +64,9 @@ func CreateCheckoutSession(e *core.RequestEvent) error {
 	if len(req.Products) == 0 {
 		return helpers.JSONBadRequest(e, "products field is required and must contain at least one product ID")
 	}
+	if req.Seats < 0 {
+		return helpers.JSONBadRequest(e, "seats must be a positive number")
+	}
 
 	// Validate FrontendURL is configured before building checkout URLs
 	if err := helpers.ValidateFrontendURL(); err != nil {
@@ -70,25 +86,79 @@ func CreateCheckoutSession(e *core.RequestEvent) error {
 	log.Printf("CreateCheckoutSession called by user: ID=%s, Email=%s, Products=%v",
 		userID, userEmail, req.Products)
 
-	// Create Polar service and checkout session
+	// Link the checkout to the workspace it was started from, if the user manages its billing
+	workspaceID := ""
+	if req.WorkspaceSlug != "" {
+		workspace, err := helpers.FindWorkspaceBySlug(e.App, req.WorkspaceSlug)
+		if err == nil && helpers.HasWorkspaceRole(e.App, workspace.Id, userID, constants.WorkspaceRoleAdmin) {
+			workspaceID = workspace.Id
+		}
+	}
+
+	// Create Polar service and make sure the user has a Polar customer
+	// A failure here is not fatal, Polar links the customer by external ID during checkout
 	polarService := services.NewPolarService()
-	checkoutURL, err := polarService.CreateCheckoutSession(
-		req.Products,
-		successURL,
-		returnURL,
-		userID,
-		userEmail,
-		userName,
-	)
+	customerID, err := polarService.EnsureCustomer(e.Request.Context(), e.App, user)
+	if err != nil {
+		log.Printf("Warning: failed to ensure Polar customer for user %s: %v", userID, err)
+		// No point in trying the checkout when Polar is down or the client went away
+		if errors.Is(err, services.ErrPolarUnavailable) || e.Request.Context().Err() != nil {
+			return polarErrorResponse(e, err, "failed to create checkout session")
+		}
+	}
+
+	checkoutParams := services.CheckoutParams{
+		ProductIDs:  req.Products,
+		SuccessURL:  successURL,
+		ReturnURL:   returnURL,
+		WorkspaceID: workspaceID,
+		Seats:       req.Seats,
+		CustomerID:  customerID,
+		UserID:      userID,
+		UserEmail:   userEmail,
+		UserName:    userName,
+	}
+	session, err := polarService.CreateCheckoutSession(e.Request.Context(), checkoutParams)
 
 	if err != nil {
 		log.Printf("Error creating checkout session for user %s: %v", userID, err)
-		return helpers.JSONInternalServerError(e, "failed to create checkout session")
+		return polarErrorResponse(e, err, "failed to create checkout session")
+	}
+
+	// Track the checkout so its outcome can be polled, failing to do so must not block the payment
+	if err := services.NewCheckoutService(e.App).Track(session, checkoutParams); err != nil {
+		log.Printf("Warning: failed to track checkout %s: %v", session.ID, err)
 	}
 
 	// Return checkout URL
 	return helpers.JSONSuccess(e, CreateCheckoutResponse{
-		URL: checkoutURL,
+		ID:  session.ID,
+		URL: session.URL,
+	})
+}
+
+// GetCheckoutStatus returns the tracked status of a checkout started by the authenticated user
+// The success page polls it until the checkout is confirmed by the checkout.updated webhook
+func GetCheckoutStatus(e *core.RequestEvent) error {
+	// Get authenticated user
+	user, err := helpers.GetAuthenticatedUser(e)
+	if err != nil {
+		return err
+	}
+
+	checkoutID := e.Request.PathValue("id")
+	checkout, err := e.App.FindRecordById(constants.CollectionCheckouts, checkoutID)
+	if err != nil || checkout.GetString("user") != user.Id {
+		return helpers.JSONNotFound(e, "checkout not found")
+	}
+
+	status := services.EffectiveCheckoutStatus(checkout)
+
+	return helpers.JSONSuccess(e, CheckoutStatusResponse{
+		ID:        checkout.Id,
+		Status:    status,
+		Completed: status == services.CheckoutStatusSucceeded,
+		ExpiresAt: checkout.GetDateTime("expires_at").String(),
 	})
 }
 
@@ -121,13 +191,19 @@ func CreateCustomerPortalSession(e *core.RequestEvent) error {
 
 	log.Printf("CreateCustomerPortalSession called by user: ID=%s", userID)
 
-	// Create Polar service and customer portal session
+	// Create Polar service, provision the customer for legacy users and create the portal session
 	polarService := services.NewPolarService()
-	portalURL, err := polarService.CreateCustomerSession(userID, returnURL)
+	customerID, err := polarService.EnsureCustomer(e.Request.Context(), e.App, user)
+	if err != nil {
+		log.Printf("Error ensuring Polar customer for user %s: %v", userID, err)
+		return polarErrorResponse(e, err, "failed to create customer portal session")
+	}
+
+	portalURL, err := polarService.CreateCustomerSession(e.Request.Context(), customerID, returnURL)
 
 	if err != nil {
 		log.Printf("Error creating customer portal session for user %s: %v", userID, err)
-		return helpers.JSONInternalServerError(e, "failed to create customer portal session")
+		return polarErrorResponse(e, err, "failed to create customer portal session")
 	}
 
 	// Return portal URL
@@ -135,3 +211,28 @@ func CreateCustomerPortalSession(e *core.RequestEvent) error {
 		URL: portalURL,
 	})
 }
+
+// polarErrorResponse maps an error from the Polar service to a response
+// An unreachable provider is a 503 the client can retry, an unusable provider response is a 502
+// and invalid input is a 400
+func polarErrorResponse(e *core.RequestEvent, err error, fallback string) error {
+	if errors.Is(err, services.ErrPolarUnavailable) {
+		e.Response.Header().Set("Retry-After", "30")
+		return helpers.JSONErrorWithMessage(e, http.StatusServiceUnavailable, "payment provider unavailable", "the payment provider is not reachable right now, please try again later")
+	}
+	if errors.Is(err, services.ErrPolarEmptyResponse) {
+		return helpers.JSONErrorWithMessage(e, http.StatusBadGateway, "payment provider error", "the payment provider returned an invalid response, please try again later")
+	}
+
+	var checkoutErr *services.CheckoutError
+	if errors.As(err, &checkoutErr) {
+		if checkoutErr.Err == nil {
+			return helpers.JSONBadRequest(e, checkoutErr.Message)
+		}
+		if services.IsPolarBadRequest(checkoutErr.Err) {
+			return helpers.JSONErrorWithMessage(e, http.StatusBadRequest, "invalid request", "the payment provider rejected the request, please check the selected products")
+		}
+	}
+
+	return helpers.JSONInternalServerError(e, fallback)
+}
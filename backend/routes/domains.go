@@ -0,0 +1,140 @@
+package routes
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"pocketvue/helpers"
+	"pocketvue/services"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// CustomDomainWorkspaceKey is the request store key of the workspace resolved from a custom domain
+const CustomDomainWorkspaceKey = "customDomainWorkspace"
+
+// manageDomainForbidden is returned to members who may not manage the custom domain
+const manageDomainForbidden = "Only workspace owners and admins can manage the custom domain"
+
+// SetDomainRequest represents the request body for setting a custom domain
+type SetDomainRequest struct {
+	Domain string `json:"domain"`
+}
+
+// CurrentDomainResponse represents the workspace served under the requested host
+type CurrentDomainResponse struct {
+	WorkspaceID   string `json:"workspace_id"`
+	WorkspaceSlug string `json:"workspace_slug"`
+	WorkspaceName string `json:"workspace_name"`
+}
+
+// GetWorkspaceDomain returns the custom domain of a workspace and the TXT record that verifies it
+func GetWorkspaceDomain(e *core.RequestEvent) error {
+	_, workspace, err := findManagedWorkspace(e, manageDomainForbidden)
+	if err != nil {
+		return err
+	}
+
+	return helpers.JSONSuccess(e, services.NewDomainService(e.App).Verification(workspace))
+}
+
+// SetWorkspaceDomain assigns a custom domain to a workspace and issues a new DNS TXT verification token
+func SetWorkspaceDomain(e *core.RequestEvent) error {
+	_, workspace, err := findManagedWorkspace(e, manageDomainForbidden)
+	if err != nil {
+		return err
+	}
+
+	var req SetDomainRequest
+	if err := json.NewDecoder(e.Request.Body).Decode(&req); err != nil {
+		log.Printf("Error parsing domain request: %v", err)
+		return helpers.JSONBadRequest(e, "invalid request body")
+	}
+
+	domainService := services.NewDomainService(e.App)
+	if err := domainService.SetDomain(services.AuditContext(e), workspace, req.Domain); err != nil {
+		return domainErrorResponse(e, err, "failed to set domain")
+	}
+
+	return helpers.JSONSuccess(e, domainService.Verification(workspace))
+}
+
+// VerifyWorkspaceDomain checks the DNS TXT record of the workspace custom domain
+func VerifyWorkspaceDomain(e *core.RequestEvent) error {
+	_, workspace, err := findManagedWorkspace(e, manageDomainForbidden)
+	if err != nil {
+		return err
+	}
+
+	domainService := services.NewDomainService(e.App)
+	if err := domainService.Verify(services.AuditContext(e), workspace); err != nil {
+		return domainErrorResponse(e, err, "failed to verify domain")
+	}
+
+	return helpers.JSONSuccess(e, domainService.Verification(workspace))
+}
+
+// DeleteWorkspaceDomain removes the custom domain of a workspace
+func DeleteWorkspaceDomain(e *core.RequestEvent) error {
+	_, workspace, err := findManagedWorkspace(e, manageDomainForbidden)
+	if err != nil {
+		return err
+	}
+
+	if err := services.NewDomainService(e.App).RemoveDomain(services.AuditContext(e), workspace); err != nil {
+		return domainErrorResponse(e, err, "failed to remove domain")
+	}
+
+	return e.NoContent(http.StatusNoContent)
+}
+
+// GetCurrentDomain returns the workspace served under the request host
+// It is public so the SPA can find its workspace before the user logs in
+func GetCurrentDomain(e *core.RequestEvent) error {
+	workspace, _ := e.Get(CustomDomainWorkspaceKey).(*core.Record)
+	if workspace == nil {
+		return helpers.JSONNotFound(e, "no workspace uses this domain")
+	}
+
+	return helpers.JSONSuccess(e, CurrentDomainResponse{
+		WorkspaceID:   workspace.Id,
+		WorkspaceSlug: workspace.GetString("slug"),
+		WorkspaceName: workspace.GetString("name"),
+	})
+}
+
+// ResolveCustomDomain is a router middleware that resolves the request Host to the workspace
+// with that verified custom domain and stores it under CustomDomainWorkspaceKey
+// The SPA is served unchanged under every host, it asks GetCurrentDomain which workspace to open
+func ResolveCustomDomain(e *core.RequestEvent) error {
+	workspace, err := services.NewDomainService(e.App).FindWorkspaceByHost(e.Request.Host)
+	if err != nil {
+		log.Printf("Error resolving custom domain %s: %v", e.Request.Host, err)
+		return e.Next()
+	}
+	if workspace == nil {
+		return e.Next()
+	}
+
+	e.Set(CustomDomainWorkspaceKey, workspace)
+	return e.Next()
+}
+
+// domainErrorResponse maps an error from the domain service to a response
+func domainErrorResponse(e *core.RequestEvent, err error, fallback string) error {
+	switch {
+	case errors.Is(err, services.ErrInvalidDomain),
+		errors.Is(err, services.ErrDomainNotConfigured),
+		errors.Is(err, services.ErrDomainVerificationFailed):
+		return helpers.JSONBadRequest(e, err.Error())
+	case errors.Is(err, services.ErrDomainTaken):
+		return helpers.JSONError(e, http.StatusConflict, err.Error())
+	case errors.Is(err, services.ErrDomainLookupFailed):
+		log.Printf("Error verifying domain: %v", err)
+		return helpers.JSONError(e, http.StatusServiceUnavailable, services.ErrDomainLookupFailed.Error())
+	}
+
+	log.Printf("Error handling domain: %v", err)
+	return helpers.JSONInternalServerError(e, fallback)
+}
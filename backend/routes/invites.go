@@ -0,0 +1,318 @@
+package routes
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"math"
+	"net/http"
+	"pocketvue/constants"
+	"pocketvue/helpers"
+	"pocketvue/services"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/router"
+)
+
+// CreateInviteRequest represents the request body for inviting a user to a workspace
+type CreateInviteRequest struct {
+	Email string `json:"email"`
+	Role  string `json:"role"`
+}
+
+// AcceptInviteRequest represents the request body for accepting an invite
+type AcceptInviteRequest struct {
+	Token string `json:"token"`
+}
+
+// InviteResponse represents a workspace invite
+type InviteResponse struct {
+	ID        string `json:"id"`
+	Email     string `json:"email"`
+	Role      string `json:"role"`
+	Status    string `json:"status"`
+	InvitedBy string `json:"invited_by"`
+	ExpiresAt string `json:"expires_at"`
+	SentAt    string `json:"sent_at"`
+	Expired   bool   `json:"expired"`
+}
+
+// InvitePreviewResponse represents the public details of an invite shown before accepting it
+type InvitePreviewResponse struct {
+	WorkspaceName string `json:"workspace_name"`
+	WorkspaceSlug string `json:"workspace_slug"`
+	Email         string `json:"email"`
+	Role          string `json:"role"`
+	InvitedBy     string `json:"invited_by"`
+	ExpiresAt     string `json:"expires_at"`
+}
+
+// AcceptInviteResponse represents the membership created by accepting an invite
+type AcceptInviteResponse struct {
+	WorkspaceID   string `json:"workspace_id"`
+	WorkspaceSlug string `json:"workspace_slug"`
+	Role          string `json:"role"`
+}
+
+// manageInvitesForbidden is returned to members who may not manage invites
+const manageInvitesForbidden = "Only workspace owners and admins can manage invites"
+
+// invitableRoles lists the roles an invite can grant, ownership is never granted by invite
+var invitableRoles = []string{
+	constants.WorkspaceRoleAdmin,
+	constants.WorkspaceRoleMember,
+	constants.WorkspaceRoleViewer,
+}
+
+// CreateWorkspaceInvite invites a user by email to a workspace
+// Only owners and admins of the workspace may invite
+func CreateWorkspaceInvite(e *core.RequestEvent) error {
+	user, workspace, err := findManagedWorkspace(e, manageInvitesForbidden)
+	if err != nil {
+		return err
+	}
+
+	var req CreateInviteRequest
+	if err := json.NewDecoder(e.Request.Body).Decode(&req); err != nil {
+		log.Printf("Error parsing invite request: %v", err)
+		return helpers.JSONBadRequest(e, "invalid request body")
+	}
+
+	req.Email = strings.TrimSpace(req.Email)
+	if req.Email == "" || !strings.Contains(req.Email, "@") {
+		return helpers.JSONBadRequest(e, "a valid email is required")
+	}
+	if req.Role == "" {
+		req.Role = constants.WorkspaceRoleMember
+	}
+	if !isInvitableRole(req.Role) {
+		return helpers.JSONBadRequest(e, "role must be one of admin, member or viewer")
+	}
+
+	invite, err := services.NewInviteService(e.App).Invite(workspace, user, req.Email, req.Role)
+	if err != nil {
+		return inviteErrorResponse(e, err, "failed to send invite")
+	}
+
+	return e.JSON(http.StatusCreated, newInviteResponse(invite))
+}
+
+// ListWorkspaceInvites lists the pending invites of a workspace
+func ListWorkspaceInvites(e *core.RequestEvent) error {
+	_, workspace, err := findManagedWorkspace(e, manageInvitesForbidden)
+	if err != nil {
+		return err
+	}
+
+	invites, err := e.App.FindRecordsByFilter(
+		constants.CollectionWorkspaceInvites,
+		"workspace = {:workspace} && status = {:status}",
+		"-sent_at",
+		0,
+		0,
+		dbx.Params{"workspace": workspace.Id, "status": services.InviteStatusPending},
+	)
+	if err != nil {
+		log.Printf("Error listing invites of workspace %s: %v", workspace.Id, err)
+		return helpers.JSONInternalServerError(e, "failed to list invites")
+	}
+
+	response := make([]InviteResponse, 0, len(invites))
+	for _, invite := range invites {
+		response = append(response, newInviteResponse(invite))
+	}
+
+	return helpers.JSONSuccess(e, response)
+}
+
+// ResendWorkspaceInvite emails a pending invite again with a fresh link
+func ResendWorkspaceInvite(e *core.RequestEvent) error {
+	user, workspace, err := findManagedWorkspace(e, manageInvitesForbidden)
+	if err != nil {
+		return err
+	}
+
+	invite, err := findWorkspaceInvite(e, workspace)
+	if err != nil {
+		return err
+	}
+
+	if err := services.NewInviteService(e.App).Resend(invite, workspace, user); err != nil {
+		return inviteErrorResponse(e, err, "failed to resend invite")
+	}
+
+	return helpers.JSONSuccess(e, newInviteResponse(invite))
+}
+
+// RevokeWorkspaceInvite cancels a pending invite
+func RevokeWorkspaceInvite(e *core.RequestEvent) error {
+	_, workspace, err := findManagedWorkspace(e, manageInvitesForbidden)
+	if err != nil {
+		return err
+	}
+
+	invite, err := findWorkspaceInvite(e, workspace)
+	if err != nil {
+		return err
+	}
+
+	if err := services.NewInviteService(e.App).Revoke(invite); err != nil {
+		return inviteErrorResponse(e, err, "failed to revoke invite")
+	}
+
+	return e.NoContent(http.StatusNoContent)
+}
+
+// PreviewInvite returns the details of an invite from its token
+// It does not require authentication so invitees without an account know which email to sign up with
+func PreviewInvite(e *core.RequestEvent) error {
+	invite, err := services.NewInviteService(e.App).FindByToken(e.Request.URL.Query().Get("token"))
+	if err != nil {
+		return inviteErrorResponse(e, err, "failed to load invite")
+	}
+
+	workspace, err := e.App.FindRecordById(constants.CollectionWorkspaces, invite.GetString("workspace"))
+	if err != nil {
+		return helpers.JSONNotFound(e, "workspace not found")
+	}
+
+	invitedBy := ""
+	if inviter, err := e.App.FindRecordById(constants.CollectionUsers, invite.GetString("invited_by")); err == nil {
+		invitedBy = inviter.GetString("name")
+	}
+
+	return helpers.JSONSuccess(e, InvitePreviewResponse{
+		WorkspaceName: workspace.GetString("name"),
+		WorkspaceSlug: workspace.GetString("slug"),
+		Email:         invite.GetString("email"),
+		Role:          invite.GetString("role"),
+		InvitedBy:     invitedBy,
+		ExpiresAt:     invite.GetDateTime("expires_at").String(),
+	})
+}
+
+// AcceptInvite adds the authenticated user to the workspace of an invite
+func AcceptInvite(e *core.RequestEvent) error {
+	user, err := helpers.GetAuthenticatedUser(e)
+	if err != nil {
+		return err
+	}
+
+	var req AcceptInviteRequest
+	if err := json.NewDecoder(e.Request.Body).Decode(&req); err != nil {
+		log.Printf("Error parsing accept invite request: %v", err)
+		return helpers.JSONBadRequest(e, "invalid request body")
+	}
+	if req.Token == "" {
+		return helpers.JSONBadRequest(e, "token is required")
+	}
+
+	member, err := services.NewInviteService(e.App).Accept(services.AuditContext(e), req.Token, user)
+	if err != nil {
+		return inviteErrorResponse(e, err, "failed to accept invite")
+	}
+
+	workspace, err := e.App.FindRecordById(constants.CollectionWorkspaces, member.GetString("workspace"))
+	if err != nil {
+		return helpers.JSONNotFound(e, "workspace not found")
+	}
+
+	return helpers.JSONSuccess(e, AcceptInviteResponse{
+		WorkspaceID:   workspace.Id,
+		WorkspaceSlug: workspace.GetString("slug"),
+		Role:          member.GetString("role"),
+	})
+}
+
+// findMemberWorkspace returns the authenticated user and the workspace from the path
+// if the user is a member of it, workspaces of other users are not found
+func findMemberWorkspace(e *core.RequestEvent) (*core.Record, *core.Record, error) {
+	user, err := helpers.GetAuthenticatedUser(e)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	workspace, err := e.App.FindRecordById(constants.CollectionWorkspaces, e.Request.PathValue("id"))
+	if err != nil || helpers.IsWorkspaceDeleted(workspace) ||
+		!helpers.HasWorkspaceRole(e.App, workspace.Id, user.Id, constants.WorkspaceRoleViewer) {
+		return nil, nil, e.NotFoundError("Workspace not found.", nil)
+	}
+
+	return user, workspace, nil
+}
+
+// findManagedWorkspace returns the authenticated user and the workspace from the path
+// if the user is an owner or admin of it, other members get forbiddenMessage
+func findManagedWorkspace(e *core.RequestEvent, forbiddenMessage string) (*core.Record, *core.Record, error) {
+	user, workspace, err := findMemberWorkspace(e)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if !helpers.HasWorkspaceRole(e.App, workspace.Id, user.Id, constants.WorkspaceRoleAdmin) {
+		return nil, nil, e.ForbiddenError(forbiddenMessage, nil)
+	}
+
+	return user, workspace, nil
+}
+
+// findWorkspaceInvite returns the invite from the path if it belongs to the workspace
+func findWorkspaceInvite(e *core.RequestEvent, workspace *core.Record) (*core.Record, error) {
+	invite, err := e.App.FindRecordById(constants.CollectionWorkspaceInvites, e.Request.PathValue("inviteId"))
+	if err != nil || invite.GetString("workspace") != workspace.Id {
+		return nil, e.NotFoundError("Invite not found.", nil)
+	}
+	return invite, nil
+}
+
+// inviteErrorResponse maps an error from the invite service to a response
+func inviteErrorResponse(e *core.RequestEvent, err error, fallback string) error {
+	var rateLimitErr *services.InviteRateLimitError
+	var apiErr *router.ApiError
+
+	switch {
+	case errors.As(err, &rateLimitErr):
+		e.Response.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(rateLimitErr.RetryAfter.Seconds()))))
+		return helpers.JSONErrorWithMessage(e, http.StatusTooManyRequests, "too many invites", rateLimitErr.Error())
+	case errors.Is(err, services.ErrInviteInvalid):
+		return helpers.JSONNotFound(e, err.Error())
+	case errors.Is(err, services.ErrInviteEmailMismatch):
+		return e.ForbiddenError("This invite was sent to a different email address. Sign in with the invited email to accept it.", nil)
+	case errors.Is(err, services.ErrAlreadyMember), errors.Is(err, services.ErrAlreadyInvited):
+		return helpers.JSONError(e, http.StatusConflict, err.Error())
+	case errors.As(err, &apiErr):
+		// Errors from record hooks, e.g. the seat limit when accepting an invite
+		return apiErr
+	}
+
+	log.Printf("Error handling invite: %v", err)
+	return helpers.JSONInternalServerError(e, fallback)
+}
+
+// newInviteResponse converts an invite record to its API representation
+func newInviteResponse(invite *core.Record) InviteResponse {
+	return InviteResponse{
+		ID:        invite.Id,
+		Email:     invite.GetString("email"),
+		Role:      invite.GetString("role"),
+		Status:    invite.GetString("status"),
+		InvitedBy: invite.GetString("invited_by"),
+		ExpiresAt: invite.GetDateTime("expires_at").String(),
+		SentAt:    invite.GetDateTime("sent_at").String(),
+		Expired:   !invite.GetDateTime("expires_at").Time().After(time.Now()),
+	}
+}
+
+// isInvitableRole reports whether an invite may grant the role
+func isInvitableRole(role string) bool {
+	for _, r := range invitableRoles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
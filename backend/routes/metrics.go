@@ -0,0 +1,147 @@
+package routes
+
+import (
+	"encoding/csv"
+	"fmt"
+	"log"
+	"net/http"
+	"pocketvue/helpers"
+	"pocketvue/services"
+	"pocketvue/types"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// GetBillingMetrics returns MRR, subscription and payment metrics for superusers
+// Query parameters:
+//   - from, to: date range (YYYY-MM-DD or RFC3339), defaults to the last 12 months
+//     A date-only "to" includes the whole day
+//   - interval: day, week or month (default month)
+//   - format: json (default) or csv
+func GetBillingMetrics(e *core.RequestEvent) error {
+	if _, err := helpers.GetAuthenticatedSuperuser(e); err != nil {
+		return err
+	}
+
+	query := e.Request.URL.Query()
+
+	now := time.Now().UTC()
+	to, err := parseMetricsDate(query.Get("to"), now, true)
+	if err != nil {
+		return helpers.JSONBadRequest(e, "invalid to date, expected YYYY-MM-DD or RFC3339")
+	}
+	defaultFrom := time.Date(to.Year(), to.Month(), 1, 0, 0, 0, 0, time.UTC).AddDate(0, -11, 0)
+	from, err := parseMetricsDate(query.Get("from"), defaultFrom, false)
+	if err != nil {
+		return helpers.JSONBadRequest(e, "invalid from date, expected YYYY-MM-DD or RFC3339")
+	}
+
+	interval := query.Get("interval")
+	if interval == "" {
+		interval = services.MetricsIntervalMonth
+	}
+
+	metrics, err := services.NewMetricsService(e.App).ComputeBillingMetrics(from, to, interval)
+	if err != nil {
+		return helpers.JSONBadRequest(e, err.Error())
+	}
+
+	if query.Get("format") == "csv" {
+		return writeBillingMetricsCSV(e, metrics)
+	}
+
+	return helpers.JSONSuccess(e, metrics)
+}
+
+// parseMetricsDate parses a date query parameter, returning the fallback when empty
+// endOfDay moves date-only values to the start of the next day so the range includes them
+func parseMetricsDate(value string, fallback time.Time, endOfDay bool) (time.Time, error) {
+	if value == "" {
+		return fallback, nil
+	}
+	if t, err := time.Parse(time.DateOnly, value); err == nil {
+		if endOfDay {
+			t = t.AddDate(0, 0, 1)
+		}
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return t.UTC(), nil
+}
+
+// writeBillingMetricsCSV writes the per period metrics as a CSV download
+// MRR and revenue get one column per currency
+func writeBillingMetricsCSV(e *core.RequestEvent, metrics *types.BillingMetrics) error {
+	currencySet := map[string]bool{}
+	for _, period := range metrics.Periods {
+		for currency := range period.MRR {
+			currencySet[currency] = true
+		}
+		for currency := range period.Revenue {
+			currencySet[currency] = true
+		}
+	}
+	currencies := make([]string, 0, len(currencySet))
+	for currency := range currencySet {
+		currencies = append(currencies, currency)
+	}
+	sort.Strings(currencies)
+
+	header := []string{
+		"period_start",
+		"period_end",
+		"active_subscriptions",
+		"new_subscriptions",
+		"churned_subscriptions",
+		"trials_started",
+		"trials_converted",
+		"failed_payments",
+	}
+	for _, currency := range currencies {
+		header = append(header, "mrr_"+currency, "revenue_"+currency)
+	}
+
+	fileName := fmt.Sprintf("billing-metrics-%s-%s.csv", metrics.From.Format(time.DateOnly), metrics.To.Format(time.DateOnly))
+	e.Response.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	e.Response.Header().Set("Content-Disposition", "attachment; filename="+fileName)
+	e.Response.WriteHeader(http.StatusOK)
+
+	writer := csv.NewWriter(e.Response)
+	if err := writer.Write(header); err != nil {
+		log.Printf("Error writing billing metrics CSV: %v", err)
+		return nil
+	}
+
+	for _, period := range metrics.Periods {
+		row := []string{
+			period.Start.Format(time.RFC3339),
+			period.End.Format(time.RFC3339),
+			strconv.Itoa(period.ActiveSubscriptions),
+			strconv.Itoa(period.NewSubscriptions),
+			strconv.Itoa(period.ChurnedSubscriptions),
+			strconv.Itoa(period.TrialsStarted),
+			strconv.Itoa(period.TrialsConverted),
+			strconv.Itoa(period.FailedPayments),
+		}
+		for _, currency := range currencies {
+			row = append(row, strconv.Itoa(period.MRR[currency]), strconv.Itoa(period.Revenue[currency]))
+		}
+		if err := writer.Write(row); err != nil {
+			log.Printf("Error writing billing metrics CSV: %v", err)
+			return nil
+		}
+	}
+
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		log.Printf("Error writing billing metrics CSV: %v", err)
+	}
+
+	return nil
+}
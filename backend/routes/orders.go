@@ -0,0 +1,77 @@
+package routes
+
+import (
+	"log"
+	"net/http"
+	"pocketvue/constants"
+	"pocketvue/helpers"
+	"pocketvue/services"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// GetOrderInvoice streams the stored invoice PDF of an order
+// Only the user who placed the order or the billing admin of its workspace may download it
+func GetOrderInvoice(e *core.RequestEvent) error {
+	// Get authenticated user
+	user, err := helpers.GetAuthenticatedUser(e)
+	if err != nil {
+		return err
+	}
+
+	orderID := e.Request.PathValue("id")
+	order, err := e.App.FindRecordById(constants.CollectionOrders, orderID)
+	if err != nil {
+		return helpers.JSONNotFound(e, "order not found")
+	}
+
+	if !canAccessOrder(e.App, user, order) {
+		// Respond with 404 to avoid leaking the existence of other users' orders
+		return helpers.JSONNotFound(e, "order not found")
+	}
+
+	switch order.GetString("invoice_status") {
+	case services.InvoiceStatusReady:
+		// handled below
+	case services.InvoiceStatusFailed:
+		return helpers.JSONErrorWithMessage(e, http.StatusNotFound, "invoice unavailable", "the invoice could not be retrieved from the payment provider")
+	default:
+		e.Response.Header().Set("Retry-After", "60")
+		return helpers.JSONErrorWithMessage(e, http.StatusAccepted, "invoice pending", "the invoice is still being generated, please try again later")
+	}
+
+	fileName := order.GetString("invoice")
+	if fileName == "" {
+		return helpers.JSONNotFound(e, "invoice not found")
+	}
+
+	fsys, err := e.App.NewFilesystem()
+	if err != nil {
+		log.Printf("Error initializing filesystem: %v", err)
+		return helpers.JSONInternalServerError(e, "failed to load invoice")
+	}
+	defer fsys.Close()
+
+	fileKey := order.BaseFilesPath() + "/" + fileName
+	e.Response.Header().Set("Cache-Control", "private, no-store")
+	if err := fsys.Serve(e.Response, e.Request, fileKey, fileName); err != nil {
+		log.Printf("Error serving invoice for order %s: %v", orderID, err)
+		return helpers.JSONNotFound(e, "invoice not found")
+	}
+
+	return nil
+}
+
+// canAccessOrder reports whether the user placed the order or administers the billing of its workspace
+func canAccessOrder(app core.App, user *core.Record, order *core.Record) bool {
+	if order.GetString("user") == user.Id {
+		return true
+	}
+
+	workspaceID := order.GetString("workspace")
+	if workspaceID == "" {
+		return false
+	}
+
+	return helpers.HasWorkspaceRole(app, workspaceID, user.Id, constants.WorkspaceRoleAdmin)
+}
@@ -76,6 +76,9 @@ func HandlePolarWebhook(e *core.RequestEvent) error {
 	case "order.paid":
 		handlerErr = webhookService.HandleOrderPaid(eventData)
 
+	case "checkout.created", "checkout.updated":
+		handlerErr = webhookService.HandleCheckoutUpdated(eventData)
+
 	case "product.created":
 		handlerErr = webhookService.HandleProductCreated(eventData)
 
@@ -1,50 +1,116 @@
 package routes
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"net/http"
 	"pocketvue/constants"
 	"pocketvue/helpers"
 	"pocketvue/types"
+	"strings"
 
+	"github.com/pocketbase/dbx"
 	"github.com/pocketbase/pocketbase/core"
 )
 
-// GetProducts returns all non-archived products
+// productIntervals lists the recurring intervals accepted by the interval filter
+var productIntervals = map[string]bool{
+	"day":   true,
+	"week":  true,
+	"month": true,
+	"year":  true,
+}
+
+// GetProducts returns all non-archived products in display order
+// Query parameters:
+//   - interval: only return products billed per day, week, month or year
+//   - currency: only return products priced in the given currency (e.g. usd)
 func GetProducts(e *core.RequestEvent) error {
-	// Fetch all non-archived products, ordered by created date
-	records, err := helpers.FindAllRecords(e.App, constants.CollectionPolarProducts)
+	query := e.Request.URL.Query()
+
+	filters := []string{"is_archived = false"}
+	params := dbx.Params{}
+
+	if interval := strings.ToLower(query.Get("interval")); interval != "" {
+		if !productIntervals[interval] {
+			return helpers.JSONBadRequest(e, "interval must be one of day, week, month or year")
+		}
+		filters = append(filters, "recurring_interval = {:interval}")
+		params["interval"] = interval
+	}
+
+	if currency := strings.ToLower(query.Get("currency")); currency != "" {
+		filters = append(filters, "price_currency = {:currency}")
+		params["currency"] = currency
+	}
+
+	// Fetch non-archived products, ordered by explicit display order then price
+	records, err := e.App.FindRecordsByFilter(
+		constants.CollectionPolarProducts,
+		strings.Join(filters, " && "),
+		"sort_order,price_amount,created",
+		0,
+		0,
+		params,
+	)
 	if err != nil {
+		log.Printf("Error fetching products: %v", err)
 		return helpers.JSONInternalServerError(e, "failed to fetch products")
 	}
 
-	// Filter out archived products and convert to response structs
-	var activeProducts []types.ProductResponse
+	// Convert records to response structs
+	activeProducts := make([]types.ProductResponse, 0, len(records))
 	for _, record := range records {
-		if !record.GetBool("is_archived") {
-			product := types.ProductResponse{
-				ID:                     record.GetString("id"),
-				Name:                   record.GetString("name"),
-				PriceAmount:            record.GetInt("price_amount"),
-				PriceCurrency:          record.GetString("price_currency"),
-				RecurringInterval:      record.GetString("recurring_interval"),
-				RecurringIntervalCount: record.GetInt("recurring_interval_count"),
-				IsRecurring:            record.GetBool("is_recurring"),
-				PolarPriceID:           record.GetString("polar_price_id"),
-			}
-
-			// Add optional fields if they exist
-			if desc := record.GetString("description"); desc != "" {
-				product.Description = desc
-			}
-			if trialInterval := record.GetString("trial_interval"); trialInterval != "" {
-				product.TrialInterval = trialInterval
-			}
-			if trialIntervalCount := record.GetInt("trial_interval_count"); trialIntervalCount > 0 {
-				product.TrialIntervalCount = trialIntervalCount
-			}
-
-			activeProducts = append(activeProducts, product)
+		product := types.ProductResponse{
+			ID:                     record.GetString("id"),
+			Name:                   record.GetString("name"),
+			PriceAmount:            record.GetInt("price_amount"),
+			PriceCurrency:          record.GetString("price_currency"),
+			RecurringInterval:      record.GetString("recurring_interval"),
+			RecurringIntervalCount: record.GetInt("recurring_interval_count"),
+			IsRecurring:            record.GetBool("is_recurring"),
+			PolarPriceID:           record.GetString("polar_price_id"),
+			SortOrder:              record.GetInt("sort_order"),
+			IsHighlighted:          record.GetBool("is_highlighted"),
+		}
+
+		features, err := helpers.ParseProductFeatures([]byte(record.GetString("features")))
+		if err != nil {
+			log.Printf("Warning: ignoring invalid features of product %s: %v", record.Id, err)
+			features = []types.ProductFeature{}
 		}
+		product.Features = features
+
+		// Add optional fields if they exist
+		if desc := record.GetString("description"); desc != "" {
+			product.Description = desc
+		}
+		if trialInterval := record.GetString("trial_interval"); trialInterval != "" {
+			product.TrialInterval = trialInterval
+		}
+		if trialIntervalCount := record.GetInt("trial_interval_count"); trialIntervalCount > 0 {
+			product.TrialIntervalCount = trialIntervalCount
+		}
+
+		activeProducts = append(activeProducts, product)
+	}
+
+	body, err := json.Marshal(activeProducts)
+	if err != nil {
+		return helpers.JSONInternalServerError(e, "failed to encode products")
+	}
+
+	// Products change rarely, let clients and proxies revalidate with the ETag
+	sum := sha256.Sum256(body)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+	e.Response.Header().Set("ETag", etag)
+	e.Response.Header().Set("Cache-Control", "public, max-age=60, stale-while-revalidate=300")
+
+	if match := e.Request.Header.Get("If-None-Match"); match != "" && strings.Contains(match, etag) {
+		return e.NoContent(http.StatusNotModified)
 	}
 
-	return helpers.JSONSuccess(e, activeProducts)
+	return e.Blob(http.StatusOK, "application/json", body)
 }
@@ -0,0 +1,226 @@
+package routes
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"pocketvue/constants"
+	"pocketvue/helpers"
+	"pocketvue/services"
+	"strings"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/router"
+)
+
+// CreateTransferRequest represents the request body for transferring a workspace
+type CreateTransferRequest struct {
+	Email string `json:"email"`
+}
+
+// AcceptTransferRequest represents the request body for accepting a workspace transfer
+type AcceptTransferRequest struct {
+	Token string `json:"token"`
+}
+
+// TransferResponse represents a pending workspace ownership transfer
+type TransferResponse struct {
+	ID        string `json:"id"`
+	Status    string `json:"status"`
+	ToEmail   string `json:"to_email"`
+	ToName    string `json:"to_name"`
+	ExpiresAt string `json:"expires_at"`
+	Created   string `json:"created"`
+}
+
+// TransferPreviewResponse represents the details of a transfer shown to the recipient before accepting it
+type TransferPreviewResponse struct {
+	WorkspaceName string `json:"workspace_name"`
+	WorkspaceSlug string `json:"workspace_slug"`
+	FromName      string `json:"from_name"`
+	ToEmail       string `json:"to_email"`
+	HasBilling    bool   `json:"has_billing"`
+	ExpiresAt     string `json:"expires_at"`
+}
+
+// AcceptTransferResponse represents the workspace taken over by accepting a transfer
+type AcceptTransferResponse struct {
+	WorkspaceID   string `json:"workspace_id"`
+	WorkspaceSlug string `json:"workspace_slug"`
+}
+
+// transferForbidden is returned to members who may not view transfers
+const transferForbidden = "Only the workspace owner can transfer ownership"
+
+// GetWorkspaceTransfer returns the pending ownership transfer of a workspace, or null
+func GetWorkspaceTransfer(e *core.RequestEvent) error {
+	_, workspace, err := findManagedWorkspace(e, transferForbidden)
+	if err != nil {
+		return err
+	}
+
+	transfer, err := services.NewTransferService(e.App).FindPending(workspace.Id)
+	if err != nil {
+		log.Printf("Error loading transfer of workspace %s: %v", workspace.Id, err)
+		return helpers.JSONInternalServerError(e, "failed to load transfer")
+	}
+	if transfer == nil {
+		return helpers.JSONSuccess(e, nil)
+	}
+
+	return helpers.JSONSuccess(e, newTransferResponse(e.App, transfer))
+}
+
+// CreateWorkspaceTransfer starts transferring a workspace to another user
+// Only the owner may transfer, the recipient must already have an account
+func CreateWorkspaceTransfer(e *core.RequestEvent) error {
+	user, workspace, err := findManagedWorkspace(e, transferForbidden)
+	if err != nil {
+		return err
+	}
+
+	var req CreateTransferRequest
+	if err := json.NewDecoder(e.Request.Body).Decode(&req); err != nil {
+		log.Printf("Error parsing transfer request: %v", err)
+		return helpers.JSONBadRequest(e, "invalid request body")
+	}
+
+	req.Email = strings.TrimSpace(req.Email)
+	if req.Email == "" || !strings.Contains(req.Email, "@") {
+		return helpers.JSONBadRequest(e, "a valid email is required")
+	}
+
+	transfer, err := services.NewTransferService(e.App).Initiate(workspace, user, req.Email, e.RealIP())
+	if err != nil {
+		return transferErrorResponse(e, err, "failed to start transfer")
+	}
+
+	return e.JSON(http.StatusCreated, newTransferResponse(e.App, transfer))
+}
+
+// CancelWorkspaceTransfer cancels the pending ownership transfer of a workspace
+func CancelWorkspaceTransfer(e *core.RequestEvent) error {
+	user, workspace, err := findManagedWorkspace(e, transferForbidden)
+	if err != nil {
+		return err
+	}
+	if workspace.GetString("user") != user.Id {
+		return e.ForbiddenError(transferForbidden, nil)
+	}
+
+	service := services.NewTransferService(e.App)
+	transfer, err := service.FindPending(workspace.Id)
+	if err != nil {
+		log.Printf("Error loading transfer of workspace %s: %v", workspace.Id, err)
+		return helpers.JSONInternalServerError(e, "failed to load transfer")
+	}
+	if transfer == nil {
+		return helpers.JSONNotFound(e, "transfer not found")
+	}
+
+	if err := service.Cancel(transfer); err != nil {
+		return transferErrorResponse(e, err, "failed to cancel transfer")
+	}
+
+	return e.NoContent(http.StatusNoContent)
+}
+
+// PreviewTransfer returns the details of a transfer from its token to its recipient
+func PreviewTransfer(e *core.RequestEvent) error {
+	user, err := helpers.GetAuthenticatedUser(e)
+	if err != nil {
+		return err
+	}
+
+	transfer, err := services.NewTransferService(e.App).FindByToken(e.Request.URL.Query().Get("token"))
+	if err != nil {
+		return transferErrorResponse(e, err, "failed to load transfer")
+	}
+	if transfer.GetString("to_user") != user.Id {
+		return transferErrorResponse(e, services.ErrTransferWrongRecipient, "failed to load transfer")
+	}
+
+	workspace, err := e.App.FindRecordById(constants.CollectionWorkspaces, transfer.GetString("workspace"))
+	if err != nil {
+		return helpers.JSONNotFound(e, "workspace not found")
+	}
+
+	fromName := ""
+	if owner, err := e.App.FindRecordById(constants.CollectionUsers, transfer.GetString("from_user")); err == nil {
+		fromName = owner.GetString("name")
+	}
+
+	return helpers.JSONSuccess(e, TransferPreviewResponse{
+		WorkspaceName: workspace.GetString("name"),
+		WorkspaceSlug: workspace.GetString("slug"),
+		FromName:      fromName,
+		ToEmail:       user.Email(),
+		HasBilling:    workspace.GetString("subscription_id") != "",
+		ExpiresAt:     transfer.GetDateTime("expires_at").String(),
+	})
+}
+
+// AcceptTransfer makes the authenticated user the owner of the workspace of a transfer
+func AcceptTransfer(e *core.RequestEvent) error {
+	user, err := helpers.GetAuthenticatedUser(e)
+	if err != nil {
+		return err
+	}
+
+	var req AcceptTransferRequest
+	if err := json.NewDecoder(e.Request.Body).Decode(&req); err != nil {
+		log.Printf("Error parsing accept transfer request: %v", err)
+		return helpers.JSONBadRequest(e, "invalid request body")
+	}
+	if req.Token == "" {
+		return helpers.JSONBadRequest(e, "token is required")
+	}
+
+	workspace, err := services.NewTransferService(e.App).Accept(services.AuditContext(e), req.Token, user, e.RealIP())
+	if err != nil {
+		return transferErrorResponse(e, err, "failed to accept transfer")
+	}
+
+	return helpers.JSONSuccess(e, AcceptTransferResponse{
+		WorkspaceID:   workspace.Id,
+		WorkspaceSlug: workspace.GetString("slug"),
+	})
+}
+
+// transferErrorResponse maps an error from the transfer service to a response
+func transferErrorResponse(e *core.RequestEvent, err error, fallback string) error {
+	var apiErr *router.ApiError
+
+	switch {
+	case errors.Is(err, services.ErrTransferInvalid):
+		return helpers.JSONNotFound(e, err.Error())
+	case errors.Is(err, services.ErrNotWorkspaceOwner):
+		return e.ForbiddenError(transferForbidden, nil)
+	case errors.Is(err, services.ErrTransferWrongRecipient):
+		return e.ForbiddenError("This transfer was sent to a different account. Sign in as the recipient to accept it.", nil)
+	case errors.Is(err, services.ErrTransferRecipientNotFound), errors.Is(err, services.ErrTransferToSelf):
+		return helpers.JSONBadRequest(e, err.Error())
+	case errors.As(err, &apiErr):
+		// Errors from record hooks, e.g. the seat limit when the recipient is not a member yet
+		return apiErr
+	}
+
+	log.Printf("Error handling transfer: %v", err)
+	return helpers.JSONInternalServerError(e, fallback)
+}
+
+// newTransferResponse converts a transfer record to its API representation
+func newTransferResponse(app core.App, transfer *core.Record) TransferResponse {
+	response := TransferResponse{
+		ID:        transfer.Id,
+		Status:    transfer.GetString("status"),
+		ExpiresAt: transfer.GetDateTime("expires_at").String(),
+		Created:   transfer.GetDateTime("created").String(),
+	}
+	if recipient, err := app.FindRecordById(constants.CollectionUsers, transfer.GetString("to_user")); err == nil {
+		response.ToEmail = recipient.Email()
+		response.ToName = recipient.GetString("name")
+	}
+	return response
+}
@@ -0,0 +1,259 @@
+package routes
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"pocketvue/constants"
+	"pocketvue/helpers"
+	"pocketvue/services"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// manageWebhooksForbidden is returned to members who may not manage webhooks
+const manageWebhooksForbidden = "Only workspace owners and admins can manage webhooks"
+
+const (
+	// deliveriesDefaultPerPage is the number of webhook deliveries returned per page by default
+	deliveriesDefaultPerPage = 20
+
+	// deliveriesMaxPerPage is the maximum number of webhook deliveries returned per page
+	deliveriesMaxPerPage = 100
+)
+
+// WebhookResponse represents a workspace webhook
+// Secret is only set when the webhook is created or its secret is rotated
+type WebhookResponse struct {
+	ID          string   `json:"id"`
+	URL         string   `json:"url"`
+	Description string   `json:"description"`
+	Events      []string `json:"events"`
+	Enabled     bool     `json:"enabled"`
+	CreatedBy   string   `json:"created_by"`
+	Secret      string   `json:"secret,omitempty"`
+	Created     string   `json:"created"`
+	Updated     string   `json:"updated"`
+}
+
+// WebhookDeliveryResponse represents an entry of the delivery log of a webhook
+type WebhookDeliveryResponse struct {
+	ID             string        `json:"id"`
+	Event          string        `json:"event"`
+	Payload        types.JSONRaw `json:"payload"`
+	Status         string        `json:"status"`
+	Attempts       int           `json:"attempts"`
+	NextAttempt    string        `json:"next_attempt"`
+	ResponseStatus int           `json:"response_status"`
+	ResponseBody   string        `json:"response_body"`
+	Error          string        `json:"error"`
+	DurationMs     int           `json:"duration_ms"`
+	DeliveredAt    string        `json:"delivered_at"`
+	Created        string        `json:"created"`
+}
+
+// WebhookListResponse lists the webhooks of a workspace and the events they can subscribe to
+type WebhookListResponse struct {
+	Items  []WebhookResponse `json:"items"`
+	Events []string          `json:"events"`
+}
+
+// ListWorkspaceWebhooks lists the webhooks of a workspace together with the events they can subscribe to
+func ListWorkspaceWebhooks(e *core.RequestEvent) error {
+	_, workspace, err := findManagedWorkspace(e, manageWebhooksForbidden)
+	if err != nil {
+		return err
+	}
+
+	webhooks, err := services.NewWorkspaceWebhookService(e.App).List(workspace.Id)
+	if err != nil {
+		log.Printf("Error listing webhooks of workspace %s: %v", workspace.Id, err)
+		return helpers.JSONInternalServerError(e, "failed to list webhooks")
+	}
+
+	response := make([]WebhookResponse, 0, len(webhooks))
+	for _, webhook := range webhooks {
+		response = append(response, newWebhookResponse(webhook, ""))
+	}
+
+	return helpers.JSONSuccess(e, WebhookListResponse{
+		Items:  response,
+		Events: services.WebhookEvents,
+	})
+}
+
+// CreateWorkspaceWebhook registers a webhook for a workspace
+// The signing secret is part of this response and of secret rotations only
+func CreateWorkspaceWebhook(e *core.RequestEvent) error {
+	user, workspace, err := findManagedWorkspace(e, manageWebhooksForbidden)
+	if err != nil {
+		return err
+	}
+
+	var input services.WebhookInput
+	if err := json.NewDecoder(e.Request.Body).Decode(&input); err != nil {
+		log.Printf("Error parsing webhook request: %v", err)
+		return helpers.JSONBadRequest(e, "invalid request body")
+	}
+
+	webhook, secret, err := services.NewWorkspaceWebhookService(e.App).Create(workspace, user, input)
+	if err != nil {
+		return webhookErrorResponse(e, err, "failed to create webhook")
+	}
+
+	return e.JSON(http.StatusCreated, newWebhookResponse(webhook, secret))
+}
+
+// UpdateWorkspaceWebhook changes the URL, description, events or enabled state of a webhook
+// and rotates its signing secret when rotate_secret is true
+func UpdateWorkspaceWebhook(e *core.RequestEvent) error {
+	_, workspace, err := findManagedWorkspace(e, manageWebhooksForbidden)
+	if err != nil {
+		return err
+	}
+
+	webhook, err := findWorkspaceWebhook(e, workspace)
+	if err != nil {
+		return err
+	}
+
+	var input services.WebhookInput
+	if err := json.NewDecoder(e.Request.Body).Decode(&input); err != nil {
+		log.Printf("Error parsing webhook request: %v", err)
+		return helpers.JSONBadRequest(e, "invalid request body")
+	}
+
+	secret, err := services.NewWorkspaceWebhookService(e.App).Update(webhook, input)
+	if err != nil {
+		return webhookErrorResponse(e, err, "failed to update webhook")
+	}
+
+	return helpers.JSONSuccess(e, newWebhookResponse(webhook, secret))
+}
+
+// DeleteWorkspaceWebhook deletes a webhook and its delivery log
+func DeleteWorkspaceWebhook(e *core.RequestEvent) error {
+	_, workspace, err := findManagedWorkspace(e, manageWebhooksForbidden)
+	if err != nil {
+		return err
+	}
+
+	webhook, err := findWorkspaceWebhook(e, workspace)
+	if err != nil {
+		return err
+	}
+
+	if err := services.NewWorkspaceWebhookService(e.App).Delete(webhook); err != nil {
+		log.Printf("Error deleting webhook %s: %v", webhook.Id, err)
+		return helpers.JSONInternalServerError(e, "failed to delete webhook")
+	}
+
+	return e.NoContent(http.StatusNoContent)
+}
+
+// TestWorkspaceWebhook sends a webhook.test event to a webhook and returns the delivery
+// The delivery is returned even when the endpoint fails, its status and error tell what happened
+func TestWorkspaceWebhook(e *core.RequestEvent) error {
+	_, workspace, err := findManagedWorkspace(e, manageWebhooksForbidden)
+	if err != nil {
+		return err
+	}
+
+	webhook, err := findWorkspaceWebhook(e, workspace)
+	if err != nil {
+		return err
+	}
+
+	delivery, err := services.NewWorkspaceWebhookService(e.App).SendTest(webhook)
+	if err != nil {
+		log.Printf("Error sending test delivery to webhook %s: %v", webhook.Id, err)
+		return helpers.JSONInternalServerError(e, "failed to send test delivery")
+	}
+
+	return helpers.JSONSuccess(e, newWebhookDeliveryResponse(delivery))
+}
+
+// ListWebhookDeliveries lists the delivery log of a webhook, newest first
+func ListWebhookDeliveries(e *core.RequestEvent) error {
+	_, workspace, err := findManagedWorkspace(e, manageWebhooksForbidden)
+	if err != nil {
+		return err
+	}
+
+	webhook, err := findWorkspaceWebhook(e, workspace)
+	if err != nil {
+		return err
+	}
+
+	pagination := helpers.GetPagination(e, deliveriesDefaultPerPage, deliveriesMaxPerPage)
+
+	deliveries, total, err := services.NewWorkspaceWebhookService(e.App).Deliveries(webhook.Id, pagination.PerPage, pagination.Offset())
+	if err != nil {
+		log.Printf("Error listing deliveries of webhook %s: %v", webhook.Id, err)
+		return helpers.JSONInternalServerError(e, "failed to list webhook deliveries")
+	}
+
+	items := make([]WebhookDeliveryResponse, 0, len(deliveries))
+	for _, delivery := range deliveries {
+		items = append(items, newWebhookDeliveryResponse(delivery))
+	}
+
+	return helpers.JSONSuccess(e, helpers.NewListResult(pagination, items, int(total)))
+}
+
+// findWorkspaceWebhook finds the webhook in the request path, it must belong to the workspace
+func findWorkspaceWebhook(e *core.RequestEvent, workspace *core.Record) (*core.Record, error) {
+	webhook, err := e.App.FindRecordById(constants.CollectionWorkspaceWebhooks, e.Request.PathValue("webhookId"))
+	if err != nil || webhook.GetString("workspace") != workspace.Id {
+		return nil, e.NotFoundError("Webhook not found.", nil)
+	}
+	return webhook, nil
+}
+
+// webhookErrorResponse maps an error from the workspace webhook service to a response
+func webhookErrorResponse(e *core.RequestEvent, err error, fallback string) error {
+	switch {
+	case errors.Is(err, services.ErrWebhookURL), errors.Is(err, services.ErrWebhookEvents):
+		return helpers.JSONBadRequest(e, err.Error())
+	case errors.Is(err, services.ErrWebhookLimit):
+		return helpers.JSONError(e, http.StatusConflict, err.Error())
+	}
+
+	log.Printf("Error handling webhook: %v", err)
+	return helpers.JSONInternalServerError(e, fallback)
+}
+
+// newWebhookResponse converts a webhook record to its API representation
+func newWebhookResponse(webhook *core.Record, secret string) WebhookResponse {
+	return WebhookResponse{
+		ID:          webhook.Id,
+		URL:         webhook.GetString("url"),
+		Description: webhook.GetString("description"),
+		Events:      webhook.GetStringSlice("events"),
+		Enabled:     webhook.GetBool("enabled"),
+		CreatedBy:   webhook.GetString("created_by"),
+		Secret:      secret,
+		Created:     webhook.GetDateTime("created").String(),
+		Updated:     webhook.GetDateTime("updated").String(),
+	}
+}
+
+// newWebhookDeliveryResponse converts a webhook delivery record to its API representation
+func newWebhookDeliveryResponse(delivery *core.Record) WebhookDeliveryResponse {
+	return WebhookDeliveryResponse{
+		ID:             delivery.Id,
+		Event:          delivery.GetString("event"),
+		Payload:        types.JSONRaw(delivery.GetString("payload")),
+		Status:         delivery.GetString("status"),
+		Attempts:       delivery.GetInt("attempts"),
+		NextAttempt:    delivery.GetDateTime("next_attempt").String(),
+		ResponseStatus: delivery.GetInt("response_status"),
+		ResponseBody:   delivery.GetString("response_body"),
+		Error:          delivery.GetString("error"),
+		DurationMs:     delivery.GetInt("duration_ms"),
+		DeliveredAt:    delivery.GetDateTime("delivered_at").String(),
+		Created:        delivery.GetDateTime("created").String(),
+	}
+}
@@ -0,0 +1,230 @@
+package routes
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"pocketvue/constants"
+	"pocketvue/helpers"
+	"pocketvue/services"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// cloneForbidden is returned to members who may not clone a workspace
+const cloneForbidden = "Only workspace owners and admins can clone the workspace"
+
+// CloneJobResponse represents a workspace clone and its progress
+// Processed counts the notes copied so far out of Total
+type CloneJobResponse struct {
+	ID            string `json:"id"`
+	Status        string `json:"status"`
+	Total         int    `json:"total"`
+	Processed     int    `json:"processed"`
+	Error         string `json:"error"`
+	WorkspaceID   string `json:"workspace_id"`
+	WorkspaceSlug string `json:"workspace_slug"`
+	SourceID      string `json:"source_id"`
+	TemplateID    string `json:"template_id"`
+	Created       string `json:"created"`
+	FinishedAt    string `json:"finished_at"`
+}
+
+// WorkspaceTemplateResponse represents a template of the workspace catalog
+// Logo is the file name of the template workspace logo, served from the workspaces collection
+type WorkspaceTemplateResponse struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Notes       int64  `json:"notes"`
+	WorkspaceID string `json:"workspace_id"`
+	Logo        string `json:"logo"`
+}
+
+// WorkspaceTemplateListResponse lists the templates of the workspace catalog
+type WorkspaceTemplateListResponse struct {
+	Items []WorkspaceTemplateResponse `json:"items"`
+}
+
+// CloneWorkspace copies the settings, logo and selected notes of a workspace into a new workspace
+// owned by the authenticated user, with a slug generated from the name unless one is requested
+// Small clones respond with 201 once done, larger ones with 202 and a job to follow
+func CloneWorkspace(e *core.RequestEvent) error {
+	user, workspace, err := findManagedWorkspace(e, cloneForbidden)
+	if err != nil {
+		return err
+	}
+	if user.GetBool("banned") {
+		return e.ForbiddenError("Your account cannot create workspaces.", nil)
+	}
+
+	input, err := readCloneInput(e)
+	if err != nil {
+		return helpers.JSONBadRequest(e, "invalid request body")
+	}
+
+	job, err := services.NewCloneService(e.App).Clone(services.AuditContext(e), workspace, user, input)
+	if err != nil {
+		return cloneErrorResponse(e, err)
+	}
+
+	return cloneJobCreatedResponse(e, job)
+}
+
+// ListWorkspaceTemplates lists the active templates of the workspace catalog
+// Superusers manage the catalog in the workspace_templates collection of the dashboard
+func ListWorkspaceTemplates(e *core.RequestEvent) error {
+	if _, err := helpers.GetAuthenticatedUser(e); err != nil {
+		return err
+	}
+
+	templates, err := services.NewCloneService(e.App).ListTemplates()
+	if err != nil {
+		log.Printf("Error listing workspace templates: %v", err)
+		return helpers.JSONInternalServerError(e, "failed to list workspace templates")
+	}
+
+	items := make([]WorkspaceTemplateResponse, 0, len(templates))
+	for _, template := range templates {
+		items = append(items, newWorkspaceTemplateResponse(e.App, template))
+	}
+
+	return helpers.JSONSuccess(e, WorkspaceTemplateListResponse{Items: items})
+}
+
+// UseWorkspaceTemplate creates a workspace owned by the authenticated user from a template of the catalog
+// It responds like CloneWorkspace, the name and slug of the new workspace can be given in the body
+func UseWorkspaceTemplate(e *core.RequestEvent) error {
+	user, err := helpers.GetAuthenticatedUser(e)
+	if err != nil {
+		return err
+	}
+	if user.GetBool("banned") {
+		return e.ForbiddenError("Your account cannot create workspaces.", nil)
+	}
+
+	template, err := e.App.FindFirstRecordByFilter(
+		constants.CollectionWorkspaceTemplates,
+		`id = {:id} && active = true && workspace.deleted_at = ""`,
+		dbx.Params{"id": e.Request.PathValue("id")},
+	)
+	if err != nil {
+		return helpers.JSONNotFound(e, "template not found")
+	}
+
+	input, err := readCloneInput(e)
+	if err != nil {
+		return helpers.JSONBadRequest(e, "invalid request body")
+	}
+
+	job, err := services.NewCloneService(e.App).Instantiate(services.AuditContext(e), template, user, input)
+	if err != nil {
+		return cloneErrorResponse(e, err)
+	}
+
+	return cloneJobCreatedResponse(e, job)
+}
+
+// GetWorkspaceCloneJob returns the progress of a clone started by the authenticated user
+func GetWorkspaceCloneJob(e *core.RequestEvent) error {
+	user, err := helpers.GetAuthenticatedUser(e)
+	if err != nil {
+		return err
+	}
+
+	job, err := e.App.FindRecordById(constants.CollectionWorkspaceCloneJobs, e.Request.PathValue("jobId"))
+	if err != nil || job.GetString("user") != user.Id {
+		return helpers.JSONNotFound(e, "clone not found")
+	}
+
+	return helpers.JSONSuccess(e, newCloneJobResponse(e.App, job))
+}
+
+// readCloneInput decodes the optional JSON body of a clone request
+func readCloneInput(e *core.RequestEvent) (services.CloneInput, error) {
+	var input services.CloneInput
+	if err := json.NewDecoder(e.Request.Body).Decode(&input); err != nil && !errors.Is(err, io.EOF) {
+		log.Printf("Error parsing clone request: %v", err)
+		return input, err
+	}
+	return input, nil
+}
+
+// cloneJobCreatedResponse responds with a new clone job, a job that failed before the response is an error
+func cloneJobCreatedResponse(e *core.RequestEvent, job *core.Record) error {
+	switch job.GetString("status") {
+	case services.CloneStatusSucceeded:
+		return e.JSON(http.StatusCreated, newCloneJobResponse(e.App, job))
+	case services.CloneStatusFailed:
+		return helpers.JSONInternalServerError(e, job.GetString("error"))
+	}
+	return e.JSON(http.StatusAccepted, newCloneJobResponse(e.App, job))
+}
+
+// cloneErrorResponse maps an error from the clone service to a response
+func cloneErrorResponse(e *core.RequestEvent, err error) error {
+	var errs validation.Errors
+
+	switch {
+	case errors.Is(err, services.ErrCloneInProgress):
+		return helpers.JSONError(e, http.StatusConflict, err.Error())
+	case errors.Is(err, services.ErrCloneNotes):
+		return helpers.JSONBadRequest(e, err.Error())
+	case errors.As(err, &errs):
+		// Record validation of the new workspace, e.g. a logo that is too large for the free plan
+		return e.BadRequestError("Failed to create the workspace.", errs)
+	}
+
+	log.Printf("Error cloning workspace: %v", err)
+	return helpers.JSONInternalServerError(e, "failed to clone workspace")
+}
+
+// newCloneJobResponse converts a clone job record to its API representation
+func newCloneJobResponse(app core.App, job *core.Record) CloneJobResponse {
+	response := CloneJobResponse{
+		ID:          job.Id,
+		Status:      job.GetString("status"),
+		Total:       job.GetInt("total"),
+		Processed:   job.GetInt("processed"),
+		Error:       job.GetString("error"),
+		WorkspaceID: job.GetString("workspace"),
+		SourceID:    job.GetString("source"),
+		TemplateID:  job.GetString("template"),
+		Created:     job.GetDateTime("created").String(),
+		FinishedAt:  job.GetDateTime("finished_at").String(),
+	}
+
+	if response.WorkspaceID != "" {
+		if workspace, err := app.FindRecordById(constants.CollectionWorkspaces, response.WorkspaceID); err == nil {
+			response.WorkspaceSlug = workspace.GetString("slug")
+		}
+	}
+
+	return response
+}
+
+// newWorkspaceTemplateResponse converts a template record to its API representation
+func newWorkspaceTemplateResponse(app core.App, template *core.Record) WorkspaceTemplateResponse {
+	response := WorkspaceTemplateResponse{
+		ID:          template.Id,
+		Name:        template.GetString("name"),
+		Description: template.GetString("description"),
+		WorkspaceID: template.GetString("workspace"),
+	}
+
+	if workspace, err := app.FindRecordById(constants.CollectionWorkspaces, response.WorkspaceID); err == nil {
+		response.Logo = workspace.GetString("logo")
+	}
+
+	notes, err := app.CountRecords(constants.CollectionNotes, dbx.HashExp{"workspace": response.WorkspaceID})
+	if err != nil {
+		log.Printf("Error counting notes of template %s: %v", template.Id, err)
+	}
+	response.Notes = notes
+
+	return response
+}
@@ -0,0 +1,84 @@
+package routes
+
+import (
+	"errors"
+	"io"
+	"log"
+	"pocketvue/helpers"
+	"pocketvue/services"
+	"pocketvue/types"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// manageSettingsForbidden is returned to members who may not change the workspace settings
+const manageSettingsForbidden = "Only workspace owners and admins can change the workspace settings"
+
+// maxSettingsPatchBytes is the largest settings patch that is read
+const maxSettingsPatchBytes = 100000
+
+// WorkspaceSettingsResponse represents the effective settings of a workspace
+// together with the defaults and choices the settings form offers
+type WorkspaceSettingsResponse struct {
+	Settings   types.WorkspaceSettings `json:"settings"`
+	Defaults   types.WorkspaceSettings `json:"defaults"`
+	NoteColors []string                `json:"note_colors"`
+}
+
+// GetWorkspaceSettings returns the effective settings of a workspace to any of its members
+func GetWorkspaceSettings(e *core.RequestEvent) error {
+	_, workspace, err := findMemberWorkspace(e)
+	if err != nil {
+		return err
+	}
+
+	settings, err := services.NewWorkspaceSettingsService(e.App).Get(workspace)
+	if err != nil {
+		// Settings that no longer parse are reported, defaults are not silently applied over them
+		log.Printf("Error reading settings of workspace %s: %v", workspace.Id, err)
+		return helpers.JSONInternalServerError(e, "failed to read workspace settings")
+	}
+
+	return helpers.JSONSuccess(e, newWorkspaceSettingsResponse(settings))
+}
+
+// UpdateWorkspaceSettings applies a JSON merge patch (RFC 7386) to the settings of a workspace
+// Only the settings in the body change, settings set to null are reset to their defaults
+func UpdateWorkspaceSettings(e *core.RequestEvent) error {
+	_, workspace, err := findManagedWorkspace(e, manageSettingsForbidden)
+	if err != nil {
+		return err
+	}
+
+	patch, err := io.ReadAll(io.LimitReader(e.Request.Body, maxSettingsPatchBytes))
+	if err != nil {
+		log.Printf("Error reading settings request: %v", err)
+		return helpers.JSONBadRequest(e, "invalid request body")
+	}
+
+	settings, err := services.NewWorkspaceSettingsService(e.App).Update(services.AuditContext(e), workspace, patch)
+	if err != nil {
+		var errs validation.Errors
+		switch {
+		case errors.Is(err, services.ErrSettingsNotObject):
+			return helpers.JSONBadRequest(e, err.Error())
+		case errors.As(err, &errs):
+			return e.BadRequestError("Invalid workspace settings.", errs)
+		}
+
+		log.Printf("Error updating settings of workspace %s: %v", workspace.Id, err)
+		return helpers.JSONInternalServerError(e, "failed to update workspace settings")
+	}
+
+	return helpers.JSONSuccess(e, newWorkspaceSettingsResponse(settings))
+}
+
+// newWorkspaceSettingsResponse wraps settings with the defaults and note colors
+func newWorkspaceSettingsResponse(settings types.WorkspaceSettings) WorkspaceSettingsResponse {
+	return WorkspaceSettingsResponse{
+		Settings:   settings,
+		Defaults:   types.DefaultWorkspaceSettings(),
+		NoteColors: types.NoteColors,
+	}
+}
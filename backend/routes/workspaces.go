@@ -1,30 +1,232 @@
 package routes
 
 import (
+	"database/sql"
+	"errors"
 	"log"
+	"net/http"
 	"pocketvue/constants"
 	"pocketvue/helpers"
+	"pocketvue/services"
+	"strings"
 
+	"github.com/pocketbase/dbx"
 	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/types"
 )
 
-func GetAllWorkspaces(e *core.RequestEvent) error {
-	// Get authenticated user
+const (
+	// workspaceListDefaultPerPage is the number of workspaces returned per page by default
+	workspaceListDefaultPerPage = 20
+
+	// workspaceListMaxPerPage is the maximum number of workspaces returned per page
+	workspaceListMaxPerPage = 100
+)
+
+// ListWorkspaces lists the workspaces the authenticated user owns or is a member of
+// with their role, note count and plan
+// Supports the page, perPage, scope (all, owned or member), search and sort query parameters
+func ListWorkspaces(e *core.RequestEvent) error {
+	user, err := helpers.GetAuthenticatedUser(e)
+	if err != nil {
+		return err
+	}
+
+	params := e.Request.URL.Query()
+	sort := params.Get("sort")
+	if sort == "" {
+		sort = "name"
+	}
+	if !services.ValidWorkspaceListSort(sort) {
+		return helpers.JSONBadRequest(e, "sort must be one of name, created, updated or notes, optionally prefixed with -")
+	}
+
+	pagination := helpers.GetPagination(e, workspaceListDefaultPerPage, workspaceListMaxPerPage)
+	query := services.WorkspaceListQuery{
+		Scope:  params.Get("scope"),
+		Search: params.Get("search"),
+		Sort:   sort,
+		Limit:  pagination.PerPage,
+		Offset: pagination.Offset(),
+	}
+
+	items, total, err := services.NewWorkspaceListService(e.App).List(user.Id, query)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidWorkspaceScope) {
+			return helpers.JSONBadRequest(e, err.Error())
+		}
+		log.Printf("Error listing workspaces of user %s: %v", user.Id, err)
+		return helpers.JSONInternalServerError(e, "failed to list workspaces")
+	}
+
+	return helpers.JSONSuccess(e, helpers.NewListResult(pagination, items, total))
+}
+
+// slugSuggestionLimit is the number of alternatives returned for an unavailable slug
+const slugSuggestionLimit = 3
+
+// SlugAvailabilityResponse represents the availability of a workspace slug
+type SlugAvailabilityResponse struct {
+	Slug        string   `json:"slug"`
+	Available   bool     `json:"available"`
+	Code        string   `json:"code,omitempty"`
+	Reason      string   `json:"reason,omitempty"`
+	Suggestions []string `json:"suggestions"`
+}
+
+// CheckSlugAvailability reports whether a workspace slug can be used and suggests alternatives when it cannot
+// The slug is normalized first, the response contains the normalized form
+func CheckSlugAvailability(e *core.RequestEvent) error {
+	if _, err := helpers.GetAuthenticatedUser(e); err != nil {
+		return err
+	}
+
+	requested := e.Request.URL.Query().Get("slug")
+	if requested == "" {
+		return helpers.JSONBadRequest(e, "slug is required")
+	}
+
+	slugService := services.NewSlugService(e.App)
+	slug := services.NormalizeSlug(requested)
+
+	slugErr, err := slugService.Check(slug, "")
+	if err != nil {
+		log.Printf("Error checking slug availability: %v", err)
+		return helpers.JSONInternalServerError(e, "failed to check slug availability")
+	}
+
+	response := SlugAvailabilityResponse{
+		Slug:        slug,
+		Available:   slugErr == nil,
+		Suggestions: []string{},
+	}
+
+	if slugErr != nil {
+		response.Code = slugErr.Code
+		response.Reason = slugErr.Message
+
+		suggestions, err := slugService.Suggest(requested, slugSuggestionLimit)
+		if err != nil {
+			log.Printf("Error suggesting slugs: %v", err)
+		} else {
+			response.Suggestions = suggestions
+		}
+	}
+
+	return helpers.JSONSuccess(e, response)
+}
+
+// ResolveSlugResponse represents the workspace a slug resolves to
+type ResolveSlugResponse struct {
+	WorkspaceID string `json:"workspace_id"`
+	Slug        string `json:"slug"`
+	Renamed     bool   `json:"renamed"`
+}
+
+// ResolveWorkspaceSlug maps a current or previous workspace slug to the workspace and its current slug
+// Clients redirect to the current slug when renamed is true
+func ResolveWorkspaceSlug(e *core.RequestEvent) error {
 	user, err := helpers.GetAuthenticatedUser(e)
 	if err != nil {
 		return err
 	}
 
-	// Log user information
-	log.Printf("GetAllWorkspaces called by user: ID=%s, Email=%s, Name=%s",
-		user.GetString("id"),
-		user.GetString("email"),
-		user.GetString("name"))
+	slug := strings.ToLower(strings.TrimSpace(e.Request.URL.Query().Get("slug")))
+	if slug == "" {
+		return helpers.JSONBadRequest(e, "slug is required")
+	}
+
+	workspace, renamed, err := helpers.ResolveWorkspaceSlug(e.App, slug)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			log.Printf("Error resolving workspace slug %s: %v", slug, err)
+		}
+		return helpers.JSONNotFound(e, "workspace not found")
+	}
+
+	// Do not reveal workspaces the user cannot access
+	if !helpers.HasWorkspaceRole(e.App, workspace.Id, user.Id, constants.WorkspaceRoleViewer) {
+		return helpers.JSONNotFound(e, "workspace not found")
+	}
+
+	return helpers.JSONSuccess(e, ResolveSlugResponse{
+		WorkspaceID: workspace.Id,
+		Slug:        workspace.GetString("slug"),
+		Renamed:     renamed,
+	})
+}
+
+// DeletedWorkspaceResponse represents a soft deleted workspace that can still be restored
+type DeletedWorkspaceResponse struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Slug      string `json:"slug"`
+	DeletedAt string `json:"deleted_at"`
+	PurgeAt   string `json:"purge_at"`
+}
 
-	records, err := helpers.FindAllRecords(e.App, constants.CollectionWorkspaces)
+// ListDeletedWorkspaces lists the deleted workspaces owned by the authenticated user that are not purged yet
+func ListDeletedWorkspaces(e *core.RequestEvent) error {
+	user, err := helpers.GetAuthenticatedUser(e)
 	if err != nil {
-		return helpers.JSONInternalServerError(e, err.Error())
+		return err
 	}
 
-	return helpers.JSONSuccess(e, records)
+	workspaces, err := e.App.FindRecordsByFilter(
+		constants.CollectionWorkspaces,
+		"user = {:user} && deleted_at != ''",
+		"-deleted_at",
+		0,
+		0,
+		dbx.Params{"user": user.Id},
+	)
+	if err != nil {
+		log.Printf("Error listing deleted workspaces of user %s: %v", user.Id, err)
+		return helpers.JSONInternalServerError(e, "failed to list deleted workspaces")
+	}
+
+	response := make([]DeletedWorkspaceResponse, 0, len(workspaces))
+	for _, workspace := range workspaces {
+		response = append(response, newDeletedWorkspaceResponse(workspace))
+	}
+
+	return helpers.JSONSuccess(e, response)
+}
+
+// RestoreWorkspace undoes the deletion of a workspace, only its owner may restore it
+func RestoreWorkspace(e *core.RequestEvent) error {
+	user, err := helpers.GetAuthenticatedUser(e)
+	if err != nil {
+		return err
+	}
+
+	workspace, err := e.App.FindRecordById(constants.CollectionWorkspaces, e.Request.PathValue("id"))
+	if err != nil || workspace.GetString("user") != user.Id {
+		return helpers.JSONNotFound(e, "workspace not found")
+	}
+
+	if err := services.NewWorkspaceDeletionService(e.App).Restore(services.AuditContext(e), workspace); err != nil {
+		if errors.Is(err, services.ErrWorkspaceNotDeleted) {
+			return helpers.JSONError(e, http.StatusConflict, err.Error())
+		}
+		log.Printf("Error restoring workspace %s: %v", workspace.Id, err)
+		return helpers.JSONInternalServerError(e, "failed to restore workspace")
+	}
+
+	return helpers.JSONSuccess(e, map[string]string{
+		"id":   workspace.Id,
+		"slug": workspace.GetString("slug"),
+	})
+}
+
+// newDeletedWorkspaceResponse converts a deleted workspace record to its API representation
+func newDeletedWorkspaceResponse(workspace *core.Record) DeletedWorkspaceResponse {
+	purgeAt, _ := types.ParseDateTime(services.WorkspacePurgeAt(workspace))
+	return DeletedWorkspaceResponse{
+		ID:        workspace.Id,
+		Name:      workspace.GetString("name"),
+		Slug:      workspace.GetString("slug"),
+		DeletedAt: workspace.GetDateTime("deleted_at").String(),
+		PurgeAt:   purgeAt.String(),
+	}
 }
@@ -0,0 +1,249 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"pocketvue/constants"
+	"pocketvue/helpers"
+	"strings"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/security"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// API key scopes stored in the workspace_api_keys.scopes field
+const (
+	APIKeyScopeNotesRead  = "notes:read"
+	APIKeyScopeNotesWrite = "notes:write"
+
+	// APIKeyScopeWorkspaceRead reads the workspace itself, such as its members with their emails and its logo
+	APIKeyScopeWorkspaceRead = "workspace:read"
+)
+
+// APIKeyScopes lists the scopes an API key can be granted
+var APIKeyScopes = []string{APIKeyScopeNotesRead, APIKeyScopeNotesWrite, APIKeyScopeWorkspaceRead}
+
+const (
+	// APIKeyPrefix starts every API key so keys are easy to recognize in headers and secret scanners
+	APIKeyPrefix = "pvk_"
+
+	// APIKeyRequestKey is the request store key of the API key that authenticated a request
+	APIKeyRequestKey = "workspaceAPIKey"
+
+	// apiKeyIDLength is the length of the public part of a key, shown as its display prefix
+	apiKeyIDLength = 8
+
+	// apiKeySecretLength is the length of the secret part of a key
+	apiKeySecretLength = 40
+
+	// apiKeyLastUsedInterval limits how often the last used time of a key is written
+	apiKeyLastUsedInterval = time.Minute
+
+	// maxActiveAPIKeys is the number of active keys a workspace can have
+	maxActiveAPIKeys = 25
+)
+
+var (
+	// ErrAPIKeyInvalid is returned for unknown, revoked or expired API keys
+	// and keys whose creator is no longer an admin of the workspace
+	ErrAPIKeyInvalid = errors.New("API key is invalid or has expired")
+
+	// ErrAPIKeyScopes is returned when a key is created without scopes or with unknown scopes
+	ErrAPIKeyScopes = fmt.Errorf("scopes must be one or more of %s", strings.Join(APIKeyScopes, ", "))
+
+	// ErrAPIKeyName is returned when a key is created without a name
+	ErrAPIKeyName = errors.New("name is required")
+
+	// ErrAPIKeyExpiry is returned when a key is created with an expiry in the past
+	ErrAPIKeyExpiry = errors.New("expiry must be in the future")
+
+	// ErrAPIKeyLimit is returned when a workspace already has the maximum number of active keys
+	ErrAPIKeyLimit = fmt.Errorf("a workspace can have at most %d active API keys", maxActiveAPIKeys)
+
+	// ErrAPIKeyRevoked is returned when revoking a key that is already revoked
+	ErrAPIKeyRevoked = errors.New("API key is already revoked")
+)
+
+// APIKeyService creates, revokes and authenticates workspace API keys
+// Keys act on behalf of the admin who created them, limited to one workspace and to their scopes
+// Only a SHA-256 hash of a key is stored, the key itself is shown once when it is created
+type APIKeyService struct {
+	app core.App
+}
+
+// NewAPIKeyService creates a new API key service instance
+func NewAPIKeyService(app core.App) *APIKeyService {
+	return &APIKeyService{
+		app: app,
+	}
+}
+
+// Create issues a new API key for a workspace and returns its record and the key
+// expiresAt may be zero for keys that do not expire
+func (aks *APIKeyService) Create(workspace, user *core.Record, name string, scopes []string, expiresAt types.DateTime) (*core.Record, string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, "", ErrAPIKeyName
+	}
+	if !validAPIKeyScopes(scopes) {
+		return nil, "", ErrAPIKeyScopes
+	}
+	if !expiresAt.IsZero() && !expiresAt.Time().After(time.Now()) {
+		return nil, "", ErrAPIKeyExpiry
+	}
+
+	active, err := aks.app.CountRecords(constants.CollectionWorkspaceAPIKeys, dbx.HashExp{
+		"workspace":  workspace.Id,
+		"revoked_at": "",
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	if active >= maxActiveAPIKeys {
+		return nil, "", ErrAPIKeyLimit
+	}
+
+	collection, err := aks.app.FindCollectionByNameOrId(constants.CollectionWorkspaceAPIKeys)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to find workspace_api_keys collection: %w", err)
+	}
+
+	prefix := APIKeyPrefix + security.RandomStringWithAlphabet(apiKeyIDLength, "abcdefghijklmnopqrstuvwxyz0123456789")
+	key := prefix + "_" + security.RandomString(apiKeySecretLength)
+
+	record := core.NewRecord(collection)
+	record.Set("workspace", workspace.Id)
+	record.Set("name", name)
+	record.Set("prefix", prefix)
+	record.Set("key_hash", security.SHA256(key))
+	record.Set("scopes", scopes)
+	record.Set("created_by", user.Id)
+	if !expiresAt.IsZero() {
+		record.Set("expires_at", expiresAt)
+	}
+
+	if err := aks.app.Save(record); err != nil {
+		return nil, "", fmt.Errorf("failed to save API key of workspace %s: %w", workspace.Id, err)
+	}
+
+	log.Printf("User %s created API key %s for workspace %s", user.Id, prefix, workspace.Id)
+	return record, key, nil
+}
+
+// List returns the API keys of a workspace, newest first, including revoked ones
+func (aks *APIKeyService) List(workspaceID string) ([]*core.Record, error) {
+	return aks.app.FindRecordsByFilter(
+		constants.CollectionWorkspaceAPIKeys,
+		"workspace = {:workspace}",
+		"-created",
+		0,
+		0,
+		dbx.Params{"workspace": workspaceID},
+	)
+}
+
+// Revoke disables an API key, revoked keys are kept so their use stays traceable
+func (aks *APIKeyService) Revoke(key, user *core.Record) error {
+	if !key.GetDateTime("revoked_at").IsZero() {
+		return ErrAPIKeyRevoked
+	}
+
+	key.Set("revoked_at", types.NowDateTime())
+	key.Set("revoked_by", user.Id)
+	if err := aks.app.Save(key); err != nil {
+		return fmt.Errorf("failed to revoke API key %s: %w", key.Id, err)
+	}
+
+	log.Printf("User %s revoked API key %s of workspace %s", user.Id, key.GetString("prefix"), key.GetString("workspace"))
+	return nil
+}
+
+// Authenticate returns the API key record and its creator for a key
+// The key must be active, its workspace not deleted and its creator still an admin of the workspace
+func (aks *APIKeyService) Authenticate(key string) (*core.Record, *core.Record, error) {
+	if !strings.HasPrefix(key, APIKeyPrefix) {
+		return nil, nil, ErrAPIKeyInvalid
+	}
+
+	record, err := aks.app.FindFirstRecordByFilter(
+		constants.CollectionWorkspaceAPIKeys,
+		"key_hash = {:hash}",
+		dbx.Params{"hash": security.SHA256(key)},
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil, ErrAPIKeyInvalid
+		}
+		return nil, nil, err
+	}
+
+	if !record.GetDateTime("revoked_at").IsZero() {
+		return nil, nil, ErrAPIKeyInvalid
+	}
+	if expiresAt := record.GetDateTime("expires_at"); !expiresAt.IsZero() && expiresAt.Time().Before(time.Now()) {
+		return nil, nil, ErrAPIKeyInvalid
+	}
+
+	workspace, err := aks.app.FindRecordById(constants.CollectionWorkspaces, record.GetString("workspace"))
+	if err != nil || helpers.IsWorkspaceDeleted(workspace) {
+		return nil, nil, ErrAPIKeyInvalid
+	}
+
+	creator, err := aks.app.FindRecordById(constants.CollectionUsers, record.GetString("created_by"))
+	if err != nil || creator.GetBool("banned") ||
+		!helpers.HasWorkspaceRole(aks.app, workspace.Id, creator.Id, constants.WorkspaceRoleAdmin) {
+		return nil, nil, ErrAPIKeyInvalid
+	}
+
+	aks.touch(record)
+
+	return record, creator, nil
+}
+
+// HasAPIKeyScope reports whether an API key was granted a scope
+func HasAPIKeyScope(key *core.Record, scope string) bool {
+	for _, granted := range key.GetStringSlice("scopes") {
+		if granted == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// touch records the use of a key, at most once per apiKeyLastUsedInterval
+func (aks *APIKeyService) touch(key *core.Record) {
+	lastUsed := key.GetDateTime("last_used_at")
+	if !lastUsed.IsZero() && time.Since(lastUsed.Time()) < apiKeyLastUsedInterval {
+		return
+	}
+
+	key.Set("last_used_at", types.NowDateTime())
+	if err := aks.app.SaveNoValidate(key); err != nil {
+		log.Printf("Error updating last use of API key %s: %v", key.GetString("prefix"), err)
+	}
+}
+
+// validAPIKeyScopes reports whether scopes is a non-empty list of known scopes
+func validAPIKeyScopes(scopes []string) bool {
+	if len(scopes) == 0 {
+		return false
+	}
+	for _, scope := range scopes {
+		known := false
+		for _, allowed := range APIKeyScopes {
+			if scope == allowed {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,469 @@
+package services
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"path"
+	"pocketvue/constants"
+	"strings"
+	"time"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/filesystem"
+)
+
+const (
+	// ArchiveFormat identifies workspace archives in their manifest
+	ArchiveFormat = "pocketvue-workspace"
+
+	// ArchiveVersion is the manifest version written by Export
+	// Bump it when the archive layout changes and teach Import to read the previous versions
+	ArchiveVersion = 1
+
+	// archiveMaxEntrySize bounds the uncompressed size of a single archive entry
+	archiveMaxEntrySize = 64 << 20
+)
+
+// Paths of the entries of a workspace archive
+const (
+	archiveManifestPath  = "manifest.json"
+	archiveWorkspacePath = "workspace.json"
+	archiveMembersPath   = "members.json"
+	archiveNotesPath     = "notes.json"
+	archiveNotesDir      = "notes/"
+	archiveLogoDir       = "logo/"
+)
+
+var (
+	// ErrArchiveInvalid is returned when an uploaded file is not a readable workspace archive
+	ErrArchiveInvalid = errors.New("file is not a valid workspace archive")
+
+	// ErrArchiveUnsupportedVersion is returned for archives written by a newer version of the app
+	ErrArchiveUnsupportedVersion = errors.New("archive version is not supported by this server")
+)
+
+// ArchiveManifest describes a workspace archive, it is the manifest.json entry
+type ArchiveManifest struct {
+	Format      string `json:"format"`
+	Version     int    `json:"version"`
+	ExportedAt  string `json:"exported_at"`
+	WorkspaceID string `json:"workspace_id"`
+	Notes       int    `json:"notes"`
+	Members     int    `json:"members"`
+}
+
+// ArchiveWorkspace is the workspace.json entry of an archive
+type ArchiveWorkspace struct {
+	Name    string `json:"name"`
+	Slug    string `json:"slug"`
+	Domain  string `json:"domain"`
+	Logo    string `json:"logo,omitempty"`
+	Created string `json:"created"`
+	Updated string `json:"updated"`
+}
+
+// ArchiveMember is an entry of members.json
+type ArchiveMember struct {
+	Email  string `json:"email"`
+	Name   string `json:"name"`
+	Role   string `json:"role"`
+	Joined string `json:"joined"`
+}
+
+// ArchiveNote is an entry of notes.json, Markdown is the path of the note converted to Markdown
+type ArchiveNote struct {
+	ID       string `json:"id"`
+	Title    string `json:"title"`
+	Content  string `json:"content"`
+	Color    string `json:"color"`
+	Author   string `json:"author"`
+	Created  string `json:"created"`
+	Updated  string `json:"updated"`
+	Markdown string `json:"markdown"`
+}
+
+// ImportResult describes a workspace created from an archive
+// Members are not added automatically, the list lets the new owner invite them
+type ImportResult struct {
+	WorkspaceID   string          `json:"workspace_id"`
+	Slug          string          `json:"slug"`
+	RequestedSlug string          `json:"requested_slug"`
+	Notes         int             `json:"notes"`
+	Members       []ArchiveMember `json:"members"`
+}
+
+// ArchiveService exports workspaces to zip archives and recreates workspaces from them
+type ArchiveService struct {
+	app core.App
+}
+
+// NewArchiveService creates a new archive service instance
+func NewArchiveService(app core.App) *ArchiveService {
+	return &ArchiveService{
+		app: app,
+	}
+}
+
+// Export writes a zip archive of the workspace metadata, members, notes and logo to w
+func (as *ArchiveService) Export(w io.Writer, workspace *core.Record) error {
+	notes, err := as.app.FindRecordsByFilter(
+		constants.CollectionNotes,
+		"workspace = {:workspace}",
+		"created",
+		0,
+		0,
+		dbx.Params{"workspace": workspace.Id},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to find notes of workspace %s: %w", workspace.Id, err)
+	}
+
+	members, err := as.exportMembers(workspace.Id)
+	if err != nil {
+		return err
+	}
+
+	archive := zip.NewWriter(w)
+
+	data := ArchiveWorkspace{
+		Name:    workspace.GetString("name"),
+		Slug:    workspace.GetString("slug"),
+		Domain:  workspace.GetString("domain"),
+		Created: workspace.GetDateTime("created").String(),
+		Updated: workspace.GetDateTime("updated").String(),
+	}
+	if logo := workspace.GetString("logo"); logo != "" {
+		data.Logo = archiveLogoDir + logo
+		if err := as.copyFile(archive, workspace.BaseFilesPath()+"/"+logo, data.Logo); err != nil {
+			// A missing logo should not prevent customers from getting their data
+			log.Printf("Warning: failed to export logo of workspace %s: %v", workspace.Id, err)
+			data.Logo = ""
+		}
+	}
+
+	authors := map[string]string{}
+	archiveNotes := make([]ArchiveNote, 0, len(notes))
+	for i, note := range notes {
+		entry := ArchiveNote{
+			ID:       note.Id,
+			Title:    note.GetString("title"),
+			Content:  note.GetString("content"),
+			Color:    note.GetString("color"),
+			Author:   as.authorEmail(authors, note.GetString("user")),
+			Created:  note.GetDateTime("created").String(),
+			Updated:  note.GetDateTime("updated").String(),
+			Markdown: fmt.Sprintf("%s%03d-%s.md", archiveNotesDir, i+1, noteFileName(note.GetString("title"))),
+		}
+		archiveNotes = append(archiveNotes, entry)
+
+		if err := writeArchiveEntry(archive, entry.Markdown, []byte(noteMarkdown(entry))); err != nil {
+			return err
+		}
+	}
+
+	manifest := ArchiveManifest{
+		Format:      ArchiveFormat,
+		Version:     ArchiveVersion,
+		ExportedAt:  time.Now().UTC().Format(time.RFC3339),
+		WorkspaceID: workspace.Id,
+		Notes:       len(archiveNotes),
+		Members:     len(members),
+	}
+
+	entries := []struct {
+		name  string
+		value any
+	}{
+		{archiveManifestPath, manifest},
+		{archiveWorkspacePath, data},
+		{archiveMembersPath, members},
+		{archiveNotesPath, archiveNotes},
+	}
+	for _, entry := range entries {
+		content, err := json.MarshalIndent(entry.value, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := writeArchiveEntry(archive, entry.name, content); err != nil {
+			return err
+		}
+	}
+
+	return archive.Close()
+}
+
+// Import creates a workspace owned by the user from an archive, with new IDs for every record
+// The archived slug is kept when it is available, otherwise an available variant is used
+// The archived domain is not imported, an archive must not claim the domain of another workspace
+func (as *ArchiveService) Import(ctx context.Context, r io.ReaderAt, size int64, owner *core.Record) (*ImportResult, error) {
+	archive, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, ErrArchiveInvalid
+	}
+
+	var manifest ArchiveManifest
+	if err := readArchiveJSON(archive, archiveManifestPath, &manifest); err != nil {
+		return nil, err
+	}
+	if manifest.Format != ArchiveFormat || manifest.Version < 1 {
+		return nil, ErrArchiveInvalid
+	}
+	if manifest.Version > ArchiveVersion {
+		return nil, ErrArchiveUnsupportedVersion
+	}
+
+	var data ArchiveWorkspace
+	var notes []ArchiveNote
+	var members []ArchiveMember
+	if err := readArchiveJSON(archive, archiveWorkspacePath, &data); err != nil {
+		return nil, err
+	}
+	if err := readArchiveJSON(archive, archiveNotesPath, &notes); err != nil {
+		return nil, err
+	}
+	// The member list is informational, a missing or malformed list does not stop the import
+	if err := readArchiveJSON(archive, archiveMembersPath, &members); err != nil {
+		members = nil
+	}
+	if strings.TrimSpace(data.Name) == "" {
+		return nil, ErrArchiveInvalid
+	}
+
+	slug, err := as.availableSlug(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var logo *filesystem.File
+	if data.Logo != "" {
+		content, err := readArchiveFile(archive, data.Logo)
+		if err != nil {
+			return nil, err
+		}
+		logo, err = filesystem.NewFileFromBytes(content, path.Base(data.Logo))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var workspace *core.Record
+	err = as.app.RunInTransaction(func(txApp core.App) error {
+		workspaces, err := txApp.FindCollectionByNameOrId(constants.CollectionWorkspaces)
+		if err != nil {
+			return err
+		}
+
+		workspace = core.NewRecord(workspaces)
+		workspace.Set("name", data.Name)
+		workspace.Set("slug", slug)
+		workspace.Set("user", owner.Id)
+		if logo != nil {
+			workspace.Set("logo", logo)
+		}
+		if err := txApp.SaveWithContext(ctx, workspace); err != nil {
+			return archiveRecordError("workspace", err)
+		}
+
+		notesCollection, err := txApp.FindCollectionByNameOrId(constants.CollectionNotes)
+		if err != nil {
+			return err
+		}
+		for _, entry := range notes {
+			note := core.NewRecord(notesCollection)
+			note.Set("title", entry.Title)
+			note.Set("content", entry.Content)
+			note.Set("color", entry.Color)
+			note.Set("user", owner.Id)
+			note.Set("workspace", workspace.Id)
+			if err := txApp.SaveWithContext(ctx, note); err != nil {
+				return archiveRecordError(fmt.Sprintf("note %q", entry.Title), err)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if members == nil {
+		members = []ArchiveMember{}
+	}
+
+	log.Printf("User %s imported workspace %s from archive of workspace %s (%d notes)",
+		owner.Id, workspace.Id, manifest.WorkspaceID, len(notes))
+
+	return &ImportResult{
+		WorkspaceID:   workspace.Id,
+		Slug:          slug,
+		RequestedSlug: data.Slug,
+		Notes:         len(notes),
+		Members:       members,
+	}, nil
+}
+
+// availableSlug returns the archived slug if it can be used, or an available variant of it
+func (as *ArchiveService) availableSlug(data ArchiveWorkspace) (string, error) {
+	requested := data.Slug
+	if NormalizeSlug(requested) == "" {
+		requested = data.Name
+	}
+
+	return NewSlugService(as.app).Available(requested)
+}
+
+// exportMembers lists the members of a workspace with their email, name and role
+func (as *ArchiveService) exportMembers(workspaceID string) ([]ArchiveMember, error) {
+	records, err := as.app.FindRecordsByFilter(
+		constants.CollectionWorkspaceMembers,
+		"workspace = {:workspace}",
+		"created",
+		0,
+		0,
+		dbx.Params{"workspace": workspaceID},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find members of workspace %s: %w", workspaceID, err)
+	}
+
+	members := make([]ArchiveMember, 0, len(records))
+	for _, record := range records {
+		user, err := as.app.FindRecordById(constants.CollectionUsers, record.GetString("user"))
+		if err != nil {
+			continue
+		}
+		members = append(members, ArchiveMember{
+			Email:  user.Email(),
+			Name:   user.GetString("name"),
+			Role:   record.GetString("role"),
+			Joined: record.GetDateTime("created").String(),
+		})
+	}
+	return members, nil
+}
+
+// authorEmail returns the email of a note author, caching lookups since authors repeat
+func (as *ArchiveService) authorEmail(cache map[string]string, userID string) string {
+	if email, ok := cache[userID]; ok {
+		return email
+	}
+	email := ""
+	if user, err := as.app.FindRecordById(constants.CollectionUsers, userID); err == nil {
+		email = user.Email()
+	}
+	cache[userID] = email
+	return email
+}
+
+// copyFile copies a file from the app storage into the archive
+func (as *ArchiveService) copyFile(archive *zip.Writer, key, name string) error {
+	fsys, err := as.app.NewFilesystem()
+	if err != nil {
+		return err
+	}
+	defer fsys.Close()
+
+	reader, err := fsys.GetReader(key)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	w, err := archive.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, reader)
+	return err
+}
+
+// noteMarkdown renders a note as a Markdown document with a front matter block
+func noteMarkdown(note ArchiveNote) string {
+	var b strings.Builder
+	b.WriteString("---\n")
+	fmt.Fprintf(&b, "title: %q\n", note.Title)
+	if note.Author != "" {
+		fmt.Fprintf(&b, "author: %q\n", note.Author)
+	}
+	if note.Color != "" {
+		fmt.Fprintf(&b, "color: %q\n", note.Color)
+	}
+	fmt.Fprintf(&b, "created: %q\n", note.Created)
+	fmt.Fprintf(&b, "updated: %q\n", note.Updated)
+	b.WriteString("---\n\n")
+	if note.Title != "" {
+		b.WriteString("# " + note.Title + "\n\n")
+	}
+	b.WriteString(HTMLToMarkdown(note.Content))
+	return b.String()
+}
+
+// noteFileName turns a note title into a safe file name
+func noteFileName(title string) string {
+	name := NormalizeSlug(title)
+	if len(name) > 60 {
+		name = strings.Trim(name[:60], "-")
+	}
+	if name == "" {
+		return "note"
+	}
+	return name
+}
+
+// writeArchiveEntry adds a file to the archive
+func writeArchiveEntry(archive *zip.Writer, name string, content []byte) error {
+	w, err := archive.Create(name)
+	if err != nil {
+		return fmt.Errorf("failed to add %s to archive: %w", name, err)
+	}
+	if _, err := w.Write(content); err != nil {
+		return fmt.Errorf("failed to write %s to archive: %w", name, err)
+	}
+	return nil
+}
+
+// archiveRecordError reports records of the archive that fail validation as an invalid archive
+func archiveRecordError(what string, err error) error {
+	var validationErrs validation.Errors
+	if errors.As(err, &validationErrs) {
+		return fmt.Errorf("%w: %s: %v", ErrArchiveInvalid, what, validationErrs)
+	}
+	return fmt.Errorf("failed to import %s: %w", what, err)
+}
+
+// readArchiveJSON decodes a JSON entry of the archive
+func readArchiveJSON(archive *zip.Reader, name string, value any) error {
+	content, err := readArchiveFile(archive, name)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(content, value); err != nil {
+		return fmt.Errorf("%w: %s is malformed", ErrArchiveInvalid, name)
+	}
+	return nil
+}
+
+// readArchiveFile reads an entry of the archive, refusing entries larger than archiveMaxEntrySize
+func readArchiveFile(archive *zip.Reader, name string) ([]byte, error) {
+	file, err := archive.Open(name)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s is missing", ErrArchiveInvalid, name)
+	}
+	defer file.Close()
+
+	content, err := io.ReadAll(io.LimitReader(file, archiveMaxEntrySize+1))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s cannot be read", ErrArchiveInvalid, name)
+	}
+	if len(content) > archiveMaxEntrySize {
+		return nil, fmt.Errorf("%w: %s is too large", ErrArchiveInvalid, name)
+	}
+	return content, nil
+}
@@ -0,0 +1,333 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"unicode/utf8"
+
+	"pocketvue/config"
+	"pocketvue/constants"
+	"pocketvue/helpers"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// Audit events, actions are written as "<target>.<event>", for example "note.update"
+const (
+	AuditEventCreate  = "create"
+	AuditEventUpdate  = "update"
+	AuditEventDelete  = "delete"
+	AuditEventRestore = "restore"
+)
+
+const (
+	// auditValueMaxLength is the number of characters kept of a changed text value
+	auditValueMaxLength = 1000
+
+	// auditUserAgentMaxLength is the number of characters kept of a user agent
+	auditUserAgentMaxLength = 500
+)
+
+// auditTargets maps the audited collections to the target names used in actions
+var auditTargets = map[string]string{
+	constants.CollectionWorkspaces:       "workspace",
+	constants.CollectionNotes:            "note",
+	constants.CollectionWorkspaceMembers: "member",
+	constants.CollectionSubscriptions:    "subscription",
+}
+
+// AuditedCollections returns the collections whose changes are written to the audit log
+func AuditedCollections() []string {
+	collections := make([]string, 0, len(auditTargets))
+	for collection := range auditTargets {
+		collections = append(collections, collection)
+	}
+	return collections
+}
+
+// AuditChange is the value of a field before and after a change
+// Values of hidden fields are not stored, only the fact that they changed
+type AuditChange struct {
+	From     any  `json:"from"`
+	To       any  `json:"to"`
+	Redacted bool `json:"redacted,omitempty"`
+}
+
+// AuditActor is the user and client behind an audited change
+type AuditActor struct {
+	User      *core.Record
+	Email     string
+	IP        string
+	UserAgent string
+}
+
+// auditActorKey is the context key of the actor of the audited changes saved with a context
+type auditActorKey struct{}
+
+// AuditContext returns the request context carrying the authenticated user of the request as audit actor
+// Custom routes save audited records with it, so their changes are attributed to the user
+// Changes saved without an actor are logged as system changes
+func AuditContext(e *core.RequestEvent) context.Context {
+	return context.WithValue(e.Request.Context(), auditActorKey{}, newAuditActor(e))
+}
+
+// auditActorFromContext returns the actor carried by a context
+func auditActorFromContext(ctx context.Context) (AuditActor, bool) {
+	if ctx == nil {
+		return AuditActor{}, false
+	}
+	actor, ok := ctx.Value(auditActorKey{}).(AuditActor)
+	return actor, ok
+}
+
+// auditActors holds the actor of the records being saved by a records API request
+// PocketBase saves those records without the request context, so they are tracked by the record instance
+// of the request. Each request has its own instance, concurrent requests on the same record never share an entry
+var auditActors sync.Map
+
+// TrackAuditActor attributes the audited changes of the records to the authenticated user of a records API request
+// The returned function releases the records and must be called once they are saved.
+// Hooks that save other records or fetch them again pass the actor on with InheritAuditActor
+func TrackAuditActor(e *core.RequestEvent, records ...*core.Record) func() {
+	actor := newAuditActor(e)
+	for _, record := range records {
+		auditActors.Store(record, actor)
+	}
+
+	return func() {
+		for _, record := range records {
+			auditActors.Delete(record)
+		}
+	}
+}
+
+// InheritAuditActor returns ctx carrying the actor of the changes of a record,
+// for records that hooks save on behalf of a change made through the records API
+func InheritAuditActor(ctx context.Context, record *core.Record) context.Context {
+	if _, ok := auditActorFromContext(ctx); ok {
+		return ctx
+	}
+	if actor, ok := trackedAuditActor(record); ok {
+		return context.WithValue(ctx, auditActorKey{}, actor)
+	}
+	return ctx
+}
+
+// trackedAuditActor returns the actor tracked by TrackAuditActor for a record
+func trackedAuditActor(record *core.Record) (AuditActor, bool) {
+	value, ok := auditActors.Load(record)
+	if !ok {
+		return AuditActor{}, false
+	}
+	return value.(AuditActor), true
+}
+
+// newAuditActor returns the authenticated user and client of a request
+func newAuditActor(e *core.RequestEvent) AuditActor {
+	actor := AuditActor{
+		IP:        e.RealIP(),
+		UserAgent: truncateAuditText(e.Request.UserAgent(), auditUserAgentMaxLength),
+	}
+	if e.Auth != nil {
+		actor.Email = e.Auth.Email()
+		// Superusers are not users, only their email is kept
+		if e.Auth.Collection().Name == constants.CollectionUsers {
+			actor.User = e.Auth
+		}
+	}
+	return actor
+}
+
+// AuditService writes and prunes the audit log of workspaces
+type AuditService struct {
+	app core.App
+}
+
+// NewAuditService creates a new audit service
+func NewAuditService(app core.App) *AuditService {
+	return &AuditService{app: app}
+}
+
+// Log writes an audit entry for a change of a record of an audited collection
+// ctx is the context the record was saved with, it carries the actor of changes made by custom routes
+// original is the record before an update and is ignored for other events
+// Changes of deleted workspaces and updates without changed fields are not logged
+func (as *AuditService) Log(ctx context.Context, record *core.Record, event string, original *core.Record) error {
+	target, ok := auditTargets[record.Collection().Name]
+	if !ok {
+		return nil
+	}
+
+	workspaceID := record.GetString("workspace")
+	if target == auditTargets[constants.CollectionWorkspaces] {
+		workspaceID = record.Id
+	} else if workspaceID != "" {
+		// Purging a deleted workspace removes its records and its audit log together
+		workspace, err := as.app.FindRecordById(constants.CollectionWorkspaces, workspaceID)
+		if err != nil || helpers.IsWorkspaceDeleted(workspace) {
+			return nil
+		}
+	}
+	// Personal subscriptions do not belong to a workspace
+	if workspaceID == "" {
+		return nil
+	}
+
+	changes := auditChanges(record, event, original)
+	if event == AuditEventUpdate {
+		if len(changes) == 0 {
+			return nil
+		}
+		if change, ok := changes["deleted_at"]; ok && target == auditTargets[constants.CollectionWorkspaces] {
+			event = AuditEventDelete
+			if change.To == "" {
+				event = AuditEventRestore
+			}
+		}
+	}
+
+	collection, err := as.app.FindCollectionByNameOrId(constants.CollectionAuditLogs)
+	if err != nil {
+		return err
+	}
+
+	entry := core.NewRecord(collection)
+	entry.Set("workspace", workspaceID)
+	entry.Set("action", target+"."+event)
+	entry.Set("target_type", target)
+	entry.Set("target_id", record.Id)
+	entry.Set("changes", changes)
+
+	actor, ok := auditActorFromContext(ctx)
+	if !ok {
+		actor, ok = trackedAuditActor(record)
+	}
+	if ok {
+		if actor.User != nil {
+			entry.Set("actor", actor.User.Id)
+		}
+		entry.Set("actor_email", actor.Email)
+		entry.Set("ip", actor.IP)
+		entry.Set("user_agent", actor.UserAgent)
+	}
+
+	return as.app.Save(entry)
+}
+
+// PruneExpired deletes audit entries older than the retention period
+// and entries left behind by workspaces deleted outside the API
+func (as *AuditService) PruneExpired() {
+	if retention := config.Current().AuditLogRetention; retention > 0 {
+		cutoff := types.NowDateTime().Add(-retention).String()
+		result, err := as.app.DB().Delete(constants.CollectionAuditLogs, dbx.NewExp(
+			"created < {:cutoff}",
+			dbx.Params{"cutoff": cutoff},
+		)).Execute()
+		if err != nil {
+			log.Printf("Error pruning audit logs: %v", err)
+		} else if count, _ := result.RowsAffected(); count > 0 {
+			log.Printf("Pruned %d audit log entries older than %s", count, retention)
+		}
+	}
+
+	if _, err := as.app.DB().Delete(constants.CollectionAuditLogs, dbx.NewExp(
+		fmt.Sprintf("workspace NOT IN (SELECT id FROM %s)", constants.CollectionWorkspaces),
+	)).Execute(); err != nil {
+		log.Printf("Error pruning orphaned audit logs: %v", err)
+	}
+}
+
+// auditChanges returns the changed fields of a record
+// Created records list their set fields, deleted records the fields they had
+func auditChanges(record *core.Record, event string, original *core.Record) map[string]AuditChange {
+	changes := map[string]AuditChange{}
+
+	for _, field := range record.Collection().Fields {
+		name := field.GetName()
+		if name == "id" || field.Type() == core.FieldTypeAutodate || field.Type() == core.FieldTypePassword {
+			continue
+		}
+
+		var change AuditChange
+		switch event {
+		case AuditEventCreate:
+			if isEmptyAuditValue(record.Get(name)) {
+				continue
+			}
+			change.To = auditValue(record.Get(name))
+		case AuditEventDelete:
+			if isEmptyAuditValue(record.Get(name)) {
+				continue
+			}
+			change.From = auditValue(record.Get(name))
+		default:
+			if original == nil || equalAuditValues(original.Get(name), record.Get(name)) {
+				continue
+			}
+			change.From = auditValue(original.Get(name))
+			change.To = auditValue(record.Get(name))
+		}
+
+		if field.GetHidden() {
+			change = AuditChange{Redacted: true}
+		}
+		changes[name] = change
+	}
+
+	return changes
+}
+
+// auditValue converts a field value to the value stored in the audit log, long texts are cut
+func auditValue(value any) any {
+	switch v := value.(type) {
+	case string:
+		return truncateAuditText(v, auditValueMaxLength)
+	case types.DateTime:
+		return v.String()
+	}
+	return value
+}
+
+// equalAuditValues compares two field values by their JSON representation
+func equalAuditValues(a, b any) bool {
+	encodedA, errA := json.Marshal(a)
+	encodedB, errB := json.Marshal(b)
+	return errA == nil && errB == nil && bytes.Equal(encodedA, encodedB)
+}
+
+// isEmptyAuditValue reports whether a field value is the zero value of its field
+func isEmptyAuditValue(value any) bool {
+	switch v := value.(type) {
+	case nil:
+		return true
+	case string:
+		return v == ""
+	case bool:
+		return !v
+	case float64:
+		return v == 0
+	case int:
+		return v == 0
+	case []string:
+		return len(v) == 0
+	case types.DateTime:
+		return v.IsZero()
+	case types.JSONRaw:
+		return len(v) == 0 || string(v) == "null"
+	}
+	return false
+}
+
+// truncateAuditText cuts a text to max characters
+func truncateAuditText(text string, max int) string {
+	if utf8.RuneCountInString(text) <= max {
+		return text
+	}
+	return string([]rune(text)[:max]) + "…"
+}
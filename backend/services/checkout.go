@@ -0,0 +1,141 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"pocketvue/constants"
+	"pocketvue/types"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// Checkout statuses stored in the checkouts.status field (same values as Polar)
+const (
+	CheckoutStatusOpen      = "open"
+	CheckoutStatusConfirmed = "confirmed"
+	CheckoutStatusSucceeded = "succeeded"
+	CheckoutStatusFailed    = "failed"
+	CheckoutStatusExpired   = "expired"
+)
+
+// checkoutFinalStatuses are statuses a checkout never leaves
+var checkoutFinalStatuses = map[string]bool{
+	CheckoutStatusSucceeded: true,
+	CheckoutStatusExpired:   true,
+}
+
+// CheckoutService persists Polar checkout sessions so their outcome can be tracked
+type CheckoutService struct {
+	app core.App
+}
+
+// NewCheckoutService creates a new checkout service instance
+func NewCheckoutService(app core.App) *CheckoutService {
+	return &CheckoutService{
+		app: app,
+	}
+}
+
+// Track stores a newly created checkout session
+// A checkout.created webhook may have recorded the session first, its status is then kept
+func (cs *CheckoutService) Track(session *CheckoutSession, params CheckoutParams) error {
+	record, err := cs.app.FindRecordById(constants.CollectionCheckouts, session.ID)
+	if err != nil {
+		collection, err := cs.app.FindCollectionByNameOrId(constants.CollectionCheckouts)
+		if err != nil {
+			return fmt.Errorf("failed to find checkouts collection: %w", err)
+		}
+
+		status := session.Status
+		if status == "" {
+			status = CheckoutStatusOpen
+		}
+
+		record = core.NewRecord(collection)
+		record.Set("id", session.ID)
+		record.Set("status", status)
+	}
+
+	record.Set("user", params.UserID)
+	record.Set("products", params.ProductIDs)
+	if record.GetDateTime("expires_at").IsZero() {
+		record.Set("expires_at", session.ExpiresAt)
+	}
+	if params.WorkspaceID != "" {
+		record.Set("workspace", params.WorkspaceID)
+	}
+
+	if err := cs.app.Save(record); err != nil {
+		return fmt.Errorf("failed to save checkout %s: %w", session.ID, err)
+	}
+
+	return nil
+}
+
+// ApplyWebhook updates a tracked checkout from a checkout.created or checkout.updated event
+// Checkouts created outside of the app are recorded when their customer can be matched to a user
+func (cs *CheckoutService) ApplyWebhook(data types.CheckoutWebhookData) error {
+	record, err := cs.app.FindRecordById(constants.CollectionCheckouts, data.ID)
+	if err != nil {
+		if data.ExternalCustomerID == nil || *data.ExternalCustomerID == "" {
+			log.Printf("Warning: checkout %s is not tracked and has no external_customer_id", data.ID)
+			return nil
+		}
+
+		user, err := cs.app.FindRecordById(constants.CollectionUsers, *data.ExternalCustomerID)
+		if err != nil {
+			log.Printf("Warning: user not found for checkout %s: %v", data.ID, err)
+			return nil
+		}
+
+		collection, err := cs.app.FindCollectionByNameOrId(constants.CollectionCheckouts)
+		if err != nil {
+			return fmt.Errorf("failed to find checkouts collection: %w", err)
+		}
+
+		record = core.NewRecord(collection)
+		record.Set("id", data.ID)
+		record.Set("user", user.Id)
+		record.Set("status", CheckoutStatusOpen)
+		if data.ProductID != "" {
+			record.Set("products", []string{data.ProductID})
+		}
+		if workspaceID, ok := data.Metadata["workspace_id"].(string); ok && workspaceID != "" {
+			if _, err := cs.app.FindRecordById(constants.CollectionWorkspaces, workspaceID); err == nil {
+				record.Set("workspace", workspaceID)
+			}
+		}
+	}
+
+	// Webhooks may arrive out of order, never move a checkout out of a final status
+	current := record.GetString("status")
+	if checkoutFinalStatuses[current] && current != data.Status {
+		log.Printf("Ignoring checkout %s status change from %s to %s", data.ID, current, data.Status)
+		return nil
+	}
+
+	record.Set("status", data.Status)
+	if !data.ExpiresAt.IsZero() {
+		record.Set("expires_at", data.ExpiresAt)
+	}
+	if data.Status == CheckoutStatusSucceeded || data.Status == CheckoutStatusFailed || data.Status == CheckoutStatusExpired {
+		record.Set("completed_at", data.ModifiedAt)
+	}
+
+	if err := cs.app.Save(record); err != nil {
+		return fmt.Errorf("failed to update checkout %s: %w", data.ID, err)
+	}
+
+	return nil
+}
+
+// EffectiveCheckoutStatus returns the status of a checkout, reporting open checkouts past their expiry as expired
+func EffectiveCheckoutStatus(record *core.Record) string {
+	status := record.GetString("status")
+	expiresAt := record.GetDateTime("expires_at")
+	if status == CheckoutStatusOpen && !expiresAt.IsZero() && expiresAt.Time().Before(time.Now()) {
+		return CheckoutStatusExpired
+	}
+	return status
+}
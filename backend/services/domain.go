@@ -0,0 +1,321 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"pocketvue/config"
+	"pocketvue/constants"
+	"pocketvue/helpers"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/security"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+const (
+	// domainVerificationPrefix is the label of the TXT record checked for a custom domain
+	domainVerificationPrefix = "_pocketvue-verification"
+
+	// domainVerificationValuePrefix starts the value of the verification TXT record
+	domainVerificationValuePrefix = "pocketvue-verification="
+
+	// domainLookupTimeout bounds a single DNS lookup during verification
+	domainLookupTimeout = 10 * time.Second
+
+	// domainCacheTTL is how long host to workspace lookups are cached
+	domainCacheTTL = time.Minute
+
+	// domainCacheMaxEntries bounds the cache since hosts come from request headers
+	domainCacheMaxEntries = 10000
+)
+
+var (
+	// ErrInvalidDomain is returned when a custom domain is not a valid hostname
+	ErrInvalidDomain = errors.New("invalid domain, use a hostname such as app.example.com")
+
+	// ErrDomainTaken is returned when another workspace already verified the custom domain
+	ErrDomainTaken = errors.New("this domain is already used by another workspace")
+
+	// ErrDomainNotConfigured is returned when verifying a workspace without a custom domain
+	ErrDomainNotConfigured = errors.New("no custom domain is configured for this workspace")
+
+	// ErrDomainVerificationFailed is returned when the verification TXT record is missing or wrong
+	ErrDomainVerificationFailed = errors.New("the verification TXT record was not found")
+
+	// ErrDomainLookupFailed is returned when DNS could not be queried
+	ErrDomainLookupFailed = errors.New("failed to look up DNS records, try again later")
+)
+
+var hostnamePattern = regexp.MustCompile(`^(?:[a-z0-9](?:[a-z0-9-]{0,61}[a-z0-9])?\.)+[a-z][a-z0-9-]{0,61}[a-z0-9]$`)
+
+// TXTResolver looks up the TXT records of a DNS name
+// *net.Resolver implements it, tests can provide a fake
+type TXTResolver interface {
+	LookupTXT(ctx context.Context, name string) ([]string, error)
+}
+
+// DefaultTXTResolver is the resolver used by NewDomainService
+var DefaultTXTResolver TXTResolver = net.DefaultResolver
+
+// DomainVerification describes the DNS record a customer must create to verify a custom domain
+type DomainVerification struct {
+	Domain      string `json:"domain"`
+	Verified    bool   `json:"verified"`
+	VerifiedAt  string `json:"verified_at,omitempty"`
+	RecordType  string `json:"record_type"`
+	RecordName  string `json:"record_name"`
+	RecordValue string `json:"record_value"`
+}
+
+// DomainService manages custom domains of workspaces and resolves request hosts to workspaces
+type DomainService struct {
+	app      core.App
+	resolver TXTResolver
+}
+
+// NewDomainService creates a new domain service instance using DefaultTXTResolver
+func NewDomainService(app core.App) *DomainService {
+	return NewDomainServiceWithResolver(app, DefaultTXTResolver)
+}
+
+// NewDomainServiceWithResolver creates a new domain service instance with a custom resolver
+func NewDomainServiceWithResolver(app core.App, resolver TXTResolver) *DomainService {
+	return &DomainService{app: app, resolver: resolver}
+}
+
+// NormalizeDomain turns user input such as "https://App.Example.com:443/path" into "app.example.com"
+func NormalizeDomain(input string) (string, error) {
+	domain := strings.ToLower(strings.TrimSpace(input))
+	if strings.Contains(domain, "://") {
+		parsed, err := url.Parse(domain)
+		if err != nil {
+			return "", ErrInvalidDomain
+		}
+		domain = parsed.Host
+	}
+	domain = strings.SplitN(domain, "/", 2)[0]
+	if host, _, err := net.SplitHostPort(domain); err == nil {
+		domain = host
+	}
+	domain = strings.TrimSuffix(domain, ".")
+
+	if len(domain) > 253 || !hostnamePattern.MatchString(domain) {
+		return "", ErrInvalidDomain
+	}
+	return domain, nil
+}
+
+// SetDomain assigns a custom domain to a workspace and issues a new verification token
+// The domain is unverified until Verify finds the TXT record, unverified claims never block other workspaces
+func (ds *DomainService) SetDomain(ctx context.Context, workspace *core.Record, input string) error {
+	domain, err := NormalizeDomain(input)
+	if err != nil {
+		return err
+	}
+
+	if frontend, err := url.Parse(config.Current().FrontendURL); err == nil && strings.EqualFold(frontend.Hostname(), domain) {
+		return ErrInvalidDomain
+	}
+
+	if err := ds.checkAvailable(workspace, domain); err != nil {
+		return err
+	}
+
+	workspace.Set("custom_domain", domain)
+	workspace.Set("domain_verification_token", security.RandomString(32))
+	workspace.Set("domain_verified", false)
+	workspace.Set("domain_verified_at", "")
+
+	if err := ds.app.SaveWithContext(ctx, workspace); err != nil {
+		return fmt.Errorf("failed to save domain of workspace %s: %w", workspace.Id, err)
+	}
+	return nil
+}
+
+// checkAvailable returns ErrDomainTaken if another workspace verified the domain
+func (ds *DomainService) checkAvailable(workspace *core.Record, domain string) error {
+	existing, err := ds.app.FindFirstRecordByFilter(
+		constants.CollectionWorkspaces,
+		"custom_domain = {:domain} && domain_verified = true && id != {:id}",
+		dbx.Params{"domain": domain, "id": workspace.Id},
+	)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("failed to check domain %s: %w", domain, err)
+	}
+	if existing != nil {
+		return ErrDomainTaken
+	}
+	return nil
+}
+
+// RemoveDomain removes the custom domain of a workspace
+func (ds *DomainService) RemoveDomain(ctx context.Context, workspace *core.Record) error {
+	workspace.Set("custom_domain", "")
+	workspace.Set("domain_verification_token", "")
+	workspace.Set("domain_verified", false)
+	workspace.Set("domain_verified_at", "")
+
+	if err := ds.app.SaveWithContext(ctx, workspace); err != nil {
+		return fmt.Errorf("failed to remove domain of workspace %s: %w", workspace.Id, err)
+	}
+	return nil
+}
+
+// Verify looks up the verification TXT record of the workspace domain and marks the domain verified when it matches
+func (ds *DomainService) Verify(ctx context.Context, workspace *core.Record) error {
+	domain := workspace.GetString("custom_domain")
+	token := workspace.GetString("domain_verification_token")
+	if domain == "" || token == "" {
+		return ErrDomainNotConfigured
+	}
+
+	lookupCtx, cancel := context.WithTimeout(ctx, domainLookupTimeout)
+	defer cancel()
+
+	records, err := ds.resolver.LookupTXT(lookupCtx, verificationRecordName(domain))
+	if err != nil {
+		var dnsErr *net.DNSError
+		if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+			return ErrDomainVerificationFailed
+		}
+		return fmt.Errorf("%w: %v", ErrDomainLookupFailed, err)
+	}
+
+	expected := domainVerificationValuePrefix + token
+	found := false
+	for _, record := range records {
+		if strings.TrimSpace(record) == expected {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return ErrDomainVerificationFailed
+	}
+
+	// Another workspace may have verified the domain since it was claimed
+	if err := ds.checkAvailable(workspace, domain); err != nil {
+		return err
+	}
+
+	workspace.Set("domain_verified", true)
+	workspace.Set("domain_verified_at", types.NowDateTime())
+
+	if err := ds.app.SaveWithContext(ctx, workspace); err != nil {
+		return fmt.Errorf("failed to mark domain of workspace %s verified: %w", workspace.Id, err)
+	}
+	return nil
+}
+
+// Verification returns the domain state of a workspace and the TXT record that verifies it
+func (ds *DomainService) Verification(workspace *core.Record) DomainVerification {
+	domain := workspace.GetString("custom_domain")
+	verification := DomainVerification{
+		Domain:     domain,
+		Verified:   workspace.GetBool("domain_verified"),
+		RecordType: "TXT",
+	}
+	if !workspace.GetDateTime("domain_verified_at").IsZero() {
+		verification.VerifiedAt = workspace.GetDateTime("domain_verified_at").String()
+	}
+	if domain != "" {
+		verification.RecordName = verificationRecordName(domain)
+		verification.RecordValue = domainVerificationValuePrefix + workspace.GetString("domain_verification_token")
+	}
+	return verification
+}
+
+// FindWorkspaceByHost returns the workspace whose verified custom domain is the host, or nil
+// Results, including misses, are cached briefly since every request goes through this lookup
+func (ds *DomainService) FindWorkspaceByHost(host string) (*core.Record, error) {
+	domain, err := NormalizeDomain(host)
+	if err != nil {
+		return nil, nil
+	}
+
+	if workspaceID, ok := domainCache.get(domain); ok {
+		if workspaceID == "" {
+			return nil, nil
+		}
+		workspace, err := ds.app.FindRecordById(constants.CollectionWorkspaces, workspaceID)
+		if err == nil && !helpers.IsWorkspaceDeleted(workspace) {
+			return workspace, nil
+		}
+	}
+
+	workspace, err := ds.app.FindFirstRecordByFilter(
+		constants.CollectionWorkspaces,
+		"custom_domain = {:domain} && domain_verified = true && deleted_at = ''",
+		dbx.Params{"domain": domain},
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			domainCache.set(domain, "")
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to find workspace for host %s: %w", domain, err)
+	}
+
+	domainCache.set(domain, workspace.Id)
+	return workspace, nil
+}
+
+// InvalidateDomainCache drops the cached host lookups, call it when custom domains change
+func InvalidateDomainCache() {
+	domainCache.clear()
+}
+
+// verificationRecordName returns the DNS name of the verification TXT record of a domain
+func verificationRecordName(domain string) string {
+	return domainVerificationPrefix + "." + domain
+}
+
+// domainCache maps hosts to workspace ids, an empty id caches a miss
+var domainCache = &hostCache{entries: map[string]hostCacheEntry{}}
+
+type hostCacheEntry struct {
+	workspaceID string
+	expires     time.Time
+}
+
+type hostCache struct {
+	mu      sync.RWMutex
+	entries map[string]hostCacheEntry
+}
+
+func (c *hostCache) get(host string) (string, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	entry, ok := c.entries[host]
+	if !ok || time.Now().After(entry.expires) {
+		return "", false
+	}
+	return entry.workspaceID, true
+}
+
+func (c *hostCache) set(host, workspaceID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.entries) >= domainCacheMaxEntries {
+		c.entries = map[string]hostCacheEntry{}
+	}
+	c.entries[host] = hostCacheEntry{workspaceID: workspaceID, expires: time.Now().Add(domainCacheTTL)}
+}
+
+func (c *hostCache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries = map[string]hostCacheEntry{}
+}
@@ -0,0 +1,388 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"html/template"
+	"log"
+	"net/mail"
+	"net/url"
+	"pocketvue/config"
+	"pocketvue/constants"
+	"pocketvue/helpers"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/mailer"
+	"github.com/pocketbase/pocketbase/tools/security"
+)
+
+// Workspace invite statuses stored in the workspace_invites.status field
+const (
+	InviteStatusPending  = "pending"
+	InviteStatusAccepted = "accepted"
+	InviteStatusRevoked  = "revoked"
+)
+
+const (
+	// inviteTokenType identifies invite tokens among the other JWTs issued by the app
+	inviteTokenType = "workspaceInvite"
+
+	// inviteResendCooldown is the minimum delay between two emails for the same invite
+	inviteResendCooldown = time.Minute
+)
+
+var (
+	// ErrInviteInvalid is returned for unknown, expired, revoked or already used invite tokens
+	ErrInviteInvalid = errors.New("invite is invalid or has expired")
+
+	// ErrInviteEmailMismatch is returned when an invite is accepted from an account with another email
+	ErrInviteEmailMismatch = errors.New("invite was sent to a different email address")
+
+	// ErrAlreadyMember is returned when the invited user already belongs to the workspace
+	ErrAlreadyMember = errors.New("user is already a member of this workspace")
+
+	// ErrAlreadyInvited is returned when the email already has a pending invite to the workspace
+	ErrAlreadyInvited = errors.New("email already has a pending invite to this workspace")
+)
+
+// InviteRateLimitError is returned when too many invite emails are sent
+type InviteRateLimitError struct {
+	RetryAfter time.Duration
+}
+
+func (e *InviteRateLimitError) Error() string {
+	return fmt.Sprintf("too many invites sent, try again in %s", e.RetryAfter.Round(time.Second))
+}
+
+// inviteLimiter tracks invite emails sent per user over the last hour
+// It is shared by every InviteService so the limit applies across requests
+var inviteLimiter = &sendLimiter{sent: map[string][]time.Time{}}
+
+type sendLimiter struct {
+	mu   sync.Mutex
+	sent map[string][]time.Time
+}
+
+// take records a send for the user, or returns the delay until the next send is allowed
+func (l *sendLimiter) take(userID string, limit int) (time.Duration, bool) {
+	if limit <= 0 {
+		return 0, true
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	windowStart := now.Add(-time.Hour)
+
+	recent := l.sent[userID][:0]
+	for _, t := range l.sent[userID] {
+		if t.After(windowStart) {
+			recent = append(recent, t)
+		}
+	}
+
+	if len(recent) >= limit {
+		l.sent[userID] = recent
+		return recent[0].Sub(windowStart), false
+	}
+
+	l.sent[userID] = append(recent, now)
+	return 0, true
+}
+
+// InviteService invites users to workspaces by email
+type InviteService struct {
+	app core.App
+}
+
+// NewInviteService creates a new invite service instance
+func NewInviteService(app core.App) *InviteService {
+	return &InviteService{
+		app: app,
+	}
+}
+
+// Invite creates a pending invite for the email and sends it
+// An expired pending invite for the same email is replaced
+func (is *InviteService) Invite(workspace, inviter *core.Record, email, role string) (*core.Record, error) {
+	email = normalizeEmail(email)
+
+	if user, err := is.app.FindAuthRecordByEmail(constants.CollectionUsers, email); err == nil {
+		member, err := helpers.FindWorkspaceMember(is.app, workspace.Id, user.Id)
+		if err != nil {
+			return nil, err
+		}
+		if member != nil {
+			return nil, ErrAlreadyMember
+		}
+	}
+
+	existing, err := is.app.FindFirstRecordByFilter(
+		constants.CollectionWorkspaceInvites,
+		"workspace = {:workspace} && email = {:email} && status = {:status}",
+		dbx.Params{"workspace": workspace.Id, "email": email, "status": InviteStatusPending},
+	)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("failed to look up invites of workspace %s: %w", workspace.Id, err)
+	}
+	if existing != nil {
+		if !isInviteExpired(existing) {
+			return nil, ErrAlreadyInvited
+		}
+		if err := is.Revoke(existing); err != nil {
+			return nil, err
+		}
+	}
+
+	if retryAfter, ok := inviteLimiter.take(inviter.Id, config.Current().WorkspaceInviteHourlyLimit); !ok {
+		return nil, &InviteRateLimitError{RetryAfter: retryAfter}
+	}
+
+	collection, err := is.app.FindCollectionByNameOrId(constants.CollectionWorkspaceInvites)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find workspace_invites collection: %w", err)
+	}
+
+	invite := core.NewRecord(collection)
+	invite.Set("workspace", workspace.Id)
+	invite.Set("email", email)
+	invite.Set("role", role)
+	invite.Set("invited_by", inviter.Id)
+	invite.Set("status", InviteStatusPending)
+
+	if err := is.send(invite, workspace, inviter); err != nil {
+		// Do not keep an invite nobody received, it would block inviting the email again
+		if !invite.IsNew() {
+			if deleteErr := is.app.Delete(invite); deleteErr != nil {
+				log.Printf("Error deleting unsent invite %s: %v", invite.Id, deleteErr)
+			}
+		}
+		return nil, err
+	}
+
+	return invite, nil
+}
+
+// Resend rotates the token of a pending invite, extends its expiry and emails it again
+// Links from previous emails stop working
+func (is *InviteService) Resend(invite, workspace, sender *core.Record) error {
+	if invite.GetString("status") != InviteStatusPending {
+		return ErrInviteInvalid
+	}
+
+	if wait := time.Until(invite.GetDateTime("sent_at").Time().Add(inviteResendCooldown)); wait > 0 {
+		return &InviteRateLimitError{RetryAfter: wait}
+	}
+
+	if retryAfter, ok := inviteLimiter.take(sender.Id, config.Current().WorkspaceInviteHourlyLimit); !ok {
+		return &InviteRateLimitError{RetryAfter: retryAfter}
+	}
+
+	return is.send(invite, workspace, sender)
+}
+
+// Revoke cancels a pending invite, its link stops working immediately
+func (is *InviteService) Revoke(invite *core.Record) error {
+	if invite.GetString("status") != InviteStatusPending {
+		return ErrInviteInvalid
+	}
+
+	invite.Set("status", InviteStatusRevoked)
+	invite.Set("token_key", security.RandomString(50))
+
+	if err := is.app.Save(invite); err != nil {
+		return fmt.Errorf("failed to revoke invite %s: %w", invite.Id, err)
+	}
+
+	return nil
+}
+
+// FindByToken returns the pending invite a token was issued for
+func (is *InviteService) FindByToken(token string) (*core.Record, error) {
+	claims, err := security.ParseUnverifiedJWT(token)
+	if err != nil || claims["type"] != inviteTokenType {
+		return nil, ErrInviteInvalid
+	}
+
+	inviteID, _ := claims["id"].(string)
+	invite, err := is.app.FindRecordById(constants.CollectionWorkspaceInvites, inviteID)
+	if err != nil {
+		return nil, ErrInviteInvalid
+	}
+
+	// The signing key is rotated on resend, revoke and accept, which makes every token single-use
+	if _, err := security.ParseJWT(token, invite.GetString("token_key")); err != nil {
+		return nil, ErrInviteInvalid
+	}
+
+	if invite.GetString("status") != InviteStatusPending || isInviteExpired(invite) {
+		return nil, ErrInviteInvalid
+	}
+
+	return invite, nil
+}
+
+// Accept adds the user to the workspace of the invite with the invited role
+// The user must be signed in with the invited email, which also verifies a newly registered account
+func (is *InviteService) Accept(ctx context.Context, token string, user *core.Record) (*core.Record, error) {
+	invite, err := is.FindByToken(token)
+	if err != nil {
+		return nil, err
+	}
+
+	if normalizeEmail(user.GetString("email")) != invite.GetString("email") {
+		return nil, ErrInviteEmailMismatch
+	}
+
+	workspaceID := invite.GetString("workspace")
+	existing, err := helpers.FindWorkspaceMember(is.app, workspaceID, user.Id)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, ErrAlreadyMember
+	}
+
+	var member *core.Record
+	err = is.app.RunInTransaction(func(txApp core.App) error {
+		collection, err := txApp.FindCollectionByNameOrId(constants.CollectionWorkspaceMembers)
+		if err != nil {
+			return err
+		}
+
+		member = core.NewRecord(collection)
+		member.Set("workspace", workspaceID)
+		member.Set("user", user.Id)
+		member.Set("role", invite.GetString("role"))
+		if err := txApp.SaveWithContext(ctx, member); err != nil {
+			return err
+		}
+
+		invite.Set("status", InviteStatusAccepted)
+		invite.Set("accepted_by", user.Id)
+		invite.Set("accepted_at", time.Now())
+		invite.Set("token_key", security.RandomString(50))
+		if err := txApp.Save(invite); err != nil {
+			return err
+		}
+
+		// Opening the emailed link proves ownership of the address
+		if !user.Verified() {
+			user.SetVerified(true)
+			if err := txApp.Save(user); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("User %s joined workspace %s as %s", user.Id, workspaceID, invite.GetString("role"))
+	return member, nil
+}
+
+// send issues a new token for the invite, saves it and emails the invite link
+func (is *InviteService) send(invite, workspace, sender *core.Record) error {
+	invite.Set("token_key", security.RandomString(50))
+	invite.Set("expires_at", time.Now().Add(config.Current().WorkspaceInviteTTL))
+	invite.Set("sent_at", time.Now())
+	invite.Set("send_count", invite.GetInt("send_count")+1)
+
+	if err := is.app.Save(invite); err != nil {
+		return fmt.Errorf("failed to save invite: %w", err)
+	}
+
+	token, err := security.NewJWT(jwt.MapClaims{
+		"id":   invite.Id,
+		"type": inviteTokenType,
+	}, invite.GetString("token_key"), config.Current().WorkspaceInviteTTL)
+	if err != nil {
+		return fmt.Errorf("failed to sign invite token: %w", err)
+	}
+
+	link := helpers.BuildWorkspaceURL(workspace.GetString("slug"), "/invite?token="+url.QueryEscape(token))
+
+	html, err := renderInviteEmail(inviteEmailData{
+		AppName:       is.app.Settings().Meta.AppName,
+		WorkspaceName: workspace.GetString("name"),
+		InviterName:   inviterName(sender),
+		Role:          invite.GetString("role"),
+		Link:          link,
+		ExpiresAt:     invite.GetDateTime("expires_at").Time().Format("January 2, 2006"),
+	})
+	if err != nil {
+		return err
+	}
+
+	message := &mailer.Message{
+		From: mail.Address{
+			Address: is.app.Settings().Meta.SenderAddress,
+			Name:    is.app.Settings().Meta.SenderName,
+		},
+		To:      []mail.Address{{Address: invite.GetString("email")}},
+		Subject: fmt.Sprintf("You're invited to join %s", workspace.GetString("name")),
+		HTML:    html,
+	}
+
+	if err := is.app.NewMailClient().Send(message); err != nil {
+		return fmt.Errorf("failed to send invite email: %w", err)
+	}
+
+	log.Printf("Sent invite %s for workspace %s", invite.Id, workspace.Id)
+	return nil
+}
+
+// inviteEmailData holds the values rendered in the invite email
+type inviteEmailData struct {
+	AppName       string
+	WorkspaceName string
+	InviterName   string
+	Role          string
+	Link          string
+	ExpiresAt     string
+}
+
+var inviteEmailTemplate = template.Must(template.New("invite").Parse(`<p>Hello,</p>
+<p>{{.InviterName}} invited you to join the <strong>{{.WorkspaceName}}</strong> workspace on {{.AppName}} as {{.Role}}.</p>
+<p><a href="{{.Link}}" target="_blank" rel="noopener">Accept the invite</a></p>
+<p>The invite expires on {{.ExpiresAt}}. If you were not expecting it, you can ignore this email.</p>
+<p>Thanks,<br/>{{.AppName}} team</p>`))
+
+// renderInviteEmail renders the HTML body of an invite email
+func renderInviteEmail(data inviteEmailData) (string, error) {
+	var buf bytes.Buffer
+	if err := inviteEmailTemplate.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("failed to render invite email: %w", err)
+	}
+	return buf.String(), nil
+}
+
+// inviterName returns the display name of the user sending an invite
+func inviterName(user *core.Record) string {
+	if name := user.GetString("name"); name != "" {
+		return name
+	}
+	return user.Email()
+}
+
+// isInviteExpired reports whether the invite link is past its expiry
+func isInviteExpired(invite *core.Record) bool {
+	return !invite.GetDateTime("expires_at").Time().After(time.Now())
+}
+
+// normalizeEmail lowercases and trims an email address so invites match regardless of casing
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
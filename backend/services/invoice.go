@@ -0,0 +1,176 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"pocketvue/constants"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/filesystem"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// Invoice statuses stored in the orders.invoice_status field
+const (
+	InvoiceStatusPending = "pending"
+	InvoiceStatusReady   = "ready"
+	InvoiceStatusFailed  = "failed"
+)
+
+const (
+	// maxInvoiceAttempts is the number of fetch attempts before an invoice is marked as failed
+	maxInvoiceAttempts = 10
+
+	// maxInvoiceBackoff caps the delay between two fetch attempts
+	maxInvoiceBackoff = 6 * time.Hour
+
+	// invoiceDownloadTimeout limits how long a single PDF download may take
+	invoiceDownloadTimeout = 30 * time.Second
+)
+
+// InvoiceService retrieves order invoices from Polar and stores them in PocketBase
+type InvoiceService struct {
+	app   core.App
+	polar *PolarService
+}
+
+// NewInvoiceService creates a new invoice service instance
+func NewInvoiceService(app core.App) *InvoiceService {
+	return &InvoiceService{
+		app:   app,
+		polar: NewPolarService(),
+	}
+}
+
+// FetchInvoiceAsync fetches the invoice of an order in the background
+func (is *InvoiceService) FetchInvoiceAsync(orderID string) {
+	go func() {
+		if err := is.FetchInvoice(orderID); err != nil {
+			log.Printf("Error fetching invoice for order %s: %v", orderID, err)
+		}
+	}()
+}
+
+// FetchInvoice downloads the invoice PDF of an order into its invoice file field
+// If Polar is still generating the invoice, the order is scheduled for a retry
+func (is *InvoiceService) FetchInvoice(orderID string) error {
+	order, err := is.app.FindRecordById(constants.CollectionOrders, orderID)
+	if err != nil {
+		return fmt.Errorf("failed to find order %s: %w", orderID, err)
+	}
+
+	if order.GetString("invoice_status") == InvoiceStatusReady {
+		return nil
+	}
+
+	invoiceURL, err := is.polar.GetOrderInvoiceURL(orderID)
+	if errors.Is(err, ErrInvoicePending) {
+		// Request generation (no-op if it was already requested) and try again later
+		if genErr := is.polar.GenerateOrderInvoice(orderID); genErr != nil {
+			return is.scheduleRetry(order, genErr)
+		}
+		return is.scheduleRetry(order, err)
+	}
+	if err != nil {
+		return is.scheduleRetry(order, err)
+	}
+
+	file, err := downloadInvoice(invoiceURL, fmt.Sprintf("invoice-%s.pdf", orderID))
+	if err != nil {
+		return is.scheduleRetry(order, err)
+	}
+
+	order.Set("invoice", file)
+	order.Set("invoice_status", InvoiceStatusReady)
+	order.Set("invoice_error", "")
+	order.Set("invoice_next_attempt", nil)
+
+	if err := is.app.Save(order); err != nil {
+		return fmt.Errorf("failed to save invoice for order %s: %w", orderID, err)
+	}
+
+	log.Printf("Stored invoice for order %s", orderID)
+	return nil
+}
+
+// RetryPendingInvoices fetches every pending invoice whose retry time has passed
+func (is *InvoiceService) RetryPendingInvoices() {
+	orders, err := is.app.FindRecordsByFilter(
+		constants.CollectionOrders,
+		"invoice_status = {:status} && invoice_next_attempt <= {:now}",
+		"invoice_next_attempt",
+		50,
+		0,
+		dbx.Params{"status": InvoiceStatusPending, "now": types.NowDateTime()},
+	)
+	if err != nil {
+		log.Printf("Error finding pending invoices: %v", err)
+		return
+	}
+
+	for _, order := range orders {
+		if err := is.FetchInvoice(order.Id); err != nil {
+			log.Printf("Warning: invoice for order %s is not available yet: %v", order.Id, err)
+		}
+	}
+}
+
+// scheduleRetry records a failed attempt and computes the next attempt with exponential backoff
+func (is *InvoiceService) scheduleRetry(order *core.Record, cause error) error {
+	attempts := order.GetInt("invoice_attempts") + 1
+	order.Set("invoice_attempts", attempts)
+	order.Set("invoice_error", cause.Error())
+
+	if attempts >= maxInvoiceAttempts {
+		order.Set("invoice_status", InvoiceStatusFailed)
+		order.Set("invoice_next_attempt", nil)
+	} else {
+		backoff := time.Duration(1<<attempts) * time.Minute
+		if backoff > maxInvoiceBackoff {
+			backoff = maxInvoiceBackoff
+		}
+		order.Set("invoice_status", InvoiceStatusPending)
+		order.Set("invoice_next_attempt", time.Now().Add(backoff))
+	}
+
+	if err := is.app.Save(order); err != nil {
+		return fmt.Errorf("failed to schedule invoice retry for order %s: %w", order.Id, err)
+	}
+
+	return cause
+}
+
+// downloadInvoice downloads the invoice PDF from the URL returned by Polar
+func downloadInvoice(url, name string) (*filesystem.File, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), invoiceDownloadTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download invoice: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to download invoice: unexpected status %d", res.StatusCode)
+	}
+
+	var buf bytes.Buffer
+	if _, err := io.Copy(&buf, res.Body); err != nil {
+		return nil, fmt.Errorf("failed to read invoice: %w", err)
+	}
+
+	return filesystem.NewFileFromBytes(buf.Bytes(), name)
+}
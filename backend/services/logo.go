@@ -0,0 +1,176 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"io"
+	"log"
+	"pocketvue/config"
+	"pocketvue/constants"
+
+	"github.com/disintegration/imaging"
+	"github.com/gabriel-vasile/mimetype"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/filesystem"
+
+	// Registers the WebP decoder with the image package
+	_ "golang.org/x/image/webp"
+)
+
+// Logo error codes returned as validation errors of the logo field
+const (
+	LogoErrorType       = "validation_logo_type"
+	LogoErrorInvalid    = "validation_logo_invalid"
+	LogoErrorDimensions = "validation_logo_dimensions"
+)
+
+const (
+	// logoSize is the largest width or height a raster logo is stored with
+	// Smaller versions, including the favicon, are served as thumbs of the logo field
+	logoSize = 512
+
+	// maxLogoDimension bounds the width and height of uploaded raster logos on every plan
+	// so decoding an upload never needs more than 64 MB
+	maxLogoDimension = 4096
+
+	// maxLogoBytes is the largest logo upload that is read, the logo field rejects bigger files
+	maxLogoBytes = 2000000
+
+	// logoJPEGQuality is the quality JPEG logos are encoded with
+	logoJPEGQuality = 90
+)
+
+// LogoError describes why an uploaded logo cannot be used
+type LogoError struct {
+	Code    string
+	Message string
+}
+
+func (e *LogoError) Error() string {
+	return e.Message
+}
+
+// LogoService checks and normalizes workspace logos before they are stored
+// Raster logos are decoded and encoded again, which drops EXIF and other metadata,
+// and SVG logos are stripped of scripts, event handlers and external references
+type LogoService struct {
+	app core.App
+}
+
+// NewLogoService creates a new logo service instance
+func NewLogoService(app core.App) *LogoService {
+	return &LogoService{
+		app: app,
+	}
+}
+
+// Process returns the normalized version of a logo uploaded to a workspace
+// The file type is detected from the content, the file name and its extension are ignored
+func (ls *LogoService) Process(workspace *core.Record, file *filesystem.File) (*filesystem.File, error) {
+	content, err := readLogo(file)
+	if err != nil {
+		return nil, err
+	}
+
+	mime := mimetype.Detect(content)
+	switch {
+	case mime.Is("image/svg+xml"):
+		sanitized, err := SanitizeSVG(content)
+		if err != nil {
+			return nil, &LogoError{Code: LogoErrorInvalid, Message: "The SVG logo could not be read."}
+		}
+		return filesystem.NewFileFromBytes(sanitized, "logo.svg")
+
+	case mime.Is("image/png"), mime.Is("image/jpeg"), mime.Is("image/webp"):
+		return ls.processRaster(workspace, content, mime.Is("image/jpeg"))
+
+	default:
+		return nil, &LogoError{Code: LogoErrorType, Message: "The logo must be a PNG, JPEG, WebP or SVG image."}
+	}
+}
+
+// MaxDimension returns the largest width or height of raster logos the plan of a workspace accepts
+// Workspaces without a subscription use the FreeLogoMaxDimension setting, plans without a limit maxLogoDimension
+func (ls *LogoService) MaxDimension(workspace *core.Record) int {
+	limit := config.Current().FreeLogoMaxDimension
+
+	if subscriptionID := workspace.GetString("subscription_id"); subscriptionID != "" {
+		limit = maxLogoDimension
+		if product, err := ls.findProduct(subscriptionID); err != nil {
+			log.Printf("Warning: failed to find the plan of workspace %s, allowing logos up to %d pixels: %v", workspace.Id, limit, err)
+		} else if productLimit := product.GetInt("logo_max_dimension"); productLimit > 0 {
+			limit = productLimit
+		}
+	}
+
+	if limit <= 0 || limit > maxLogoDimension {
+		return maxLogoDimension
+	}
+	return limit
+}
+
+// processRaster checks the dimensions of a raster logo and encodes it again, fitted into logoSize
+// JPEG logos stay JPEG, PNG and WebP logos are stored as PNG to keep their transparency
+func (ls *LogoService) processRaster(workspace *core.Record, content []byte, isJPEG bool) (*filesystem.File, error) {
+	// The header is enough to check the dimensions before the image is decoded
+	imageConfig, _, err := image.DecodeConfig(bytes.NewReader(content))
+	if err != nil {
+		return nil, &LogoError{Code: LogoErrorInvalid, Message: "The logo image could not be read."}
+	}
+
+	limit := ls.MaxDimension(workspace)
+	if imageConfig.Width > limit || imageConfig.Height > limit {
+		return nil, &LogoError{
+			Code:    LogoErrorDimensions,
+			Message: fmt.Sprintf("The logo can be at most %d×%d pixels on your plan, this image is %d×%d.", limit, limit, imageConfig.Width, imageConfig.Height),
+		}
+	}
+
+	img, err := imaging.Decode(bytes.NewReader(content), imaging.AutoOrientation(true))
+	if err != nil {
+		return nil, &LogoError{Code: LogoErrorInvalid, Message: "The logo image could not be read."}
+	}
+
+	var out bytes.Buffer
+	name := "logo.png"
+	if isJPEG {
+		name = "logo.jpg"
+		err = imaging.Encode(&out, imaging.Fit(img, logoSize, logoSize, imaging.Lanczos), imaging.JPEG, imaging.JPEGQuality(logoJPEGQuality))
+	} else {
+		err = imaging.Encode(&out, imaging.Fit(img, logoSize, logoSize, imaging.Lanczos), imaging.PNG)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode logo: %w", err)
+	}
+
+	return filesystem.NewFileFromBytes(out.Bytes(), name)
+}
+
+// findProduct returns the product of a subscription
+func (ls *LogoService) findProduct(subscriptionID string) (*core.Record, error) {
+	subscription, err := ls.app.FindRecordById(constants.CollectionSubscriptions, subscriptionID)
+	if err != nil {
+		return nil, err
+	}
+	return ls.app.FindRecordById(constants.CollectionPolarProducts, subscription.GetString("product_id"))
+}
+
+// readLogo reads the content of an uploaded logo
+func readLogo(file *filesystem.File) ([]byte, error) {
+	reader, err := file.Reader.Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open logo: %w", err)
+	}
+	defer reader.Close()
+
+	content, err := io.ReadAll(io.LimitReader(reader, maxLogoBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read logo: %w", err)
+	}
+	if len(content) > maxLogoBytes {
+		return nil, &LogoError{Code: LogoErrorInvalid, Message: "The logo must be smaller than 2 MB."}
+	}
+
+	return content, nil
+}
@@ -0,0 +1,166 @@
+package services
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+var (
+	markdownBlankLines = regexp.MustCompile(`\n{3,}`)
+	markdownSpaces     = regexp.MustCompile(`[ \t\r\n]+`)
+)
+
+// HTMLToMarkdown converts the HTML of the note editor to Markdown
+// Paragraphs, headings, emphasis, links, images, lists, quotes and code are converted,
+// other elements are reduced to their text
+func HTMLToMarkdown(input string) string {
+	nodes, err := html.ParseFragment(strings.NewReader(input), &html.Node{
+		Type:     html.ElementNode,
+		Data:     "body",
+		DataAtom: atom.Body,
+	})
+	if err != nil {
+		return input
+	}
+
+	var b strings.Builder
+	for _, node := range nodes {
+		b.WriteString(markdownNode(node, 0))
+	}
+
+	return strings.TrimSpace(markdownBlankLines.ReplaceAllString(b.String(), "\n\n")) + "\n"
+}
+
+// markdownNode renders a node and its children, depth is the nesting level of lists
+func markdownNode(n *html.Node, depth int) string {
+	switch n.Type {
+	case html.TextNode:
+		return markdownSpaces.ReplaceAllString(n.Data, " ")
+	case html.ElementNode:
+	default:
+		return markdownChildren(n, depth)
+	}
+
+	switch n.DataAtom {
+	case atom.P, atom.Div:
+		return "\n\n" + strings.TrimSpace(markdownChildren(n, depth)) + "\n\n"
+	case atom.Br:
+		return "  \n"
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+		level, _ := strconv.Atoi(n.Data[1:])
+		return "\n\n" + strings.Repeat("#", level) + " " + strings.TrimSpace(markdownChildren(n, depth)) + "\n\n"
+	case atom.Strong, atom.B:
+		return markdownWrap(markdownChildren(n, depth), "**")
+	case atom.Em, atom.I:
+		return markdownWrap(markdownChildren(n, depth), "_")
+	case atom.S, atom.Del, atom.Strike:
+		return markdownWrap(markdownChildren(n, depth), "~~")
+	case atom.Code:
+		return markdownWrap(markdownText(n), "`")
+	case atom.Pre:
+		return "\n\n```\n" + strings.TrimRight(markdownText(n), "\n") + "\n```\n\n"
+	case atom.A:
+		text := strings.TrimSpace(markdownChildren(n, depth))
+		href := markdownAttr(n, "href")
+		if href == "" {
+			return text
+		}
+		return "[" + text + "](" + href + ")"
+	case atom.Img:
+		return "![" + markdownAttr(n, "alt") + "](" + markdownAttr(n, "src") + ")"
+	case atom.Hr:
+		return "\n\n---\n\n"
+	case atom.Blockquote:
+		quote := markdownBlankLines.ReplaceAllString(markdownChildren(n, depth), "\n\n")
+		lines := strings.Split(strings.TrimSpace(quote), "\n")
+		for i, line := range lines {
+			lines[i] = strings.TrimRight("> "+line, " ")
+		}
+		return "\n\n" + strings.Join(lines, "\n") + "\n\n"
+	case atom.Ul, atom.Ol:
+		return markdownList(n, depth)
+	case atom.Script, atom.Style:
+		return ""
+	}
+
+	return markdownChildren(n, depth)
+}
+
+// markdownList renders the items of an ordered or unordered list
+func markdownList(n *html.Node, depth int) string {
+	var b strings.Builder
+	if depth == 0 {
+		b.WriteString("\n\n")
+	} else {
+		b.WriteString("\n")
+	}
+
+	indent := strings.Repeat("  ", depth)
+	number := 1
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		if child.DataAtom != atom.Li {
+			continue
+		}
+
+		marker := "- "
+		if n.DataAtom == atom.Ol {
+			marker = strconv.Itoa(number) + ". "
+			number++
+		}
+
+		item := strings.TrimSpace(markdownBlankLines.ReplaceAllString(markdownChildren(child, depth+1), "\n"))
+		item = strings.ReplaceAll(item, "\n\n", "\n")
+		b.WriteString(indent + marker + item + "\n")
+	}
+
+	if depth == 0 {
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// markdownChildren renders the children of a node
+func markdownChildren(n *html.Node, depth int) string {
+	var b strings.Builder
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		b.WriteString(markdownNode(child, depth))
+	}
+	return b.String()
+}
+
+// markdownText returns the raw text of a node, keeping whitespace as in code blocks
+func markdownText(n *html.Node) string {
+	if n.Type == html.TextNode {
+		return n.Data
+	}
+	var b strings.Builder
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		b.WriteString(markdownText(child))
+	}
+	return b.String()
+}
+
+// markdownWrap surrounds text with a marker, keeping surrounding spaces outside so the marker stays valid
+func markdownWrap(text, marker string) string {
+	trimmed := strings.TrimSpace(text)
+	if trimmed == "" {
+		return text
+	}
+	leading := text[:len(text)-len(strings.TrimLeft(text, " "))]
+	trailing := text[len(strings.TrimRight(text, " ")):]
+	return leading + marker + trimmed + marker + trailing
+}
+
+// markdownAttr returns the value of an attribute of a node
+func markdownAttr(n *html.Node, key string) string {
+	for _, attr := range n.Attr {
+		if attr.Key == key {
+			return attr.Val
+		}
+	}
+	return ""
+}
@@ -0,0 +1,305 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"pocketvue/constants"
+	"pocketvue/types"
+	"sort"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// Metrics aggregation intervals
+const (
+	MetricsIntervalDay   = "day"
+	MetricsIntervalWeek  = "week"
+	MetricsIntervalMonth = "month"
+)
+
+// maxMetricsPeriods limits the number of periods a single metrics request can produce
+const maxMetricsPeriods = 400
+
+// MetricsService computes billing metrics from the locally stored Polar data
+type MetricsService struct {
+	app core.App
+}
+
+// NewMetricsService creates a new metrics service instance
+func NewMetricsService(app core.App) *MetricsService {
+	return &MetricsService{
+		app: app,
+	}
+}
+
+// metricsSubscription is the subset of a subscription record used for metrics
+type metricsSubscription struct {
+	productID     string
+	status        string
+	currency      string
+	monthlyAmount float64
+	startedAt     time.Time
+	endedAt       time.Time
+	trialStart    time.Time
+	trialEnd      time.Time
+	pastDueAt     time.Time
+}
+
+// activeAt reports whether the subscription was paying at the given time
+func (s metricsSubscription) activeAt(t time.Time) bool {
+	if s.status == "incomplete" || s.status == "incomplete_expired" {
+		return false
+	}
+	if s.startedAt.IsZero() || s.startedAt.After(t) {
+		return false
+	}
+	if !s.endedAt.IsZero() && !s.endedAt.After(t) {
+		return false
+	}
+	// Trialing subscriptions do not contribute to revenue yet
+	if !s.trialEnd.IsZero() && s.trialEnd.After(t) {
+		return false
+	}
+	return true
+}
+
+// converted reports whether a trial turned into a paying subscription
+func (s metricsSubscription) converted() bool {
+	if s.status == "incomplete_expired" {
+		return false
+	}
+	return s.endedAt.IsZero() || s.endedAt.After(s.trialEnd)
+}
+
+// ComputeBillingMetrics computes billing metrics for the [from, to) range split by interval
+func (ms *MetricsService) ComputeBillingMetrics(from, to time.Time, interval string) (*types.BillingMetrics, error) {
+	periods, err := buildMetricsPeriods(from, to, interval)
+	if err != nil {
+		return nil, err
+	}
+
+	subscriptions, productNames, err := ms.loadSubscriptions()
+	if err != nil {
+		return nil, err
+	}
+
+	orders, err := ms.app.FindAllRecords(constants.CollectionOrders)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch orders: %w", err)
+	}
+
+	now := time.Now().UTC()
+	metrics := &types.BillingMetrics{
+		From:            from,
+		To:              to,
+		Interval:        interval,
+		MRR:             map[string]int{},
+		ActiveByProduct: []types.ProductSubscriptionCount{},
+		Revenue:         map[string]int{},
+		Periods:         periods,
+	}
+
+	// Current snapshot
+	activeByProduct := map[string]int{}
+	mrr := map[string]float64{}
+	for _, sub := range subscriptions {
+		if sub.status != "active" && sub.status != "past_due" {
+			continue
+		}
+		metrics.ActiveSubscriptions++
+		activeByProduct[sub.productID]++
+		mrr[sub.currency] += sub.monthlyAmount
+	}
+	metrics.MRR = roundAmounts(mrr)
+	for productID, count := range activeByProduct {
+		metrics.ActiveByProduct = append(metrics.ActiveByProduct, types.ProductSubscriptionCount{
+			ProductID: productID,
+			Name:      productNames[productID],
+			Count:     count,
+		})
+	}
+	sort.Slice(metrics.ActiveByProduct, func(i, j int) bool {
+		return metrics.ActiveByProduct[i].Count > metrics.ActiveByProduct[j].Count
+	})
+
+	// Per period metrics
+	for i := range metrics.Periods {
+		period := &metrics.Periods[i]
+		periodMRR := map[string]float64{}
+
+		for _, sub := range subscriptions {
+			if sub.activeAt(period.End.Add(-time.Nanosecond)) {
+				period.ActiveSubscriptions++
+				periodMRR[sub.currency] += sub.monthlyAmount
+			}
+			if inRange(sub.startedAt, period.Start, period.End) {
+				period.NewSubscriptions++
+			}
+			if inRange(sub.endedAt, period.Start, period.End) {
+				period.ChurnedSubscriptions++
+			}
+			if inRange(sub.trialStart, period.Start, period.End) {
+				period.TrialsStarted++
+			}
+			if inRange(sub.pastDueAt, period.Start, period.End) {
+				period.FailedPayments++
+			}
+			if inRange(sub.trialEnd, period.Start, period.End) && !sub.trialEnd.After(now) {
+				metrics.TrialsEnded++
+				if sub.converted() {
+					period.TrialsConverted++
+				}
+			}
+		}
+		period.MRR = roundAmounts(periodMRR)
+
+		for _, order := range orders {
+			paidAt := order.GetDateTime("paid_at").Time()
+			if order.GetString("status") == "refunded" || !inRange(paidAt, period.Start, period.End) {
+				continue
+			}
+			period.Revenue[order.GetString("currency")] += order.GetInt("total_amount")
+		}
+
+		metrics.NewSubscriptions += period.NewSubscriptions
+		metrics.ChurnedSubscriptions += period.ChurnedSubscriptions
+		metrics.TrialsStarted += period.TrialsStarted
+		metrics.TrialsConverted += period.TrialsConverted
+		metrics.FailedPayments += period.FailedPayments
+		for currency, amount := range period.Revenue {
+			metrics.Revenue[currency] += amount
+		}
+	}
+
+	if metrics.TrialsEnded > 0 {
+		metrics.TrialConversionRate = float64(metrics.TrialsConverted) / float64(metrics.TrialsEnded)
+	}
+
+	return metrics, nil
+}
+
+// loadSubscriptions loads all subscriptions and normalizes their amount to a monthly value
+// The recurring interval of the product is used when the subscription does not carry one
+func (ms *MetricsService) loadSubscriptions() ([]metricsSubscription, map[string]string, error) {
+	products, err := ms.app.FindAllRecords(constants.CollectionPolarProducts)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to fetch products: %w", err)
+	}
+
+	productNames := make(map[string]string, len(products))
+	productsByID := make(map[string]*core.Record, len(products))
+	for _, product := range products {
+		productNames[product.Id] = product.GetString("name")
+		productsByID[product.Id] = product
+	}
+
+	records, err := ms.app.FindAllRecords(constants.CollectionSubscriptions)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to fetch subscriptions: %w", err)
+	}
+
+	subscriptions := make([]metricsSubscription, 0, len(records))
+	for _, record := range records {
+		interval := record.GetString("recurring_interval")
+		intervalCount := record.GetInt("recurring_interval_count")
+		if product, ok := productsByID[record.GetString("product_id")]; ok {
+			if interval == "" {
+				interval = product.GetString("recurring_interval")
+			}
+			if intervalCount == 0 {
+				intervalCount = product.GetInt("recurring_interval_count")
+			}
+		}
+
+		subscriptions = append(subscriptions, metricsSubscription{
+			productID:     record.GetString("product_id"),
+			status:        record.GetString("status"),
+			currency:      record.GetString("currency"),
+			monthlyAmount: MonthlyAmount(record.GetInt("amount"), interval, intervalCount),
+			startedAt:     record.GetDateTime("started_at").Time(),
+			endedAt:       record.GetDateTime("ended_at").Time(),
+			trialStart:    record.GetDateTime("trial_start").Time(),
+			trialEnd:      record.GetDateTime("trial_end").Time(),
+			pastDueAt:     record.GetDateTime("past_due_at").Time(),
+		})
+	}
+
+	return subscriptions, productNames, nil
+}
+
+// MonthlyAmount normalizes a recurring amount billed every intervalCount intervals to a monthly amount
+func MonthlyAmount(amount int, interval string, intervalCount int) float64 {
+	if intervalCount < 1 {
+		intervalCount = 1
+	}
+
+	var perMonth float64
+	switch interval {
+	case "day":
+		perMonth = 365.25 / 12
+	case "week":
+		perMonth = 365.25 / 7 / 12
+	case "month":
+		perMonth = 1
+	case "year":
+		perMonth = 1.0 / 12
+	default:
+		// One-time products do not contribute to recurring revenue
+		return 0
+	}
+
+	return float64(amount) * perMonth / float64(intervalCount)
+}
+
+// buildMetricsPeriods splits the [from, to) range into periods of the given interval
+func buildMetricsPeriods(from, to time.Time, interval string) ([]types.BillingMetricsPeriod, error) {
+	if !from.Before(to) {
+		return nil, fmt.Errorf("from must be before to")
+	}
+
+	var next func(time.Time) time.Time
+	switch interval {
+	case MetricsIntervalDay:
+		next = func(t time.Time) time.Time { return t.AddDate(0, 0, 1) }
+	case MetricsIntervalWeek:
+		next = func(t time.Time) time.Time { return t.AddDate(0, 0, 7) }
+	case MetricsIntervalMonth:
+		next = func(t time.Time) time.Time { return t.AddDate(0, 1, 0) }
+	default:
+		return nil, fmt.Errorf("unsupported interval %q", interval)
+	}
+
+	var periods []types.BillingMetricsPeriod
+	for start := from; start.Before(to); start = next(start) {
+		if len(periods) >= maxMetricsPeriods {
+			return nil, fmt.Errorf("date range is too large for the %s interval", interval)
+		}
+		end := next(start)
+		if end.After(to) {
+			end = to
+		}
+		periods = append(periods, types.BillingMetricsPeriod{
+			Start:   start,
+			End:     end,
+			MRR:     map[string]int{},
+			Revenue: map[string]int{},
+		})
+	}
+
+	return periods, nil
+}
+
+// inRange reports whether t is set and falls within [start, end)
+func inRange(t, start, end time.Time) bool {
+	return !t.IsZero() && !t.Before(start) && t.Before(end)
+}
+
+// roundAmounts rounds fractional monthly amounts to the smallest currency unit
+func roundAmounts(amounts map[string]float64) map[string]int {
+	rounded := make(map[string]int, len(amounts))
+	for currency, amount := range amounts {
+		rounded[currency] = int(math.Round(amount))
+	}
+	return rounded
+}
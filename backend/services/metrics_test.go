@@ -0,0 +1,83 @@
+package services
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestMonthlyAmount(t *testing.T) {
+	tests := []struct {
+		name          string
+		amount        int
+		interval      string
+		intervalCount int
+		want          float64
+	}{
+		{name: "month", amount: 1000, interval: "month", intervalCount: 1, want: 1000},
+		{name: "every three months", amount: 3000, interval: "month", intervalCount: 3, want: 1000},
+		{name: "year", amount: 12000, interval: "year", intervalCount: 1, want: 1000},
+		{name: "every two years", amount: 24000, interval: "year", intervalCount: 2, want: 1000},
+		{name: "week", amount: 700, interval: "week", intervalCount: 1, want: 700 * 365.25 / 7 / 12},
+		{name: "every two weeks", amount: 1400, interval: "week", intervalCount: 2, want: 700 * 365.25 / 7 / 12},
+		{name: "day", amount: 100, interval: "day", intervalCount: 1, want: 100 * 365.25 / 12},
+		{name: "missing interval count", amount: 1000, interval: "month", intervalCount: 0, want: 1000},
+		{name: "negative interval count", amount: 1000, interval: "month", intervalCount: -2, want: 1000},
+		{name: "one-time", amount: 5000, interval: "", intervalCount: 1, want: 0},
+		{name: "unknown interval", amount: 5000, interval: "quarter", intervalCount: 1, want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := MonthlyAmount(tt.amount, tt.interval, tt.intervalCount)
+			if math.Abs(got-tt.want) > 1e-9 {
+				t.Fatalf("MonthlyAmount(%d, %q, %d) = %v, want %v", tt.amount, tt.interval, tt.intervalCount, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildMetricsPeriods(t *testing.T) {
+	day := func(month time.Month, d int) time.Time {
+		return time.Date(2025, month, d, 0, 0, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name     string
+		from     time.Time
+		to       time.Time
+		interval string
+		want     int
+		lastEnd  time.Time
+		wantErr  bool
+	}{
+		{name: "days", from: day(1, 1), to: day(1, 8), interval: MetricsIntervalDay, want: 7, lastEnd: day(1, 8)},
+		{name: "weeks with a partial last week", from: day(1, 1), to: day(1, 18), interval: MetricsIntervalWeek, want: 3, lastEnd: day(1, 18)},
+		{name: "months", from: day(1, 1), to: day(12, 31), interval: MetricsIntervalMonth, want: 12, lastEnd: day(12, 31)},
+		{name: "empty range", from: day(2, 1), to: day(2, 1), interval: MetricsIntervalMonth, wantErr: true},
+		{name: "reversed range", from: day(3, 1), to: day(2, 1), interval: MetricsIntervalMonth, wantErr: true},
+		{name: "unknown interval", from: day(1, 1), to: day(2, 1), interval: "year", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			periods, err := buildMetricsPeriods(tt.from, tt.to, tt.interval)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %d periods", len(periods))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(periods) != tt.want {
+				t.Fatalf("got %d periods, want %d", len(periods), tt.want)
+			}
+			if !periods[0].Start.Equal(tt.from) || !periods[len(periods)-1].End.Equal(tt.lastEnd) {
+				t.Fatalf("periods span %s to %s, want %s to %s",
+					periods[0].Start, periods[len(periods)-1].End, tt.from, tt.lastEnd)
+			}
+		})
+	}
+}
@@ -1,31 +1,60 @@
 package services
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"log"
+	"net/http"
 	"pocketvue/config"
+	"sync"
+	"time"
 
 	"github.com/pocketbase/pocketbase"
+	"github.com/pocketbase/pocketbase/core"
 	polargo "github.com/polarsource/polar-go"
+	"github.com/polarsource/polar-go/models/apierrors"
 	"github.com/polarsource/polar-go/models/components"
 	"github.com/polarsource/polar-go/models/operations"
 )
 
 // PolarService handles Polar.sh payment gateway operations
-type PolarService struct {
-	client *polargo.Polar
-}
+type PolarService struct{}
 
 // NewPolarService creates a new Polar service instance
 func NewPolarService() *PolarService {
-	client := polargo.New(
-		polargo.WithServer(config.GetPolarServer()),
-		polargo.WithSecurity(config.PolarAccessToken),
-	)
+	return &PolarService{}
+}
+
+var (
+	clientMu     sync.Mutex
+	sharedClient *polargo.Polar
+	clientServer string
+	clientToken  string
+)
+
+// client returns the Polar SDK client for the current configuration
+// The client is rebuilt when the access token or environment changes, so settings edited
+// at runtime apply to long-lived services without a restart
+func (ps *PolarService) client() *polargo.Polar {
+	clientMu.Lock()
+	defer clientMu.Unlock()
 
-	return &PolarService{
-		client: client,
+	cfg := config.Current()
+	server := cfg.PolarServer()
+	if sharedClient == nil || clientServer != server || clientToken != cfg.PolarAccessToken {
+		sharedClient = polargo.New(
+			polargo.WithServer(server),
+			polargo.WithSecurity(cfg.PolarAccessToken),
+		)
+		clientServer = server
+		clientToken = cfg.PolarAccessToken
 	}
+
+	return sharedClient
 }
 
 // CreateCustomerAsync creates a Polar customer asynchronously for a new user
@@ -35,106 +64,313 @@ func (ps *PolarService) CreateCustomerAsync(app *pocketbase.PocketBase, userID,
 
 // createCustomer handles the actual Polar customer creation (private method)
 func (ps *PolarService) createCustomer(app *pocketbase.PocketBase, userID, userEmail, userName string) {
-	ctx := context.Background()
-
 	if userEmail == "" {
 		log.Printf("Warning: User %s has no email, skipping Polar customer creation", userID)
 		return
 	}
 
-	// Create customer in Polar
-	// Note: When using organization token, organization_id should not be set
-	customerReq := components.CustomerCreate{
-		ExternalID: polargo.Pointer(userID),
-		Email:      userEmail,
-		Name:       polargo.Pointer(userName),
+	userRecord, err := app.FindRecordById("users", userID)
+	if err != nil {
+		log.Printf("Error finding user %s to create Polar customer: %v", userID, err)
+		return
 	}
 
-	res, err := ps.client.Customers.Create(ctx, customerReq)
-	if err != nil {
+	if _, err := ps.EnsureCustomer(context.Background(), app, userRecord); err != nil {
 		log.Printf("Error creating Polar customer for user %s: %v", userID, err)
-		return
 	}
+}
 
-	if res.Customer != nil {
-		log.Printf("Successfully created Polar customer %s for user %s", res.Customer.ID, userID)
+// EnsureCustomer returns the Polar customer ID of a user, provisioning the customer if needed
+// The customer is looked up by external ID before creating one, so users whose async creation
+// failed or ran before Polar was configured are recovered
+func (ps *PolarService) EnsureCustomer(ctx context.Context, app core.App, user *core.Record) (string, error) {
+	if customerID := user.GetString("polar_customer_id"); customerID != "" {
+		return customerID, nil
+	}
 
-		// Update user record with Polar customer information
-		userRecord, err := app.FindRecordById("users", userID)
-		if err != nil {
-			log.Printf("Error finding user %s to update Polar info: %v", userID, err)
-			return
+	customer, err := ps.findCustomerByExternalID(ctx, user.Id)
+	if err != nil {
+		return "", err
+	}
+
+	if customer == nil {
+		userEmail := user.GetString("email")
+		if userEmail == "" {
+			return "", fmt.Errorf("user %s has no email", user.Id)
 		}
 
-		userRecord.Set("polar_customer_id", res.Customer.ID)
-		userRecord.Set("polar_customer_created", res.Customer.CreatedAt)
+		// Create customer in Polar
+		// Note: When using organization token, organization_id should not be set
+		customerReq := components.CustomerCreate{
+			ExternalID: polargo.Pointer(user.Id),
+			Email:      userEmail,
+			Name:       polargo.Pointer(user.GetString("name")),
+		}
 
-		// Save the updated user record
-		err = app.Save(userRecord)
+		// Creating a customer is not idempotent, so it is never retried
+		var res *operations.CustomersCreateResponse
+		err := callPolar(ctx, false, func(ctx context.Context) error {
+			var err error
+			res, err = ps.client().Customers.Create(ctx, customerReq)
+			return err
+		})
 		if err != nil {
-			log.Printf("Error updating user %s with Polar customer info: %v", userID, err)
+			if errors.Is(err, ErrPolarUnavailable) || ctx.Err() != nil {
+				return "", fmt.Errorf("failed to create Polar customer: %w", err)
+			}
+			// The customer may have been created concurrently by another request
+			existing, findErr := ps.findCustomerByExternalID(ctx, user.Id)
+			if findErr != nil || existing == nil {
+				return "", fmt.Errorf("failed to create Polar customer: %w", err)
+			}
+			customer = existing
+		} else if res.Customer == nil {
+			return "", errors.New("customer response is empty")
 		} else {
-			log.Printf("Updated user %s with Polar customer ID: %s", userID, res.Customer.ID)
+			customer = res.Customer
+			log.Printf("Successfully created Polar customer %s for user %s", customer.ID, user.Id)
+		}
+	}
+
+	// Update user record with Polar customer information
+	user.Set("polar_customer_id", customer.ID)
+	user.Set("polar_customer_created", customer.CreatedAt)
+	if err := app.Save(user); err != nil {
+		return "", fmt.Errorf("failed to update user %s with Polar customer info: %w", user.Id, err)
+	}
+
+	log.Printf("Updated user %s with Polar customer ID: %s", user.Id, customer.ID)
+	return customer.ID, nil
+}
+
+// findCustomerByExternalID looks up a Polar customer by external ID
+// Returns nil without an error if no customer exists
+func (ps *PolarService) findCustomerByExternalID(ctx context.Context, externalID string) (*components.Customer, error) {
+	var res *operations.CustomersGetExternalResponse
+	err := callPolar(ctx, true, func(ctx context.Context) error {
+		var err error
+		res, err = ps.client().Customers.GetExternal(ctx, externalID)
+		return err
+	})
+	if err != nil {
+		var notFound *apierrors.ResourceNotFound
+		if errors.As(err, &notFound) {
+			return nil, nil
 		}
+		return nil, fmt.Errorf("failed to look up Polar customer %s: %w", externalID, err)
 	}
+	return res.Customer, nil
 }
 
-// CreateCheckoutSession creates a Polar checkout session and returns the checkout URL
-func (ps *PolarService) CreateCheckoutSession(productIDs []string, successURL, returnURL, userID, userEmail, userName string) (string, error) {
+// UpdateCustomerProfile updates the email and name of the Polar customer linked to a user
+// Returns nil if the user has no Polar customer
+func (ps *PolarService) UpdateCustomerProfile(externalID, email, name string) error {
 	ctx := context.Background()
 
+	update := components.CustomerUpdateExternalID{
+		Email: polargo.Pointer(email),
+		Name:  polargo.Pointer(name),
+	}
+
+	err := callPolar(ctx, true, func(ctx context.Context) error {
+		_, err := ps.client().Customers.UpdateExternal(ctx, externalID, update)
+		return err
+	})
+	if err != nil {
+		var notFound *apierrors.ResourceNotFound
+		if errors.As(err, &notFound) {
+			log.Printf("No Polar customer for user %s, skipping profile update", externalID)
+			return nil
+		}
+		return fmt.Errorf("failed to update Polar customer %s: %w", externalID, err)
+	}
+
+	log.Printf("Updated Polar customer profile for user %s", externalID)
+	return nil
+}
+
+// RevokeCustomerSubscriptions immediately revokes every active subscription of the customer linked to a user
+// Returns the number of revoked subscriptions
+func (ps *PolarService) RevokeCustomerSubscriptions(externalID string) (int, error) {
+	ctx := context.Background()
+
+	var res *operations.SubscriptionsListResponse
+	err := callPolar(ctx, true, func(ctx context.Context) error {
+		var err error
+		res, err = ps.client().Subscriptions.List(ctx, operations.SubscriptionsListRequest{
+			ExternalCustomerID: polargo.Pointer(operations.CreateExternalCustomerIDFilterStr(externalID)),
+			Active:             polargo.Pointer(true),
+			Limit:              polargo.Pointer(int64(100)),
+		})
+		return err
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to list subscriptions of user %s: %w", externalID, err)
+	}
+
+	revoked := 0
+	if res.ListResourceSubscription == nil {
+		return revoked, nil
+	}
+
+	for _, subscription := range res.ListResourceSubscription.Items {
+		err := callPolar(ctx, true, func(ctx context.Context) error {
+			_, err := ps.client().Subscriptions.Revoke(ctx, subscription.ID)
+			return err
+		})
+		if err != nil {
+			var alreadyCanceled *apierrors.AlreadyCanceledSubscription
+			if errors.As(err, &alreadyCanceled) {
+				continue
+			}
+			return revoked, fmt.Errorf("failed to revoke subscription %s: %w", subscription.ID, err)
+		}
+		revoked++
+		log.Printf("Revoked subscription %s of user %s", subscription.ID, externalID)
+	}
+
+	return revoked, nil
+}
+
+// DeleteCustomer deletes the Polar customer linked to a user
+// Returns nil if the customer does not exist
+func (ps *PolarService) DeleteCustomer(externalID string) error {
+	ctx := context.Background()
+
+	err := callPolar(ctx, true, func(ctx context.Context) error {
+		_, err := ps.client().Customers.DeleteExternal(ctx, externalID)
+		return err
+	})
+	if err != nil {
+		var notFound *apierrors.ResourceNotFound
+		if errors.As(err, &notFound) {
+			return nil
+		}
+		return fmt.Errorf("failed to delete Polar customer %s: %w", externalID, err)
+	}
+
+	log.Printf("Deleted Polar customer of user %s", externalID)
+	return nil
+}
+
+// AnonymizeCustomer replaces the personal data of the Polar customer linked to a user
+// The customer is kept so orders and invoices stay available for accounting
+func (ps *PolarService) AnonymizeCustomer(externalID string) error {
+	email := fmt.Sprintf("deleted-%s@anonymized.invalid", externalID)
+	if err := ps.UpdateCustomerProfile(externalID, email, "Deleted user"); err != nil {
+		return err
+	}
+
+	log.Printf("Anonymized Polar customer of user %s", externalID)
+	return nil
+}
+
+// CheckoutParams holds the parameters for creating a Polar checkout session
+type CheckoutParams struct {
+	ProductIDs  []string
+	SuccessURL  string
+	ReturnURL   string
+	WorkspaceID string // Optional: stored in the checkout metadata to link orders and subscriptions to the workspace
+	Seats       int    // Optional: number of seats for seat-based products
+	CustomerID  string // Optional: Polar customer ID, the user ID is sent as external customer ID otherwise
+	UserID      string
+	UserEmail   string
+	UserName    string
+}
+
+// CheckoutSession is the subset of a created Polar checkout that the app keeps track of
+type CheckoutSession struct {
+	ID        string
+	URL       string
+	Status    string
+	ExpiresAt time.Time
+}
+
+// CreateCheckoutSession creates a Polar checkout session and returns its ID and URL
+// The context should be the one of the incoming request so abandoned requests stop waiting on Polar
+func (ps *PolarService) CreateCheckoutSession(ctx context.Context, params CheckoutParams) (*CheckoutSession, error) {
 	// Validate required parameters
-	if len(productIDs) == 0 {
-		return "", &CheckoutError{Message: "at least one product ID is required"}
+	if len(params.ProductIDs) == 0 {
+		return nil, &CheckoutError{Message: "at least one product ID is required"}
 	}
-	if successURL == "" {
-		return "", &CheckoutError{Message: "success_url is required"}
+	if params.SuccessURL == "" {
+		return nil, &CheckoutError{Message: "success_url is required"}
 	}
-	if userEmail == "" {
-		return "", &CheckoutError{Message: "user email is required"}
+	if params.UserEmail == "" {
+		return nil, &CheckoutError{Message: "user email is required"}
+	}
+	if params.Seats < 0 {
+		return nil, &CheckoutError{Message: "seats must be a positive number"}
 	}
 
 	// Build checkout request
 	checkoutReq := components.CheckoutCreate{
-		Products:           productIDs,
-		ExternalCustomerID: polargo.Pointer(userID),
-		CustomerEmail:      polargo.Pointer(userEmail),
-		CustomerName:       polargo.Pointer(userName),
-		SuccessURL:         polargo.Pointer(successURL),
+		Products:      params.ProductIDs,
+		CustomerEmail: polargo.Pointer(params.UserEmail),
+		CustomerName:  polargo.Pointer(params.UserName),
+		SuccessURL:    polargo.Pointer(params.SuccessURL),
+	}
+
+	// Prefer the provisioned customer, fall back to linking it by external ID
+	if params.CustomerID != "" {
+		checkoutReq.CustomerID = polargo.Pointer(params.CustomerID)
+	} else {
+		checkoutReq.ExternalCustomerID = polargo.Pointer(params.UserID)
 	}
 
 	// Add optional return URL if provided
-	if returnURL != "" {
-		checkoutReq.ReturnURL = polargo.Pointer(returnURL)
+	if params.ReturnURL != "" {
+		checkoutReq.ReturnURL = polargo.Pointer(params.ReturnURL)
 	}
 
-	// Create checkout session
-	res, err := ps.client.Checkouts.Create(ctx, checkoutReq)
+	// Add seat quantity for seat-based products
+	if params.Seats > 0 {
+		checkoutReq.Seats = polargo.Pointer(int64(params.Seats))
+	}
+
+	// Polar copies checkout metadata onto the resulting order and subscription
+	if params.WorkspaceID != "" {
+		checkoutReq.Metadata = map[string]components.CheckoutCreateMetadata{
+			"workspace_id": components.CreateCheckoutCreateMetadataStr(params.WorkspaceID),
+		}
+	}
+
+	// Create checkout session, not retried since a retry could create a second checkout
+	var res *operations.CheckoutsCreateResponse
+	err := callPolar(ctx, false, func(ctx context.Context) error {
+		var err error
+		res, err = ps.client().Checkouts.Create(ctx, checkoutReq)
+		return err
+	})
 	if err != nil {
-		log.Printf("Error creating Polar checkout for user %s: %v", userID, err)
-		return "", &CheckoutError{Message: "failed to create checkout session", Err: err}
+		log.Printf("Error creating Polar checkout for user %s: %v", params.UserID, err)
+		return nil, &CheckoutError{Message: "failed to create checkout session", Err: err}
 	}
 
 	if res.Checkout == nil {
-		return "", &CheckoutError{Message: "checkout response is empty"}
+		return nil, &CheckoutError{Message: "checkout response is empty", Err: ErrPolarEmptyResponse}
 	}
 
-	log.Printf("Successfully created checkout session %s for user %s", res.Checkout.ID, userID)
-	return res.Checkout.URL, nil
+	log.Printf("Successfully created checkout session %s for user %s", res.Checkout.ID, params.UserID)
+	return &CheckoutSession{
+		ID:        res.Checkout.ID,
+		URL:       res.Checkout.URL,
+		Status:    string(res.Checkout.Status),
+		ExpiresAt: res.Checkout.ExpiresAt,
+	}, nil
 }
 
 // CreateCustomerSession creates a Polar customer session for accessing the customer portal
-func (ps *PolarService) CreateCustomerSession(userID, returnURL string) (string, error) {
-	ctx := context.Background()
-
+// Use EnsureCustomer to get the customer ID of a user
+func (ps *PolarService) CreateCustomerSession(ctx context.Context, customerID, returnURL string) (string, error) {
 	// Validate required parameters
-	if userID == "" {
-		return "", &CheckoutError{Message: "user ID is required"}
+	if customerID == "" {
+		return "", &CheckoutError{Message: "customer ID is required"}
 	}
 
 	// Create customer session request
-	sessionReq := components.CustomerSessionCustomerExternalIDCreate{
-		ExternalCustomerID: userID,
+	sessionReq := components.CustomerSessionCustomerIDCreate{
+		CustomerID: customerID,
 	}
 
 	// Add optional return URL if provided
@@ -143,23 +379,184 @@ func (ps *PolarService) CreateCustomerSession(userID, returnURL string) (string,
 	}
 
 	// Create customer session using the SDK's wrapper function
-	res, err := ps.client.CustomerSessions.Create(
-		ctx,
-		operations.CreateCustomerSessionsCreateCustomerSessionCreateCustomerSessionCustomerExternalIDCreate(sessionReq),
-	)
+	var res *operations.CustomerSessionsCreateResponse
+	err := callPolar(ctx, false, func(ctx context.Context) error {
+		var err error
+		res, err = ps.client().CustomerSessions.Create(
+			ctx,
+			operations.CreateCustomerSessionsCreateCustomerSessionCreateCustomerSessionCustomerIDCreate(sessionReq),
+		)
+		return err
+	})
 	if err != nil {
-		log.Printf("Error creating customer session for user %s: %v", userID, err)
+		log.Printf("Error creating customer session for customer %s: %v", customerID, err)
 		return "", &CheckoutError{Message: "failed to create customer session", Err: err}
 	}
 
 	if res.CustomerSession == nil {
-		return "", &CheckoutError{Message: "customer session response is empty"}
+		return "", &CheckoutError{Message: "customer session response is empty", Err: ErrPolarEmptyResponse}
 	}
 
-	log.Printf("Successfully created customer session for user %s", userID)
+	log.Printf("Successfully created customer session for customer %s", customerID)
 	return res.CustomerSession.CustomerPortalURL, nil
 }
 
+// UpdateSubscriptionSeats changes the number of seats billed on a seat-based subscription
+// The SDK has no typed request for seat updates yet, so the API is called directly
+func (ps *PolarService) UpdateSubscriptionSeats(subscriptionID string, seats int) error {
+	ctx := context.Background()
+
+	if subscriptionID == "" {
+		return errors.New("subscription ID is required")
+	}
+	if seats < 1 {
+		return errors.New("seats must be at least 1")
+	}
+
+	body, err := json.Marshal(map[string]int{"seats": seats})
+	if err != nil {
+		return err
+	}
+
+	// Setting an absolute seat quantity is idempotent, so the call can be retried
+	cfg := config.Current()
+	url := polargo.ServerList[cfg.PolarServer()] + "/v1/subscriptions/" + subscriptionID
+	err = callPolar(ctx, true, func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPatch, url, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "Bearer "+cfg.PolarAccessToken)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Accept", "application/json")
+
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return err
+		}
+		defer res.Body.Close()
+
+		if res.StatusCode < 200 || res.StatusCode > 299 {
+			resBody, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+			return apierrors.NewAPIError("polar returned an error", res.StatusCode, string(resBody), res)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Error updating seats for subscription %s: %v", subscriptionID, err)
+		return err
+	}
+
+	log.Printf("Updated subscription %s to %d seats", subscriptionID, seats)
+	return nil
+}
+
+// SetSubscriptionCancelAtPeriodEnd schedules a subscription to end with its current period, or undoes that
+// The customer keeps access until the period ends, so the cancellation can be reverted in the meantime
+// It reports false when cancelling a subscription that was already cancelled
+func (ps *PolarService) SetSubscriptionCancelAtPeriodEnd(subscriptionID string, cancel bool) (bool, error) {
+	ctx := context.Background()
+
+	err := callPolar(ctx, true, func(ctx context.Context) error {
+		_, err := ps.client().Subscriptions.Update(ctx, subscriptionID, components.CreateSubscriptionUpdateSubscriptionCancel(
+			components.SubscriptionCancel{CancelAtPeriodEnd: cancel},
+		))
+		return err
+	})
+	if err != nil {
+		var alreadyCanceled *apierrors.AlreadyCanceledSubscription
+		if cancel && errors.As(err, &alreadyCanceled) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to update cancellation of subscription %s: %w", subscriptionID, err)
+	}
+
+	log.Printf("Set cancel_at_period_end=%v on subscription %s", cancel, subscriptionID)
+	return true, nil
+}
+
+// RevokeSubscription ends a subscription immediately
+// Returns nil if the subscription is already canceled or does not exist
+func (ps *PolarService) RevokeSubscription(subscriptionID string) error {
+	ctx := context.Background()
+
+	err := callPolar(ctx, true, func(ctx context.Context) error {
+		_, err := ps.client().Subscriptions.Revoke(ctx, subscriptionID)
+		return err
+	})
+	if err != nil {
+		var alreadyCanceled *apierrors.AlreadyCanceledSubscription
+		var notFound *apierrors.ResourceNotFound
+		if errors.As(err, &alreadyCanceled) || errors.As(err, &notFound) {
+			return nil
+		}
+		return fmt.Errorf("failed to revoke subscription %s: %w", subscriptionID, err)
+	}
+
+	log.Printf("Revoked subscription %s", subscriptionID)
+	return nil
+}
+
+// ErrInvoicePending is returned while Polar is still generating an order invoice
+var ErrInvoicePending = errors.New("invoice is not ready yet")
+
+// GenerateOrderInvoice asks Polar to generate the invoice for a paid order
+// Generation is asynchronous, use GetOrderInvoiceURL to check when it is ready
+func (ps *PolarService) GenerateOrderInvoice(orderID string) error {
+	ctx := context.Background()
+
+	if orderID == "" {
+		return errors.New("order ID is required")
+	}
+
+	err := callPolar(ctx, true, func(ctx context.Context) error {
+		_, err := ps.client().Orders.GenerateInvoice(ctx, orderID)
+		return err
+	})
+	if err != nil {
+		// An invoice that already exists is not an error for our purposes
+		var alreadyExists *apierrors.InvoiceAlreadyExists
+		if errors.As(err, &alreadyExists) {
+			return nil
+		}
+		log.Printf("Error requesting invoice generation for order %s: %v", orderID, err)
+		return err
+	}
+
+	log.Printf("Requested invoice generation for order %s", orderID)
+	return nil
+}
+
+// GetOrderInvoiceURL returns the download URL for an order invoice
+// Returns ErrInvoicePending if the invoice has not been generated yet
+func (ps *PolarService) GetOrderInvoiceURL(orderID string) (string, error) {
+	ctx := context.Background()
+
+	if orderID == "" {
+		return "", errors.New("order ID is required")
+	}
+
+	var res *operations.OrdersInvoiceResponse
+	err := callPolar(ctx, true, func(ctx context.Context) error {
+		var err error
+		res, err = ps.client().Orders.Invoice(ctx, orderID)
+		return err
+	})
+	if err != nil {
+		var notFound *apierrors.ResourceNotFound
+		if errors.As(err, &notFound) {
+			return "", ErrInvoicePending
+		}
+		return "", err
+	}
+
+	if res.OrderInvoice == nil || res.OrderInvoice.URL == "" {
+		return "", ErrInvoicePending
+	}
+
+	return res.OrderInvoice.URL, nil
+}
+
 // CheckoutError represents an error during checkout creation
 type CheckoutError struct {
 	Message string
@@ -172,3 +569,7 @@ func (e *CheckoutError) Error() string {
 	}
 	return e.Message
 }
+
+func (e *CheckoutError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,212 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"net"
+	"net/http"
+	"pocketvue/config"
+	"sync"
+	"time"
+
+	"github.com/polarsource/polar-go/models/apierrors"
+)
+
+// ErrPolarUnavailable is returned when Polar cannot be reached, times out, fails on its side
+// or when the circuit breaker is open
+var ErrPolarUnavailable = errors.New("payment provider unavailable")
+
+// ErrPolarEmptyResponse is returned when Polar answers a successful call without the expected resource
+var ErrPolarEmptyResponse = errors.New("payment provider returned an empty response")
+
+const (
+	// polarRetryBaseDelay is the base delay of the exponential backoff between retries
+	polarRetryBaseDelay = 200 * time.Millisecond
+
+	// polarRetryMaxDelay caps the delay between two retries
+	polarRetryMaxDelay = 2 * time.Second
+)
+
+// polarBreaker is shared by every PolarService so all callers see the same Polar health
+var polarBreaker = &circuitBreaker{}
+
+// circuitBreaker stops calling Polar after repeated failures until a cooldown has passed
+// Once the cooldown is over a single probe call is let through, its outcome closes or reopens the breaker
+type circuitBreaker struct {
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+	probing   bool
+}
+
+// allow reports whether a call may be sent to Polar
+func (cb *circuitBreaker) allow() bool {
+	threshold := config.Current().PolarBreakerThreshold
+	if threshold <= 0 {
+		return true
+	}
+
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if cb.failures < threshold {
+		return true
+	}
+	if time.Now().Before(cb.openUntil) || cb.probing {
+		return false
+	}
+
+	cb.probing = true
+	return true
+}
+
+// success closes the breaker
+func (cb *circuitBreaker) success() {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if threshold := config.Current().PolarBreakerThreshold; threshold > 0 && cb.failures >= threshold {
+		log.Printf("Polar is reachable again, closing circuit breaker")
+	}
+	cb.failures = 0
+	cb.probing = false
+}
+
+// release gives up a probe without judging Polar's health
+func (cb *circuitBreaker) release() {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	cb.probing = false
+}
+
+// failure records a failed call and opens the breaker once the threshold is reached
+func (cb *circuitBreaker) failure() {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	cb.failures++
+	cb.probing = false
+
+	cfg := config.Current()
+	if cfg.PolarBreakerThreshold > 0 && cb.failures >= cfg.PolarBreakerThreshold {
+		cb.openUntil = time.Now().Add(cfg.PolarBreakerCooldown)
+		log.Printf("Warning: %d consecutive Polar failures, failing fast for %s", cb.failures, cfg.PolarBreakerCooldown)
+	}
+}
+
+// callPolar runs a Polar API call with a timeout, the circuit breaker and, for idempotent calls, retries
+// Transient failures are returned wrapped in ErrPolarUnavailable, other errors are returned as is
+func callPolar(ctx context.Context, idempotent bool, call func(ctx context.Context) error) error {
+	attempts := 1
+	if retries := config.Current().PolarMaxRetries; idempotent && retries > 0 {
+		attempts += retries
+	}
+
+	var err error
+	for attempt := 0; attempt < attempts; attempt++ {
+		if attempt > 0 {
+			if waitErr := waitRetry(ctx, attempt); waitErr != nil {
+				return waitErr
+			}
+		}
+
+		if !polarBreaker.allow() {
+			return fmt.Errorf("%w: circuit breaker is open", ErrPolarUnavailable)
+		}
+
+		err = callWithTimeout(ctx, call)
+		if err == nil {
+			polarBreaker.success()
+			return nil
+		}
+
+		// The caller went away, this says nothing about Polar's health
+		if ctx.Err() != nil {
+			polarBreaker.release()
+			return ctx.Err()
+		}
+
+		if !isTransientPolarError(err) {
+			// Polar answered, so it is up even though it rejected the request
+			polarBreaker.success()
+			return err
+		}
+
+		polarBreaker.failure()
+	}
+
+	return fmt.Errorf("%w: %v", ErrPolarUnavailable, err)
+}
+
+// callWithTimeout runs a single call with the configured per-call timeout
+func callWithTimeout(ctx context.Context, call func(ctx context.Context) error) error {
+	timeout := config.Current().PolarTimeout
+	if timeout <= 0 {
+		return call(ctx)
+	}
+
+	callCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	return call(callCtx)
+}
+
+// waitRetry sleeps before a retry using exponential backoff with full jitter
+func waitRetry(ctx context.Context, attempt int) error {
+	backoff := polarRetryBaseDelay << (attempt - 1)
+	if backoff > polarRetryMaxDelay {
+		backoff = polarRetryMaxDelay
+	}
+	delay := time.Duration(rand.Int63n(int64(backoff) + 1))
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// isTransientPolarError reports whether an error means Polar is unreachable or failing on its side
+func isTransientPolarError(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+
+	var apiErr *apierrors.APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode >= 500 || apiErr.StatusCode == http.StatusTooManyRequests
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// IsPolarBadRequest reports whether Polar rejected a request because of invalid input
+func IsPolarBadRequest(err error) bool {
+	var validationErr *apierrors.HTTPValidationError
+	if errors.As(err, &validationErr) {
+		return true
+	}
+
+	var notFound *apierrors.ResourceNotFound
+	if errors.As(err, &notFound) {
+		return true
+	}
+
+	var apiErr *apierrors.APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode == http.StatusBadRequest ||
+			apiErr.StatusCode == http.StatusNotFound ||
+			apiErr.StatusCode == http.StatusUnprocessableEntity
+	}
+
+	return false
+}
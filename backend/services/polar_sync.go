@@ -0,0 +1,286 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"pocketvue/config"
+	"pocketvue/constants"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// Polar sync job actions stored in the polar_sync_jobs.action field
+const (
+	SyncActionUpdateCustomer = "update_customer"
+	SyncActionDeleteCustomer = "delete_customer"
+	SyncActionUpdateSeats    = "update_seats"
+)
+
+// Polar sync job statuses stored in the polar_sync_jobs.status field
+const (
+	SyncStatusPending = "pending"
+	SyncStatusDone    = "done"
+	SyncStatusFailed  = "failed"
+
+	// SyncStatusSuperseded marks jobs that were replaced by a newer job for the same user
+	SyncStatusSuperseded = "superseded"
+)
+
+const (
+	// maxSyncAttempts is the number of attempts before a sync job is marked as failed
+	maxSyncAttempts = 12
+
+	// maxSyncBackoff caps the delay between two attempts of a sync job
+	maxSyncBackoff = 12 * time.Hour
+
+	// syncJobLease is how long a claimed job is hidden from other runs,
+	// a job interrupted by a restart is retried once it passes
+	syncJobLease = 10 * time.Minute
+)
+
+// errSyncJobSuperseded is returned by jobs that no longer need to run
+var errSyncJobSuperseded = errors.New("sync job was superseded")
+
+// PolarSyncService propagates user changes to Polar through a durable job queue
+// Jobs are stored in the polar_sync_jobs collection and retried with backoff until they succeed
+type PolarSyncService struct {
+	app   core.App
+	polar *PolarService
+}
+
+// NewPolarSyncService creates a new Polar sync service instance
+func NewPolarSyncService(app core.App) *PolarSyncService {
+	return &PolarSyncService{
+		app:   app,
+		polar: NewPolarService(),
+	}
+}
+
+// Enqueue stores a sync job and processes it in the background
+// externalID is the ID of the user, or of the workspace for update_seats jobs
+func (ss *PolarSyncService) Enqueue(action, externalID string) error {
+	job, err := ss.Queue(action, externalID)
+	if err != nil {
+		return err
+	}
+
+	go ss.process(job)
+
+	return nil
+}
+
+// Queue stores a sync job without processing it, for saving it in the transaction of the change it syncs
+// Jobs only store the ID, they send the data the user or workspace has when they run.
+// Pending jobs they make obsolete, earlier profile or seat updates of the same ID, are superseded
+func (ss *PolarSyncService) Queue(action, externalID string) (*core.Record, error) {
+	collection, err := ss.app.FindCollectionByNameOrId(constants.CollectionPolarSyncJobs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find polar_sync_jobs collection: %w", err)
+	}
+
+	job := core.NewRecord(collection)
+	job.Set("action", action)
+	job.Set("external_id", externalID)
+	job.Set("status", SyncStatusPending)
+	job.Set("next_attempt", types.NowDateTime())
+
+	superseded := SyncActionUpdateCustomer
+	if action == SyncActionUpdateSeats {
+		superseded = SyncActionUpdateSeats
+	}
+
+	err = ss.app.RunInTransaction(func(txApp core.App) error {
+		if _, err := txApp.DB().Update(
+			constants.CollectionPolarSyncJobs,
+			dbx.Params{"status": SyncStatusSuperseded, "next_attempt": ""},
+			dbx.HashExp{
+				"external_id": externalID,
+				"action":      superseded,
+				"status":      SyncStatusPending,
+			},
+		).Execute(); err != nil {
+			return err
+		}
+		return txApp.Save(job)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to enqueue %s job for %s: %w", action, externalID, err)
+	}
+
+	return job, nil
+}
+
+// ProcessPending runs every pending job whose retry time has passed
+func (ss *PolarSyncService) ProcessPending() {
+	jobs, err := ss.app.FindRecordsByFilter(
+		constants.CollectionPolarSyncJobs,
+		"status = {:status} && next_attempt <= {:now}",
+		"created",
+		50,
+		0,
+		dbx.Params{"status": SyncStatusPending, "now": types.NowDateTime()},
+	)
+	if err != nil {
+		log.Printf("Error finding pending Polar sync jobs: %v", err)
+		return
+	}
+
+	for _, job := range jobs {
+		ss.process(job)
+	}
+}
+
+// ProcessPendingFor processes the due pending jobs of a user or workspace in the background,
+// for jobs queued in a transaction once it is committed
+func (ss *PolarSyncService) ProcessPendingFor(externalID string) {
+	jobs, err := ss.app.FindRecordsByFilter(
+		constants.CollectionPolarSyncJobs,
+		"external_id = {:id} && status = {:status} && next_attempt <= {:now}",
+		"created",
+		0,
+		0,
+		dbx.Params{"id": externalID, "status": SyncStatusPending, "now": types.NowDateTime()},
+	)
+	if err != nil {
+		log.Printf("Error finding pending Polar sync jobs of %s: %v", externalID, err)
+		return
+	}
+
+	for _, job := range jobs {
+		go ss.process(job)
+	}
+}
+
+// claim takes a due pending job for this run by pushing its next attempt past syncJobLease
+// Enqueue and ProcessPending may both pick up a new job, only the one whose update matches runs it
+func (ss *PolarSyncService) claim(job *core.Record) (bool, error) {
+	now := types.NowDateTime()
+	result, err := ss.app.DB().Update(
+		constants.CollectionPolarSyncJobs,
+		dbx.Params{"next_attempt": now.Add(syncJobLease)},
+		dbx.And(
+			dbx.HashExp{"id": job.Id, "status": SyncStatusPending},
+			dbx.NewExp("next_attempt <= {:now}", dbx.Params{"now": now}),
+		),
+	).Execute()
+	if err != nil {
+		return false, err
+	}
+
+	claimed, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return claimed == 1, nil
+}
+
+// process runs a single job and records its outcome, unless another run claimed it
+func (ss *PolarSyncService) process(job *core.Record) {
+	claimed, err := ss.claim(job)
+	if err != nil {
+		log.Printf("Error claiming Polar sync job %s: %v", job.Id, err)
+		return
+	}
+	if !claimed {
+		return
+	}
+
+	err = ss.run(job)
+	if errors.Is(err, errSyncJobSuperseded) {
+		job.Set("status", SyncStatusSuperseded)
+		job.Set("last_error", "")
+		job.Set("next_attempt", nil)
+	} else if err == nil {
+		job.Set("status", SyncStatusDone)
+		job.Set("last_error", "")
+		job.Set("next_attempt", nil)
+	} else {
+		attempts := job.GetInt("attempts") + 1
+		job.Set("attempts", attempts)
+		job.Set("last_error", err.Error())
+
+		if attempts >= maxSyncAttempts {
+			job.Set("status", SyncStatusFailed)
+			job.Set("next_attempt", nil)
+			log.Printf("Error: Polar sync job %s failed permanently: %v", job.Id, err)
+		} else {
+			backoff := time.Duration(1<<attempts) * time.Minute
+			if backoff > maxSyncBackoff {
+				backoff = maxSyncBackoff
+			}
+			job.Set("next_attempt", time.Now().Add(backoff))
+			log.Printf("Warning: Polar sync job %s failed, retrying in %s: %v", job.Id, backoff, err)
+		}
+	}
+
+	if saveErr := ss.app.Save(job); saveErr != nil {
+		log.Printf("Error saving Polar sync job %s: %v", job.Id, saveErr)
+	}
+}
+
+// run executes the Polar calls of a job
+func (ss *PolarSyncService) run(job *core.Record) error {
+	externalID := job.GetString("external_id")
+
+	switch job.GetString("action") {
+	case SyncActionUpdateCustomer:
+		user, err := ss.currentProfile(externalID)
+		if err != nil {
+			return err
+		}
+		return ss.polar.UpdateCustomerProfile(externalID, user.GetString("email"), user.GetString("name"))
+
+	case SyncActionDeleteCustomer:
+		// Cancel subscriptions first so a deleted user is never billed again
+		if _, err := ss.polar.RevokeCustomerSubscriptions(externalID); err != nil {
+			return err
+		}
+		if config.Current().PolarCustomerDeletion == config.PolarCustomerDelete {
+			return ss.polar.DeleteCustomer(externalID)
+		}
+		return ss.polar.AnonymizeCustomer(externalID)
+
+	case SyncActionUpdateSeats:
+		workspace, err := ss.app.FindRecordById(constants.CollectionWorkspaces, externalID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return errSyncJobSuperseded
+		}
+		if err != nil {
+			return err
+		}
+		return NewSeatService(ss.app).ReserveSeat(workspace)
+
+	default:
+		return fmt.Errorf("unknown sync action %q", job.GetString("action"))
+	}
+}
+
+// currentProfile returns the user whose profile an update_customer job sends to Polar
+// Users that were deleted, or whose Polar customer is being deleted, supersede the job
+// so a retried update never restores the profile of a deleted user
+func (ss *PolarSyncService) currentProfile(externalID string) (*core.Record, error) {
+	deletions, err := ss.app.CountRecords(
+		constants.CollectionPolarSyncJobs,
+		dbx.HashExp{"external_id": externalID, "action": SyncActionDeleteCustomer},
+	)
+	if err != nil {
+		return nil, err
+	}
+	if deletions > 0 {
+		return nil, errSyncJobSuperseded
+	}
+
+	user, err := ss.app.FindRecordById(constants.CollectionUsers, externalID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errSyncJobSuperseded
+	}
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
@@ -0,0 +1,167 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"pocketvue/config"
+	"pocketvue/constants"
+	"pocketvue/types"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// SeatService tracks seat usage of workspaces and keeps it in sync with Polar
+type SeatService struct {
+	app   core.App
+	polar *PolarService
+}
+
+// NewSeatService creates a new seat service instance
+func NewSeatService(app core.App) *SeatService {
+	return &SeatService{
+		app:   app,
+		polar: NewPolarService(),
+	}
+}
+
+// SeatLimitError is returned when a workspace has no seat left for a new member
+type SeatLimitError struct {
+	Used  int
+	Limit int
+}
+
+func (e *SeatLimitError) Error() string {
+	return fmt.Sprintf("all %d seats of this workspace are in use", e.Limit)
+}
+
+// seatReservationKey marks contexts whose member saves skip the seat checks
+type seatReservationKey struct{}
+
+// WithoutSeatReservation returns a context for saving members that neither need a free seat nor bill one,
+// such as the new owner added by an ownership transfer
+func WithoutSeatReservation(ctx context.Context) context.Context {
+	return context.WithValue(ctx, seatReservationKey{}, true)
+}
+
+// SkipsSeatReservation reports whether members saved with the context skip the seat checks
+func SkipsSeatReservation(ctx context.Context) bool {
+	skip, _ := ctx.Value(seatReservationKey{}).(bool)
+	return skip
+}
+
+// CountUsedSeats returns the number of seats used by a workspace
+// Every member takes one seat, including the owner
+func (ss *SeatService) CountUsedSeats(workspaceID string) (int, error) {
+	members, err := ss.app.CountRecords(
+		constants.CollectionWorkspaceMembers,
+		dbx.HashExp{"workspace": workspaceID},
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count members of workspace %s: %w", workspaceID, err)
+	}
+	return int(members), nil
+}
+
+// SeatLimit returns the number of seats available to a workspace (0 means unlimited)
+func (ss *SeatService) SeatLimit(workspace *core.Record) int {
+	if workspace.GetString("subscription_id") == "" {
+		return config.Current().FreeWorkspaceSeats
+	}
+	return workspace.GetInt("seats")
+}
+
+// CheckSeat checks that a new member fits in the workspace seats before it is saved
+// A full workspace rejects the member unless the SeatOverageMode setting bills extra seats,
+// in which case it reports true and ReserveSeat must run once the member is saved
+func (ss *SeatService) CheckSeat(workspace *core.Record) (bool, error) {
+	limit := ss.SeatLimit(workspace)
+	if limit == 0 {
+		return false, nil
+	}
+
+	used, err := ss.CountUsedSeats(workspace.Id)
+	if err != nil {
+		return false, err
+	}
+
+	if used < limit {
+		return false, nil
+	}
+
+	if config.Current().SeatOverageMode != config.SeatOverageIncrease || workspace.GetString("subscription_id") == "" {
+		return false, &SeatLimitError{Used: used, Limit: limit}
+	}
+	return true, nil
+}
+
+// ReserveSeat increases the seat quantity in Polar when the saved members exceed the workspace seats
+// It runs as an update_seats job of the Polar sync queue, queued with the member,
+// so a failed save never bills a seat and a failed Polar call is retried
+func (ss *SeatService) ReserveSeat(workspace *core.Record) error {
+	limit := ss.SeatLimit(workspace)
+	subscriptionID := workspace.GetString("subscription_id")
+	if limit == 0 || subscriptionID == "" || config.Current().SeatOverageMode != config.SeatOverageIncrease {
+		return nil
+	}
+
+	used, err := ss.CountUsedSeats(workspace.Id)
+	if err != nil {
+		return err
+	}
+
+	if used <= limit {
+		return nil
+	}
+
+	// Bill the extra seats, the subscription.updated webhook will confirm the new quantity
+	if err := ss.polar.UpdateSubscriptionSeats(subscriptionID, used); err != nil {
+		return fmt.Errorf("failed to increase seats for workspace %s: %w", workspace.Id, err)
+	}
+
+	workspace.Set("seats", used)
+	if err := ss.app.Save(workspace); err != nil {
+		return fmt.Errorf("failed to update seats for workspace %s: %w", workspace.Id, err)
+	}
+
+	log.Printf("Increased seats for workspace %s to %d", workspace.Id, used)
+	return nil
+}
+
+// SyncSubscriptionSeats stores the seat quantity of a workspace subscription locally
+// Subscriptions are linked to a workspace through the workspace_id checkout metadata
+func (ss *SeatService) SyncSubscriptionSeats(subData types.SubscriptionWebhookData, active bool) error {
+	workspaceID, ok := subData.Metadata["workspace_id"].(string)
+	if !ok || workspaceID == "" {
+		return nil
+	}
+
+	workspace, err := ss.app.FindRecordById(constants.CollectionWorkspaces, workspaceID)
+	if err != nil {
+		return fmt.Errorf("failed to find workspace %s for subscription %s: %w", workspaceID, subData.ID, err)
+	}
+
+	if !active {
+		// Only clear the seats if the ended subscription is the one linked to the workspace
+		if workspace.GetString("subscription_id") != subData.ID {
+			return nil
+		}
+		workspace.Set("subscription_id", "")
+		workspace.Set("seats", 0)
+	} else {
+		workspace.Set("subscription_id", subData.ID)
+		if subData.Seats != nil {
+			workspace.Set("seats", *subData.Seats)
+		}
+	}
+
+	if err := ss.app.Save(workspace); err != nil {
+		return fmt.Errorf("failed to update seats for workspace %s: %w", workspaceID, err)
+	}
+
+	log.Printf("Synced seats for workspace %s: subscription_id=%s, seats=%d",
+		workspaceID, workspace.GetString("subscription_id"), workspace.GetInt("seats"))
+
+	return nil
+}
@@ -0,0 +1,60 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"pocketvue/config"
+	"pocketvue/constants"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// SettingsService loads the configuration stored in the app_settings collection
+// Superusers edit these records in the dashboard, their values take precedence over env and the config file
+type SettingsService struct {
+	app core.App
+}
+
+// NewSettingsService creates a new settings service instance
+func NewSettingsService(app core.App) *SettingsService {
+	return &SettingsService{
+		app: app,
+	}
+}
+
+// Load returns the stored settings keyed by their configuration key
+func (ss *SettingsService) Load() (map[string]string, error) {
+	if _, err := ss.app.FindCachedCollectionByNameOrId(constants.CollectionAppSettings); err != nil {
+		return nil, fmt.Errorf("%s collection not found, run the migrations first", constants.CollectionAppSettings)
+	}
+
+	records, err := ss.app.FindAllRecords(constants.CollectionAppSettings)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch app settings: %w", err)
+	}
+
+	values := make(map[string]string, len(records))
+	for _, record := range records {
+		key := record.GetString("key")
+		value, err := config.DecryptSetting(record.GetString("value"))
+		if err != nil {
+			// Fall back to the lower layers rather than using a corrupted secret
+			log.Printf("Warning: failed to decrypt app setting %s: %v", key, err)
+			continue
+		}
+		values[key] = value
+	}
+
+	return values, nil
+}
+
+// Reload applies the stored settings to the runtime configuration
+func (ss *SettingsService) Reload() error {
+	values, err := ss.Load()
+	if err != nil {
+		return err
+	}
+
+	config.SetSettings(values)
+	return nil
+}
@@ -0,0 +1,239 @@
+package services
+
+import (
+	"fmt"
+	"pocketvue/config"
+	"pocketvue/constants"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/security"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// Codes of slug validation errors, they are returned in the slug field errors of record requests
+const (
+	SlugErrorRequired = "validation_required"
+	SlugErrorLength   = "validation_slug_length"
+	SlugErrorPattern  = "validation_invalid_slug"
+	SlugErrorReserved = "validation_reserved_slug"
+	SlugErrorTaken    = "validation_not_unique"
+	SlugErrorCooling  = "validation_slug_cooling_off"
+)
+
+var (
+	slugPattern     = regexp.MustCompile(constants.WorkspaceSlugPattern)
+	slugInvalidRuns = regexp.MustCompile(`[^a-z0-9]+`)
+	reservedSlugs   = make(map[string]struct{}, len(constants.ReservedWorkspaceSlugs))
+)
+
+func init() {
+	for _, slug := range constants.ReservedWorkspaceSlugs {
+		reservedSlugs[slug] = struct{}{}
+	}
+}
+
+// SlugError describes why a slug cannot be used
+type SlugError struct {
+	Code    string
+	Message string
+}
+
+func (e *SlugError) Error() string {
+	return e.Message
+}
+
+// SlugService validates workspace slugs and checks their availability
+type SlugService struct {
+	app core.App
+}
+
+// NewSlugService creates a new slug service instance
+func NewSlugService(app core.App) *SlugService {
+	return &SlugService{app: app}
+}
+
+// NormalizeSlug lowercases a slug and replaces every run of other characters with a single hyphen
+// For example " My Workspace_2 " becomes "my-workspace-2"
+func NormalizeSlug(slug string) string {
+	slug = strings.ToLower(strings.TrimSpace(slug))
+	slug = slugInvalidRuns.ReplaceAllString(slug, "-")
+	return strings.Trim(slug, "-")
+}
+
+// ValidateSlugFormat checks the length, pattern and reserved list of a normalized slug
+// It does not check whether the slug is already used
+func ValidateSlugFormat(slug string) *SlugError {
+	if slug == "" {
+		return &SlugError{Code: SlugErrorRequired, Message: "Slug is required."}
+	}
+	if len(slug) < constants.WorkspaceSlugMinLength || len(slug) > constants.WorkspaceSlugMaxLength {
+		return &SlugError{
+			Code: SlugErrorLength,
+			Message: fmt.Sprintf(
+				"Slug must be between %d and %d characters.",
+				constants.WorkspaceSlugMinLength,
+				constants.WorkspaceSlugMaxLength,
+			),
+		}
+	}
+	if !slugPattern.MatchString(slug) {
+		return &SlugError{Code: SlugErrorPattern, Message: "Slug can only contain lowercase letters, numbers and single hyphens."}
+	}
+	if IsReservedSlug(slug) {
+		return &SlugError{Code: SlugErrorReserved, Message: "This URL is reserved. Please choose a different one."}
+	}
+	return nil
+}
+
+// IsReservedSlug reports whether a slug is on the reserved list
+func IsReservedSlug(slug string) bool {
+	_, ok := reservedSlugs[slug]
+	return ok
+}
+
+// Check validates a normalized slug and makes sure no other workspace uses it
+// excludeWorkspaceID is the workspace being updated, it may keep its own slug
+func (ss *SlugService) Check(slug, excludeWorkspaceID string) (*SlugError, error) {
+	if slugErr := ValidateSlugFormat(slug); slugErr != nil {
+		return slugErr, nil
+	}
+
+	taken, err := ss.isTaken(slug, excludeWorkspaceID)
+	if err != nil {
+		return nil, err
+	}
+	if taken {
+		return &SlugError{Code: SlugErrorTaken, Message: "This URL is already taken. Please choose a different one."}, nil
+	}
+
+	cooling, err := ss.isCoolingOff(slug, excludeWorkspaceID)
+	if err != nil {
+		return nil, err
+	}
+	if cooling {
+		return &SlugError{Code: SlugErrorCooling, Message: "This URL was recently used by another workspace. Please choose a different one."}, nil
+	}
+
+	return nil, nil
+}
+
+// RecordRename keeps the previous slug of a renamed workspace in the slug history
+// The previous slug stays reserved for the workspace during the cooling-off period and keeps resolving to it
+func (ss *SlugService) RecordRename(workspaceID, previousSlug, newSlug string) error {
+	// A workspace going back to one of its previous slugs takes it out of the history
+	if _, err := ss.app.DB().Delete(constants.CollectionWorkspaceSlugHistory, dbx.NewExp(
+		"workspace = {:workspace} AND LOWER(slug) = {:slug}",
+		dbx.Params{"workspace": workspaceID, "slug": strings.ToLower(newSlug)},
+	)).Execute(); err != nil {
+		return fmt.Errorf("failed to clear slug history of workspace %s: %w", workspaceID, err)
+	}
+
+	if previousSlug == "" {
+		return nil
+	}
+
+	collection, err := ss.app.FindCollectionByNameOrId(constants.CollectionWorkspaceSlugHistory)
+	if err != nil {
+		return err
+	}
+
+	history := core.NewRecord(collection)
+	history.Set("workspace", workspaceID)
+	history.Set("slug", previousSlug)
+	history.Set("reserved_until", time.Now().Add(config.Current().WorkspaceSlugCooldown))
+
+	if err := ss.app.Save(history); err != nil {
+		return fmt.Errorf("failed to record previous slug %s of workspace %s: %w", previousSlug, workspaceID, err)
+	}
+	return nil
+}
+
+// Suggest returns up to limit available slugs derived from the requested one
+func (ss *SlugService) Suggest(slug string, limit int) ([]string, error) {
+	base := NormalizeSlug(slug)
+	if len(base) < constants.WorkspaceSlugMinLength {
+		base = strings.Trim(base+"-workspace", "-")
+	}
+	// Leave room for the longest suffix
+	if maxBase := constants.WorkspaceSlugMaxLength - len("-team"); len(base) > maxBase {
+		base = strings.Trim(base[:maxBase], "-")
+	}
+
+	candidates := []string{base}
+	for _, suffix := range []string{"team", "hq", "app"} {
+		candidates = append(candidates, base+"-"+suffix)
+	}
+	for i := 2; i <= 20; i++ {
+		candidates = append(candidates, base+"-"+strconv.Itoa(i))
+	}
+
+	suggestions := make([]string, 0, limit)
+	for _, candidate := range candidates {
+		if len(suggestions) >= limit {
+			break
+		}
+		slugErr, err := ss.Check(candidate, "")
+		if err != nil {
+			return nil, err
+		}
+		if slugErr == nil {
+			suggestions = append(suggestions, candidate)
+		}
+	}
+
+	return suggestions, nil
+}
+
+// Available returns the requested slug if it can be used, or an available variant of it
+func (ss *SlugService) Available(requested string) (string, error) {
+	slug := NormalizeSlug(requested)
+	slugErr, err := ss.Check(slug, "")
+	if err != nil {
+		return "", err
+	}
+	if slugErr == nil {
+		return slug, nil
+	}
+
+	suggestions, err := ss.Suggest(requested, 1)
+	if err != nil {
+		return "", err
+	}
+	if len(suggestions) > 0 {
+		return suggestions[0], nil
+	}
+
+	return "workspace-" + security.RandomStringWithAlphabet(8, "abcdefghijklmnopqrstuvwxyz0123456789"), nil
+}
+
+// isCoolingOff reports whether another workspace used the slug recently enough to still reserve it
+func (ss *SlugService) isCoolingOff(slug, excludeWorkspaceID string) (bool, error) {
+	count, err := ss.app.CountRecords(
+		constants.CollectionWorkspaceSlugHistory,
+		dbx.NewExp(
+			"LOWER(slug) = {:slug} AND workspace != {:workspace} AND reserved_until > {:now}",
+			dbx.Params{"slug": slug, "workspace": excludeWorkspaceID, "now": types.NowDateTime().String()},
+		),
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to check slug history of %s: %w", slug, err)
+	}
+	return count > 0, nil
+}
+
+// isTaken reports whether another workspace uses the slug
+func (ss *SlugService) isTaken(slug, excludeWorkspaceID string) (bool, error) {
+	count, err := ss.app.CountRecords(
+		constants.CollectionWorkspaces,
+		dbx.NewExp("LOWER(slug) = {:slug} AND id != {:id}", dbx.Params{"slug": slug, "id": excludeWorkspaceID}),
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to check slug %s: %w", slug, err)
+	}
+	return count > 0, nil
+}
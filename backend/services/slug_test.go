@@ -0,0 +1,84 @@
+package services
+
+import (
+	"strings"
+	"testing"
+
+	"pocketvue/constants"
+)
+
+func TestNormalizeSlug(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{input: "acme", want: "acme"},
+		{input: " My Workspace_2 ", want: "my-workspace-2"},
+		{input: "ACME Corp", want: "acme-corp"},
+		{input: "a  --  b", want: "a-b"},
+		{input: "--acme--", want: "acme"},
+		{input: "déjà vu", want: "d-j-vu"},
+		{input: "notes/../admin", want: "notes-admin"},
+		{input: "!!!", want: ""},
+		{input: "", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			if got := NormalizeSlug(tt.input); got != tt.want {
+				t.Fatalf("NormalizeSlug(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateSlugFormat(t *testing.T) {
+	tests := []struct {
+		name string
+		slug string
+		want string
+	}{
+		{name: "valid", slug: "acme-notes"},
+		{name: "digits", slug: "team-42"},
+		{name: "minimum length", slug: strings.Repeat("a", constants.WorkspaceSlugMinLength)},
+		{name: "maximum length", slug: strings.Repeat("a", constants.WorkspaceSlugMaxLength)},
+		{name: "empty", slug: "", want: SlugErrorRequired},
+		{name: "too short", slug: strings.Repeat("a", constants.WorkspaceSlugMinLength-1), want: SlugErrorLength},
+		{name: "too long", slug: strings.Repeat("a", constants.WorkspaceSlugMaxLength+1), want: SlugErrorLength},
+		{name: "uppercase", slug: "Acme", want: SlugErrorPattern},
+		{name: "double hyphen", slug: "acme--notes", want: SlugErrorPattern},
+		{name: "leading hyphen", slug: "-acme", want: SlugErrorPattern},
+		{name: "trailing hyphen", slug: "acme-", want: SlugErrorPattern},
+		{name: "underscore", slug: "acme_notes", want: SlugErrorPattern},
+		{name: "reserved route", slug: "login", want: SlugErrorReserved},
+		{name: "reserved subdomain", slug: "admin", want: SlugErrorReserved},
+		{name: "reserved after normalization", slug: NormalizeSlug(" New Workspace "), want: SlugErrorReserved},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			slugErr := ValidateSlugFormat(tt.slug)
+			if tt.want == "" {
+				if slugErr != nil {
+					t.Fatalf("ValidateSlugFormat(%q) = %s, want no error", tt.slug, slugErr.Code)
+				}
+				return
+			}
+			if slugErr == nil || slugErr.Code != tt.want {
+				t.Fatalf("ValidateSlugFormat(%q) = %v, want %s", tt.slug, slugErr, tt.want)
+			}
+		})
+	}
+}
+
+func TestReservedSlugsAreNormalized(t *testing.T) {
+	// A reserved slug that normalizes differently could never match a normalized slug
+	for _, slug := range constants.ReservedWorkspaceSlugs {
+		if NormalizeSlug(slug) != slug {
+			t.Errorf("reserved slug %q is not normalized", slug)
+		}
+		if !IsReservedSlug(slug) {
+			t.Errorf("reserved slug %q is not reported as reserved", slug)
+		}
+	}
+}
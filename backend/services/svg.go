@@ -0,0 +1,186 @@
+package services
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// ErrInvalidSVG is returned when an SVG cannot be parsed or has no svg root element
+var ErrInvalidSVG = errors.New("invalid SVG document")
+
+// unsafeSVGElements are removed from SVGs together with their content
+var unsafeSVGElements = map[string]bool{
+	"script":        true,
+	"foreignobject": true,
+	"iframe":        true,
+	"embed":         true,
+	"object":        true,
+	"audio":         true,
+	"video":         true,
+	"handler":       true,
+	"listener":      true,
+	"set":           true,
+}
+
+// cssURLPattern matches the targets of url() references in styles and presentation attributes
+var cssURLPattern = regexp.MustCompile(`(?i)url\(\s*['"]?\s*([^'")\s]*)`)
+
+var (
+	svgTextEscaper      = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+	svgAttributeEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;")
+)
+
+// SanitizeSVG returns a copy of an SVG document without scripts, event handlers and external references
+// Only references to fragments of the document itself (#id) are kept,
+// comments, processing instructions and DOCTYPE declarations are dropped.
+// Documents whose elements are not properly nested under a single svg root are rejected
+func SanitizeSVG(content []byte) ([]byte, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(content))
+	decoder.Strict = true
+
+	var out bytes.Buffer
+	hasRoot := false
+	// skipDepth counts the open elements inside a removed element
+	skipDepth := 0
+	// styleDepth counts the open style elements, their text is CSS
+	styleDepth := 0
+	// open holds the names of the open elements, RawToken does not check that end tags match them
+	var open []xml.Name
+
+	for {
+		// RawToken keeps namespace prefixes as written, so the output uses the same names as the input
+		token, err := decoder.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, ErrInvalidSVG
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			// A document has a single root element
+			if hasRoot && len(open) == 0 {
+				return nil, ErrInvalidSVG
+			}
+			open = append(open, t.Name)
+			if skipDepth > 0 || isUnsafeSVGElement(t) {
+				skipDepth++
+				continue
+			}
+			if !hasRoot {
+				if !strings.EqualFold(t.Name.Local, "svg") {
+					return nil, ErrInvalidSVG
+				}
+				hasRoot = true
+			}
+			if strings.EqualFold(t.Name.Local, "style") {
+				styleDepth++
+			}
+
+			out.WriteString("<" + svgName(t.Name))
+			for _, attr := range t.Attr {
+				if isUnsafeSVGAttribute(attr) {
+					continue
+				}
+				out.WriteString(" " + svgName(attr.Name) + `="` + svgAttributeEscaper.Replace(attr.Value) + `"`)
+			}
+			out.WriteString(">")
+
+		case xml.EndElement:
+			if len(open) == 0 || open[len(open)-1] != t.Name {
+				return nil, ErrInvalidSVG
+			}
+			open = open[:len(open)-1]
+			if skipDepth > 0 {
+				skipDepth--
+				continue
+			}
+			if strings.EqualFold(t.Name.Local, "style") && styleDepth > 0 {
+				styleDepth--
+			}
+			out.WriteString("</" + svgName(t.Name) + ">")
+
+		case xml.CharData:
+			if skipDepth > 0 || !hasRoot {
+				continue
+			}
+			text := string(t)
+			if styleDepth > 0 && !isSafeCSS(text) {
+				continue
+			}
+			out.WriteString(svgTextEscaper.Replace(text))
+		}
+	}
+
+	if !hasRoot || len(open) > 0 {
+		return nil, ErrInvalidSVG
+	}
+
+	return out.Bytes(), nil
+}
+
+// isUnsafeSVGElement reports whether an element is removed from SVGs
+// Animations are removed when they change links, since they could point them to scripts
+func isUnsafeSVGElement(element xml.StartElement) bool {
+	name := strings.ToLower(element.Name.Local)
+	if unsafeSVGElements[name] {
+		return true
+	}
+
+	if strings.HasPrefix(name, "animate") {
+		for _, attr := range element.Attr {
+			if strings.EqualFold(attr.Name.Local, "attributeName") && strings.HasSuffix(strings.ToLower(attr.Value), "href") {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// isUnsafeSVGAttribute reports whether an attribute is removed from SVGs
+// Event handlers, links to anything but fragments of the document and external url() references are removed
+func isUnsafeSVGAttribute(attr xml.Attr) bool {
+	name := strings.ToLower(attr.Name.Local)
+	value := strings.TrimSpace(attr.Value)
+
+	if strings.HasPrefix(name, "on") {
+		return true
+	}
+	if name == "href" && !strings.HasPrefix(value, "#") {
+		return true
+	}
+	if strings.Contains(strings.ToLower(value), "javascript:") {
+		return true
+	}
+
+	return !isSafeCSS(value)
+}
+
+// isSafeCSS reports whether a style only references fragments of the document itself
+func isSafeCSS(css string) bool {
+	lower := strings.ToLower(css)
+	if strings.Contains(lower, "@import") || strings.Contains(lower, "expression(") {
+		return false
+	}
+
+	for _, match := range cssURLPattern.FindAllStringSubmatch(css, -1) {
+		if !strings.HasPrefix(match[1], "#") {
+			return false
+		}
+	}
+	return true
+}
+
+// svgName returns an element or attribute name with its namespace prefix
+func svgName(name xml.Name) string {
+	if name.Space == "" {
+		return name.Local
+	}
+	return name.Space + ":" + name.Local
+}
@@ -0,0 +1,123 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestSanitizeSVG(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		contains []string
+		removed  []string
+		wantErr  bool
+	}{
+		{
+			name:     "plain shapes are kept",
+			input:    `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 10 10"><rect width="10" height="10" fill="red"/></svg>`,
+			contains: []string{`<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 10 10">`, `<rect width="10" height="10" fill="red">`},
+		},
+		{
+			name:     "script with content",
+			input:    `<svg><script>alert(document.cookie)</script><circle r="1"/></svg>`,
+			contains: []string{`<circle r="1">`},
+			removed:  []string{"script", "alert"},
+		},
+		{
+			name:    "uppercase script",
+			input:   `<svg><SCRIPT>alert(1)</SCRIPT></svg>`,
+			removed: []string{"SCRIPT", "alert"},
+		},
+		{
+			name:     "nested elements inside a removed element",
+			input:    `<svg><foreignObject><div><p>hi</p></div></foreignObject><g/></svg>`,
+			contains: []string{"<g>"},
+			removed:  []string{"foreignObject", "div", "hi"},
+		},
+		{
+			name:     "event handlers",
+			input:    `<svg onload="alert(1)"><rect ONCLICK="alert(2)" width="1"/></svg>`,
+			contains: []string{`<rect width="1">`},
+			removed:  []string{"onload", "ONCLICK", "alert"},
+		},
+		{
+			name:     "external href",
+			input:    `<svg xmlns:xlink="http://www.w3.org/1999/xlink"><a href="https://evil.example"><text>x</text></a><use xlink:href="https://evil.example/s.svg#a"/></svg>`,
+			contains: []string{"<a>", "<use>"},
+			removed:  []string{"evil.example"},
+		},
+		{
+			name:     "javascript href",
+			input:    `<svg><a href="javascript:alert(1)"><text>x</text></a></svg>`,
+			contains: []string{"<a>"},
+			removed:  []string{"javascript"},
+		},
+		{
+			name:     "fragment href is kept",
+			input:    `<svg xmlns:xlink="http://www.w3.org/1999/xlink"><defs><path id="p"/></defs><use xlink:href="#p"/><use href="#p"/></svg>`,
+			contains: []string{`<use xlink:href="#p">`, `<use href="#p">`},
+		},
+		{
+			name:     "animations that change links",
+			input:    `<svg><a><animate attributeName="href" to="javascript:alert(1)"/><set attributeName="fill" to="red"/><animate attributeName="opacity" to="0"/></a></svg>`,
+			contains: []string{`<animate attributeName="opacity" to="0">`},
+			removed:  []string{"javascript", "<set"},
+		},
+		{
+			name:     "external url in attributes",
+			input:    `<svg><rect fill="url(https://evil.example/x)"/><rect fill="url(#grad)"/></svg>`,
+			contains: []string{`<rect fill="url(#grad)">`},
+			removed:  []string{"evil.example"},
+		},
+		{
+			name:     "unsafe styles",
+			input:    `<svg><style>@import url(https://evil.example/a.css);</style><style>rect { fill: url(#grad) }</style></svg>`,
+			contains: []string{"rect { fill: url(#grad) }"},
+			removed:  []string{"@import", "evil.example"},
+		},
+		{
+			name:     "comments and doctype",
+			input:    `<?xml version="1.0"?><!DOCTYPE svg><!-- hidden --><svg><g/></svg>`,
+			contains: []string{"<svg><g>"},
+			removed:  []string{"DOCTYPE", "hidden", "<?xml"},
+		},
+		{
+			name:     "text is escaped",
+			input:    `<svg><text>a &lt;b&gt; &amp; c</text></svg>`,
+			contains: []string{"<text>a &lt;b&gt; &amp; c</text>"},
+		},
+		{name: "not an svg", input: `<html><body/></html>`, wantErr: true},
+		{name: "mismatched end tag", input: `<svg><rect></svg>`, wantErr: true},
+		{name: "unclosed root", input: `<svg><rect/>`, wantErr: true},
+		{name: "second root element", input: `<svg></svg><script>alert(1)</script>`, wantErr: true},
+		{name: "empty", input: ``, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output, err := SanitizeSVG([]byte(tt.input))
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidSVG) {
+					t.Fatalf("expected ErrInvalidSVG, got %v with output %q", err, output)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			for _, want := range tt.contains {
+				if !strings.Contains(string(output), want) {
+					t.Errorf("output %q does not contain %q", output, want)
+				}
+			}
+			for _, unwanted := range tt.removed {
+				if strings.Contains(string(output), unwanted) {
+					t.Errorf("output %q still contains %q", output, unwanted)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,327 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"html/template"
+	"log"
+	"net/mail"
+	"net/url"
+	"pocketvue/config"
+	"pocketvue/constants"
+	"pocketvue/helpers"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/mailer"
+	"github.com/pocketbase/pocketbase/tools/security"
+)
+
+// Workspace transfer statuses stored in the workspace_transfers.status field
+const (
+	TransferStatusPending   = "pending"
+	TransferStatusAccepted  = "accepted"
+	TransferStatusCancelled = "cancelled"
+)
+
+// transferTokenType identifies ownership transfer tokens among the other JWTs issued by the app
+const transferTokenType = "workspaceTransfer"
+
+var (
+	// ErrTransferInvalid is returned for unknown, expired, cancelled or already used transfer tokens
+	ErrTransferInvalid = errors.New("transfer is invalid or has expired")
+
+	// ErrNotWorkspaceOwner is returned when someone other than the owner starts a transfer
+	ErrNotWorkspaceOwner = errors.New("only the workspace owner can transfer ownership")
+
+	// ErrTransferRecipientNotFound is returned when the recipient email has no active account
+	ErrTransferRecipientNotFound = errors.New("no active account exists with this email, the recipient must sign up first")
+
+	// ErrTransferToSelf is returned when the owner tries to transfer a workspace to themselves
+	ErrTransferToSelf = errors.New("you already own this workspace")
+
+	// ErrTransferWrongRecipient is returned when a transfer is accepted from another account than the recipient
+	ErrTransferWrongRecipient = errors.New("transfer was sent to a different account")
+)
+
+// TransferService moves the ownership of workspaces between users
+// The owner starts a transfer and the recipient accepts it from the emailed link
+// Every transfer stays in the workspace_transfers collection as its audit trail
+type TransferService struct {
+	app core.App
+}
+
+// NewTransferService creates a new transfer service instance
+func NewTransferService(app core.App) *TransferService {
+	return &TransferService{
+		app: app,
+	}
+}
+
+// Initiate starts transferring a workspace to the user with the email and emails them the accept link
+// A pending transfer of the workspace is cancelled first, a workspace has at most one
+func (ts *TransferService) Initiate(workspace, owner *core.Record, email, ip string) (*core.Record, error) {
+	if workspace.GetString("user") != owner.Id {
+		return nil, ErrNotWorkspaceOwner
+	}
+
+	recipient, err := ts.app.FindAuthRecordByEmail(constants.CollectionUsers, normalizeEmail(email))
+	if err != nil || recipient.GetBool("banned") {
+		return nil, ErrTransferRecipientNotFound
+	}
+	if recipient.Id == owner.Id {
+		return nil, ErrTransferToSelf
+	}
+
+	pending, err := ts.FindPending(workspace.Id)
+	if err != nil {
+		return nil, err
+	}
+	if pending != nil {
+		if err := ts.Cancel(pending); err != nil {
+			return nil, err
+		}
+	}
+
+	collection, err := ts.app.FindCollectionByNameOrId(constants.CollectionWorkspaceTransfers)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find workspace_transfers collection: %w", err)
+	}
+
+	transfer := core.NewRecord(collection)
+	transfer.Set("workspace", workspace.Id)
+	transfer.Set("from_user", owner.Id)
+	transfer.Set("to_user", recipient.Id)
+	transfer.Set("status", TransferStatusPending)
+	transfer.Set("token_key", security.RandomString(50))
+	transfer.Set("expires_at", time.Now().Add(config.Current().WorkspaceTransferTTL))
+	transfer.Set("initiated_ip", ip)
+
+	if err := ts.app.Save(transfer); err != nil {
+		return nil, fmt.Errorf("failed to save transfer of workspace %s: %w", workspace.Id, err)
+	}
+
+	if err := ts.send(transfer, workspace, owner, recipient); err != nil {
+		// A transfer the recipient never received cannot be accepted, keep it in the trail as cancelled
+		if cancelErr := ts.Cancel(transfer); cancelErr != nil {
+			log.Printf("Error cancelling unsent transfer %s: %v", transfer.Id, cancelErr)
+		}
+		return nil, err
+	}
+
+	log.Printf("User %s started transferring workspace %s to user %s", owner.Id, workspace.Id, recipient.Id)
+	return transfer, nil
+}
+
+// FindPending returns the pending, unexpired transfer of a workspace or nil
+func (ts *TransferService) FindPending(workspaceID string) (*core.Record, error) {
+	transfer, err := ts.app.FindFirstRecordByFilter(
+		constants.CollectionWorkspaceTransfers,
+		"workspace = {:workspace} && status = {:status}",
+		dbx.Params{"workspace": workspaceID, "status": TransferStatusPending},
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to find pending transfer of workspace %s: %w", workspaceID, err)
+	}
+	if isTransferExpired(transfer) {
+		return nil, nil
+	}
+	return transfer, nil
+}
+
+// Cancel cancels a pending transfer, its link stops working immediately
+func (ts *TransferService) Cancel(transfer *core.Record) error {
+	if transfer.GetString("status") != TransferStatusPending {
+		return ErrTransferInvalid
+	}
+
+	transfer.Set("status", TransferStatusCancelled)
+	transfer.Set("cancelled_at", time.Now())
+	transfer.Set("token_key", security.RandomString(50))
+
+	if err := ts.app.Save(transfer); err != nil {
+		return fmt.Errorf("failed to cancel transfer %s: %w", transfer.Id, err)
+	}
+	return nil
+}
+
+// FindByToken returns the pending transfer a token was issued for
+func (ts *TransferService) FindByToken(token string) (*core.Record, error) {
+	claims, err := security.ParseUnverifiedJWT(token)
+	if err != nil || claims["type"] != transferTokenType {
+		return nil, ErrTransferInvalid
+	}
+
+	transferID, _ := claims["id"].(string)
+	transfer, err := ts.app.FindRecordById(constants.CollectionWorkspaceTransfers, transferID)
+	if err != nil {
+		return nil, ErrTransferInvalid
+	}
+
+	// The signing key is rotated on cancel and accept, which makes every token single-use
+	if _, err := security.ParseJWT(token, transfer.GetString("token_key")); err != nil {
+		return nil, ErrTransferInvalid
+	}
+
+	if transfer.GetString("status") != TransferStatusPending || isTransferExpired(transfer) {
+		return nil, ErrTransferInvalid
+	}
+
+	return transfer, nil
+}
+
+// Accept makes the user the owner of the workspace of a transfer
+// The previous owner stays in the workspace as an admin
+// Billing does not move: Polar cannot move a subscription to another customer, so a subscription
+// of the workspace stays with the previous owner until it is bought again, billing_transferred stays false
+func (ts *TransferService) Accept(ctx context.Context, token string, user *core.Record, ip string) (*core.Record, error) {
+	transfer, err := ts.FindByToken(token)
+	if err != nil {
+		return nil, err
+	}
+
+	if transfer.GetString("to_user") != user.Id {
+		return nil, ErrTransferWrongRecipient
+	}
+
+	workspace, err := ts.app.FindRecordById(constants.CollectionWorkspaces, transfer.GetString("workspace"))
+	if err != nil {
+		return nil, ErrTransferInvalid
+	}
+
+	// Deleting a workspace cancels its transfer, a deleted workspace is never handed over
+	if helpers.IsWorkspaceDeleted(workspace) {
+		return nil, ErrTransferInvalid
+	}
+
+	// The workspace changed hands since the transfer started
+	previousOwnerID := transfer.GetString("from_user")
+	if workspace.GetString("user") != previousOwnerID {
+		return nil, ErrTransferInvalid
+	}
+
+	err = ts.app.RunInTransaction(func(txApp core.App) error {
+		workspace.Set("user", user.Id)
+		if err := txApp.SaveWithContext(ctx, workspace); err != nil {
+			return err
+		}
+
+		if err := setMemberRole(ctx, txApp, workspace.Id, user.Id, constants.WorkspaceRoleOwner); err != nil {
+			return err
+		}
+		if err := setMemberRole(ctx, txApp, workspace.Id, previousOwnerID, constants.WorkspaceRoleAdmin); err != nil {
+			return err
+		}
+
+		transfer.Set("status", TransferStatusAccepted)
+		transfer.Set("accepted_at", time.Now())
+		transfer.Set("accepted_ip", ip)
+		transfer.Set("billing_transferred", false)
+		transfer.Set("token_key", security.RandomString(50))
+		return txApp.Save(transfer)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("Workspace %s transferred from user %s to user %s", workspace.Id, previousOwnerID, user.Id)
+	if workspace.GetString("subscription_id") != "" {
+		log.Printf("Subscription %s of workspace %s stays with the previous owner %s",
+			workspace.GetString("subscription_id"), workspace.Id, previousOwnerID)
+	}
+	return workspace, nil
+}
+
+// setMemberRole gives a user a role in a workspace, adding the membership if needed
+// An added membership skips the seat checks, a transfer must not fail or bill a seat because the workspace is full
+func setMemberRole(ctx context.Context, app core.App, workspaceID, userID, role string) error {
+	member, err := helpers.FindWorkspaceMember(app, workspaceID, userID)
+	if err != nil {
+		return err
+	}
+
+	if member == nil {
+		ctx = WithoutSeatReservation(ctx)
+		collection, err := app.FindCollectionByNameOrId(constants.CollectionWorkspaceMembers)
+		if err != nil {
+			return err
+		}
+		member = core.NewRecord(collection)
+		member.Set("workspace", workspaceID)
+		member.Set("user", userID)
+	}
+
+	member.Set("role", role)
+	if err := app.SaveWithContext(ctx, member); err != nil {
+		return fmt.Errorf("failed to set role of user %s in workspace %s: %w", userID, workspaceID, err)
+	}
+	return nil
+}
+
+// send signs a token for the transfer and emails the accept link to the recipient
+func (ts *TransferService) send(transfer, workspace, owner, recipient *core.Record) error {
+	token, err := security.NewJWT(jwt.MapClaims{
+		"id":   transfer.Id,
+		"type": transferTokenType,
+	}, transfer.GetString("token_key"), config.Current().WorkspaceTransferTTL)
+	if err != nil {
+		return fmt.Errorf("failed to sign transfer token: %w", err)
+	}
+
+	link := helpers.BuildWorkspaceURL(workspace.GetString("slug"), "/transfer?token="+url.QueryEscape(token))
+
+	var html bytes.Buffer
+	if err := transferEmailTemplate.Execute(&html, transferEmailData{
+		AppName:       ts.app.Settings().Meta.AppName,
+		WorkspaceName: workspace.GetString("name"),
+		OwnerName:     inviterName(owner),
+		Link:          link,
+		ExpiresAt:     transfer.GetDateTime("expires_at").Time().Format("January 2, 2006"),
+	}); err != nil {
+		return fmt.Errorf("failed to render transfer email: %w", err)
+	}
+
+	message := &mailer.Message{
+		From: mail.Address{
+			Address: ts.app.Settings().Meta.SenderAddress,
+			Name:    ts.app.Settings().Meta.SenderName,
+		},
+		To:      []mail.Address{{Address: recipient.Email()}},
+		Subject: fmt.Sprintf("Take over ownership of %s", workspace.GetString("name")),
+		HTML:    html.String(),
+	}
+
+	if err := ts.app.NewMailClient().Send(message); err != nil {
+		return fmt.Errorf("failed to send transfer email: %w", err)
+	}
+	return nil
+}
+
+// transferEmailData holds the values rendered in the transfer email
+type transferEmailData struct {
+	AppName       string
+	WorkspaceName string
+	OwnerName     string
+	Link          string
+	ExpiresAt     string
+}
+
+var transferEmailTemplate = template.Must(template.New("transfer").Parse(`<p>Hello,</p>
+<p>{{.OwnerName}} wants to make you the owner of the <strong>{{.WorkspaceName}}</strong> workspace on {{.AppName}}.</p>
+<p>As the owner you will manage its members and billing. {{.OwnerName}} will stay in the workspace as an admin.</p>
+<p><a href="{{.Link}}" target="_blank" rel="noopener">Review and accept the transfer</a></p>
+<p>The link expires on {{.ExpiresAt}}. If you were not expecting it, you can ignore this email.</p>
+<p>Thanks,<br/>{{.AppName}} team</p>`))
+
+// isTransferExpired reports whether the transfer link is past its expiry
+func isTransferExpired(transfer *core.Record) bool {
+	return !transfer.GetDateTime("expires_at").Time().After(time.Now())
+}
@@ -6,6 +6,7 @@ import (
 	"log"
 	"pocketvue/constants"
 	"pocketvue/types"
+	"time"
 
 	"github.com/pocketbase/pocketbase"
 	"github.com/pocketbase/pocketbase/core"
@@ -44,14 +45,26 @@ func (ws *WebhookService) findUserByExternalID(externalID string) (*core.Record,
 	return records[0], nil
 }
 
-// updateUserSubscription updates user subscription fields based on subscription data
-// statusOverride allows overriding the status (e.g., "active" for subscription.active events)
-func (ws *WebhookService) updateUserSubscription(subData types.SubscriptionWebhookData, statusOverride string) (*core.Record, error) {
+// findSubscriptionUser finds the user a subscription belongs to
+// The user of the local subscription record wins over the paying customer,
+// since a workspace ownership transfer moves workspace subscriptions to the new owner
+func (ws *WebhookService) findSubscriptionUser(subData types.SubscriptionWebhookData) (*core.Record, error) {
+	if record, err := ws.app.FindRecordById(constants.CollectionSubscriptions, subData.ID); err == nil && record.GetString("user") != "" {
+		if user, err := ws.app.FindRecordById(constants.CollectionUsers, record.GetString("user")); err == nil {
+			return user, nil
+		}
+	}
+
 	if subData.Customer.ExternalID == nil {
 		return nil, fmt.Errorf("subscription event has no external_id, subscription_id=%s", subData.ID)
 	}
+	return ws.findUserByExternalID(*subData.Customer.ExternalID)
+}
 
-	user, err := ws.findUserByExternalID(*subData.Customer.ExternalID)
+// updateUserSubscription updates user subscription fields based on subscription data
+// statusOverride allows overriding the status (e.g., "active" for subscription.active events)
+func (ws *WebhookService) updateUserSubscription(subData types.SubscriptionWebhookData, statusOverride string) (*core.Record, error) {
+	user, err := ws.findSubscriptionUser(subData)
 	if err != nil {
 		return nil, err
 	}
@@ -76,6 +89,73 @@ func (ws *WebhookService) updateUserSubscription(subData types.SubscriptionWebho
 	return user, nil
 }
 
+// saveSubscription creates or updates the local subscription record used for billing metrics
+func (ws *WebhookService) saveSubscription(subData types.SubscriptionWebhookData) error {
+	record, err := ws.app.FindRecordById(constants.CollectionSubscriptions, subData.ID)
+	if err != nil {
+		collection, err := ws.app.FindCollectionByNameOrId(constants.CollectionSubscriptions)
+		if err != nil {
+			return fmt.Errorf("failed to find subscriptions collection: %w", err)
+		}
+		record = core.NewRecord(collection)
+		record.Set("id", subData.ID)
+	}
+
+	// Count every transition into past_due as a failed payment
+	if subData.Status == "past_due" && record.GetString("status") != "past_due" {
+		record.Set("past_due_at", subData.ModifiedAt)
+		record.Set("failed_payments", record.GetInt("failed_payments")+1)
+	}
+
+	// Keep the user once set, it changes hands with a workspace ownership transfer
+	if subData.Customer.ExternalID != nil && record.GetString("user") == "" {
+		if user, err := ws.findUserByExternalID(*subData.Customer.ExternalID); err == nil {
+			record.Set("user", user.Id)
+		}
+	}
+	if workspaceID, ok := subData.Metadata["workspace_id"].(string); ok && workspaceID != "" {
+		if _, err := ws.app.FindRecordById(constants.CollectionWorkspaces, workspaceID); err == nil {
+			record.Set("workspace", workspaceID)
+		}
+	}
+
+	record.Set("product_id", subData.ProductID)
+	record.Set("status", subData.Status)
+	record.Set("amount", subData.Amount)
+	record.Set("currency", subData.Currency)
+	record.Set("recurring_interval", subData.RecurringInterval)
+	record.Set("recurring_interval_count", subData.RecurringIntervalCount)
+	record.Set("current_period_end", subData.CurrentPeriodEnd)
+	record.Set("cancel_at_period_end", subData.CancelAtPeriodEnd)
+	if subData.Seats != nil {
+		record.Set("seats", *subData.Seats)
+	}
+
+	startedAt := subData.CreatedAt
+	if subData.StartedAt != nil {
+		startedAt = *subData.StartedAt
+	}
+	record.Set("started_at", startedAt)
+	record.Set("canceled_at", optionalTime(subData.CanceledAt))
+	record.Set("ended_at", optionalTime(subData.EndedAt))
+	record.Set("trial_start", optionalTime(subData.TrialStart))
+	record.Set("trial_end", optionalTime(subData.TrialEnd))
+
+	if err := ws.app.Save(record); err != nil {
+		return fmt.Errorf("failed to save subscription record: %w", err)
+	}
+
+	return nil
+}
+
+// optionalTime returns the value of an optional timestamp, or an empty value that clears a date field
+func optionalTime(t *time.Time) any {
+	if t == nil {
+		return ""
+	}
+	return *t
+}
+
 // HandleSubscriptionCreated handles subscription.created events
 func (ws *WebhookService) HandleSubscriptionCreated(data []byte) error {
 	var subData types.SubscriptionWebhookData
@@ -83,6 +163,14 @@ func (ws *WebhookService) HandleSubscriptionCreated(data []byte) error {
 		return fmt.Errorf("failed to parse subscription data: %w", err)
 	}
 
+	if err := ws.saveSubscription(subData); err != nil {
+		return err
+	}
+
+	if err := NewSeatService(ws.app).SyncSubscriptionSeats(subData, true); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+
 	user, err := ws.updateUserSubscription(subData, "")
 	if err != nil {
 		log.Printf("Warning: %v", err)
@@ -102,6 +190,14 @@ func (ws *WebhookService) HandleSubscriptionUpdated(data []byte) error {
 		return fmt.Errorf("failed to parse subscription data: %w", err)
 	}
 
+	if err := ws.saveSubscription(subData); err != nil {
+		return err
+	}
+
+	if err := NewSeatService(ws.app).SyncSubscriptionSeats(subData, true); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+
 	user, err := ws.updateUserSubscription(subData, "")
 	if err != nil {
 		log.Printf("Warning: %v", err)
@@ -121,6 +217,14 @@ func (ws *WebhookService) HandleSubscriptionActive(data []byte) error {
 		return fmt.Errorf("failed to parse subscription data: %w", err)
 	}
 
+	if err := ws.saveSubscription(subData); err != nil {
+		return err
+	}
+
+	if err := NewSeatService(ws.app).SyncSubscriptionSeats(subData, true); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+
 	user, err := ws.updateUserSubscription(subData, "active")
 	if err != nil {
 		log.Printf("Warning: %v", err)
@@ -139,12 +243,11 @@ func (ws *WebhookService) HandleSubscriptionCanceled(data []byte) error {
 		return fmt.Errorf("failed to parse subscription data: %w", err)
 	}
 
-	if subData.Customer.ExternalID == nil {
-		log.Printf("Warning: subscription.canceled event has no external_id, subscription_id=%s", subData.ID)
-		return nil
+	if err := ws.saveSubscription(subData); err != nil {
+		return err
 	}
 
-	user, err := ws.findUserByExternalID(*subData.Customer.ExternalID)
+	user, err := ws.findSubscriptionUser(subData)
 	if err != nil {
 		log.Printf("Warning: %v", err)
 		return nil
@@ -171,12 +274,15 @@ func (ws *WebhookService) HandleSubscriptionRevoked(data []byte) error {
 		return fmt.Errorf("failed to parse subscription data: %w", err)
 	}
 
-	if subData.Customer.ExternalID == nil {
-		log.Printf("Warning: subscription.revoked event has no external_id, subscription_id=%s", subData.ID)
-		return nil
+	if err := ws.saveSubscription(subData); err != nil {
+		return err
+	}
+
+	if err := NewSeatService(ws.app).SyncSubscriptionSeats(subData, false); err != nil {
+		log.Printf("Warning: %v", err)
 	}
 
-	user, err := ws.findUserByExternalID(*subData.Customer.ExternalID)
+	user, err := ws.findSubscriptionUser(subData)
 	if err != nil {
 		log.Printf("Warning: %v", err)
 		return nil
@@ -245,9 +351,70 @@ func (ws *WebhookService) HandleOrderPaid(data []byte) error {
 		return fmt.Errorf("failed to update user: %w", err)
 	}
 
+	if err := ws.saveOrder(user, orderData); err != nil {
+		return err
+	}
+
 	log.Printf("Order paid for user %s: order_id=%s, amount=%d %s, billing_reason=%s",
 		user.Id, orderData.ID, orderData.TotalAmount, orderData.Currency, orderData.BillingReason)
 
+	// Download the invoice in the background, pending generations are retried by the invoice job
+	NewInvoiceService(ws.app).FetchInvoiceAsync(orderData.ID)
+
+	return nil
+}
+
+// saveOrder creates or updates the local order record for a paid Polar order
+func (ws *WebhookService) saveOrder(user *core.Record, orderData types.OrderWebhookData) error {
+	record, err := ws.app.FindRecordById(constants.CollectionOrders, orderData.ID)
+	if err != nil {
+		collection, err := ws.app.FindCollectionByNameOrId(constants.CollectionOrders)
+		if err != nil {
+			return fmt.Errorf("failed to find orders collection: %w", err)
+		}
+		record = core.NewRecord(collection)
+		record.Set("id", orderData.ID)
+		record.Set("invoice_status", InvoiceStatusPending)
+	}
+
+	record.Set("user", user.Id)
+	record.Set("status", orderData.Status)
+	record.Set("billing_reason", orderData.BillingReason)
+	record.Set("product_id", orderData.ProductID)
+	record.Set("total_amount", orderData.TotalAmount)
+	record.Set("currency", orderData.Currency)
+	record.Set("paid_at", orderData.ModifiedAt)
+	if orderData.SubscriptionID != nil {
+		record.Set("subscription_id", *orderData.SubscriptionID)
+	}
+
+	// Checkouts started from a workspace carry its ID in the metadata
+	if workspaceID, ok := orderData.Metadata["workspace_id"].(string); ok && workspaceID != "" {
+		if _, err := ws.app.FindRecordById(constants.CollectionWorkspaces, workspaceID); err == nil {
+			record.Set("workspace", workspaceID)
+		}
+	}
+
+	if err := ws.app.Save(record); err != nil {
+		return fmt.Errorf("failed to save order record: %w", err)
+	}
+
+	return nil
+}
+
+// HandleCheckoutUpdated handles checkout.created and checkout.updated events
+func (ws *WebhookService) HandleCheckoutUpdated(data []byte) error {
+	var checkoutData types.CheckoutWebhookData
+	if err := json.Unmarshal(data, &checkoutData); err != nil {
+		return fmt.Errorf("failed to parse checkout data: %w", err)
+	}
+
+	if err := NewCheckoutService(ws.app).ApplyWebhook(checkoutData); err != nil {
+		return err
+	}
+
+	log.Printf("Checkout updated: checkout_id=%s, status=%s", checkoutData.ID, checkoutData.Status)
+
 	return nil
 }
 
@@ -0,0 +1,435 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"path"
+	"pocketvue/constants"
+	"strings"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/filesystem"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// Workspace clone job statuses stored in the workspace_clone_jobs.status field
+const (
+	CloneStatusPending   = "pending"
+	CloneStatusRunning   = "running"
+	CloneStatusSucceeded = "succeeded"
+	CloneStatusFailed    = "failed"
+)
+
+const (
+	// cloneSyncNoteLimit is the largest number of notes copied before the clone request responds,
+	// clones of more notes continue as a background job
+	cloneSyncNoteLimit = 100
+
+	// cloneBatchSize is the number of notes copied per transaction, the job progress is saved after each batch
+	cloneBatchSize = 50
+
+	// cloneStaleAfter is how long a job may go without progress before it is considered interrupted,
+	// for example by a restart of the server
+	cloneStaleAfter = 10 * time.Minute
+
+	// cloneJobRetention is how long finished clone jobs are kept
+	cloneJobRetention = 30 * 24 * time.Hour
+)
+
+var (
+	// ErrCloneInProgress is returned when the user already has a clone that has not finished
+	ErrCloneInProgress = errors.New("another workspace clone is still in progress")
+
+	// ErrCloneNotes is returned when the selected notes are not all notes of the source workspace
+	ErrCloneNotes = errors.New("notes must be IDs of notes of the workspace")
+)
+
+// CloneInput describes the workspace a clone creates
+// A nil Notes copies every note, an empty list none, the logo and settings are copied unless disabled
+type CloneInput struct {
+	Name            string   `json:"name"`
+	Slug            string   `json:"slug"`
+	Notes           []string `json:"notes"`
+	IncludeLogo     *bool    `json:"include_logo"`
+	IncludeSettings *bool    `json:"include_settings"`
+}
+
+// CloneService copies workspaces and creates workspaces from the template catalog
+// The new workspace is created right away, its notes are copied in batches by a clone job
+// that records its progress in the workspace_clone_jobs collection
+type CloneService struct {
+	app core.App
+}
+
+// NewCloneService creates a new clone service instance
+func NewCloneService(app core.App) *CloneService {
+	return &CloneService{
+		app: app,
+	}
+}
+
+// Clone copies the settings, logo and selected notes of a workspace into a new workspace owned by the user
+// The domain is not copied, two workspaces never share it
+// Clones of up to cloneSyncNoteLimit notes finish before Clone returns, larger clones continue in the background
+// The workspace and notes are saved with ctx, which carries the audit actor
+func (cs *CloneService) Clone(ctx context.Context, source, owner *core.Record, input CloneInput) (*core.Record, error) {
+	if strings.TrimSpace(input.Name) == "" {
+		input.Name = source.GetString("name") + " (copy)"
+	}
+	return cs.start(ctx, source, owner, nil, input)
+}
+
+// Instantiate creates a workspace owned by the user from a template of the catalog, with all of its notes
+func (cs *CloneService) Instantiate(ctx context.Context, template, owner *core.Record, input CloneInput) (*core.Record, error) {
+	source, err := cs.app.FindRecordById(constants.CollectionWorkspaces, template.GetString("workspace"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to find workspace of template %s: %w", template.Id, err)
+	}
+
+	if strings.TrimSpace(input.Name) == "" {
+		input.Name = template.GetString("name")
+	}
+	input.Notes = nil
+	return cs.start(ctx, source, owner, template, input)
+}
+
+// ListTemplates returns the active templates of the catalog whose workspace is not deleted
+func (cs *CloneService) ListTemplates() ([]*core.Record, error) {
+	templates, err := cs.app.FindRecordsByFilter(
+		constants.CollectionWorkspaceTemplates,
+		`active = true && workspace.deleted_at = ""`,
+		"sort,name",
+		0,
+		0,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list workspace templates: %w", err)
+	}
+	return templates, nil
+}
+
+// ProcessStale resumes the jobs that made no progress for cloneStaleAfter from the notes they already copied
+func (cs *CloneService) ProcessStale() {
+	jobs, err := cs.app.FindRecordsByFilter(
+		constants.CollectionWorkspaceCloneJobs,
+		"(status = {:pending} || status = {:running}) && updated < {:before}",
+		"created",
+		50,
+		0,
+		dbx.Params{
+			"pending": CloneStatusPending,
+			"running": CloneStatusRunning,
+			"before":  types.NowDateTime().Add(-cloneStaleAfter),
+		},
+	)
+	if err != nil {
+		log.Printf("Error finding stale workspace clone jobs: %v", err)
+		return
+	}
+
+	for _, job := range jobs {
+		claimed, err := cs.claimStale(job)
+		if err != nil {
+			log.Printf("Error claiming stale workspace clone job %s: %v", job.Id, err)
+			continue
+		}
+		if !claimed {
+			continue
+		}
+
+		log.Printf("Resuming interrupted workspace clone job %s at %d of %d notes",
+			job.Id, job.GetInt("processed"), job.GetInt("total"))
+		go cs.run(context.Background(), job)
+	}
+}
+
+// claimStale marks a stale job as updated so that it is resumed only once
+// It reports false when the job progressed or was resumed since it was found
+func (cs *CloneService) claimStale(job *core.Record) (bool, error) {
+	result, err := cs.app.DB().Update(
+		constants.CollectionWorkspaceCloneJobs,
+		dbx.Params{"updated": types.NowDateTime().String()},
+		dbx.HashExp{"id": job.Id, "updated": job.GetDateTime("updated").String()},
+	).Execute()
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected == 1, nil
+}
+
+// PruneJobs deletes finished clone jobs older than cloneJobRetention
+func (cs *CloneService) PruneJobs() {
+	cutoff := types.NowDateTime().Add(-cloneJobRetention)
+
+	_, err := cs.app.DB().Delete(constants.CollectionWorkspaceCloneJobs, dbx.And(
+		dbx.NewExp("created < {:cutoff}", dbx.Params{"cutoff": cutoff}),
+		dbx.HashExp{"status": []any{CloneStatusSucceeded, CloneStatusFailed}},
+	)).Execute()
+	if err != nil {
+		log.Printf("Error pruning workspace clone jobs: %v", err)
+	}
+}
+
+// start creates the new workspace and its clone job, then copies the notes
+func (cs *CloneService) start(ctx context.Context, source, owner, template *core.Record, input CloneInput) (*core.Record, error) {
+	active, err := cs.app.CountRecords(
+		constants.CollectionWorkspaceCloneJobs,
+		dbx.HashExp{"user": owner.Id, "status": []any{CloneStatusPending, CloneStatusRunning}},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check clone jobs of user %s: %w", owner.Id, err)
+	}
+	if active > 0 {
+		return nil, ErrCloneInProgress
+	}
+
+	noteIDs, err := cs.noteIDs(source.Id, input.Notes)
+	if err != nil {
+		return nil, err
+	}
+
+	name := strings.TrimSpace(input.Name)
+	requested := input.Slug
+	if NormalizeSlug(requested) == "" {
+		requested = name
+	}
+	slug, err := NewSlugService(cs.app).Available(requested)
+	if err != nil {
+		return nil, err
+	}
+
+	var logo *filesystem.File
+	if (input.IncludeLogo == nil || *input.IncludeLogo) && source.GetString("logo") != "" {
+		if logo, err = cs.copyLogo(source); err != nil {
+			return nil, err
+		}
+	}
+
+	var settings any
+	if input.IncludeSettings == nil || *input.IncludeSettings {
+		// Settings that no longer parse are left out, the new workspace starts with the defaults
+		if settings, err = NewWorkspaceSettingsService(cs.app).Get(source); err != nil {
+			log.Printf("Warning: not copying settings of workspace %s: %v", source.Id, err)
+			settings = nil
+		}
+	}
+
+	var job *core.Record
+	err = cs.app.RunInTransaction(func(txApp core.App) error {
+		workspaces, err := txApp.FindCollectionByNameOrId(constants.CollectionWorkspaces)
+		if err != nil {
+			return err
+		}
+
+		workspace := core.NewRecord(workspaces)
+		workspace.Set("name", name)
+		workspace.Set("slug", slug)
+		workspace.Set("user", owner.Id)
+		if logo != nil {
+			workspace.Set("logo", logo)
+		}
+		if settings != nil {
+			workspace.Set("settings", settings)
+		}
+		if err := txApp.SaveWithContext(ctx, workspace); err != nil {
+			return err
+		}
+
+		jobs, err := txApp.FindCollectionByNameOrId(constants.CollectionWorkspaceCloneJobs)
+		if err != nil {
+			return err
+		}
+
+		job = core.NewRecord(jobs)
+		job.Set("user", owner.Id)
+		job.Set("source", source.Id)
+		job.Set("workspace", workspace.Id)
+		if template != nil {
+			job.Set("template", template.Id)
+		}
+		job.Set("status", CloneStatusPending)
+		job.Set("total", len(noteIDs))
+		job.Set("processed", 0)
+		job.Set("notes", noteIDs)
+		return txApp.Save(job)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("User %s started cloning workspace %s into %s (%d notes)",
+		owner.Id, source.Id, job.GetString("workspace"), len(noteIDs))
+
+	// The notes are copied after the request finished, keep the actor but not the cancelation
+	ctx = context.WithoutCancel(ctx)
+	if len(noteIDs) <= cloneSyncNoteLimit {
+		cs.run(ctx, job)
+	} else {
+		go cs.run(ctx, job)
+	}
+
+	return job, nil
+}
+
+// run copies the notes of a job in batches and records its progress
+func (cs *CloneService) run(ctx context.Context, job *core.Record) {
+	job.Set("status", CloneStatusRunning)
+	if err := cs.app.Save(job); err != nil {
+		log.Printf("Error starting workspace clone job %s: %v", job.Id, err)
+		return
+	}
+
+	var noteIDs []string
+	if err := json.Unmarshal([]byte(job.GetString("notes")), &noteIDs); err != nil {
+		cs.fail(job, fmt.Errorf("invalid note list: %w", err))
+		return
+	}
+
+	for start := job.GetInt("processed"); start < len(noteIDs); start += cloneBatchSize {
+		end := min(start+cloneBatchSize, len(noteIDs))
+		if err := cs.copyNotes(ctx, job, noteIDs[start:end], end); err != nil {
+			cs.fail(job, err)
+			return
+		}
+	}
+
+	job.Set("status", CloneStatusSucceeded)
+	job.Set("finished_at", types.NowDateTime())
+	if err := cs.app.Save(job); err != nil {
+		log.Printf("Error finishing workspace clone job %s: %v", job.Id, err)
+	}
+}
+
+// copyNotes copies a batch of notes from the source workspace of a job into its new workspace
+// and saves processed as the progress of the job in the same transaction, so a resumed job
+// continues after the last copied batch. Notes deleted from the source since the clone started are skipped
+func (cs *CloneService) copyNotes(ctx context.Context, job *core.Record, noteIDs []string, processed int) error {
+	ids := make([]any, len(noteIDs))
+	for i, id := range noteIDs {
+		ids[i] = id
+	}
+
+	var sources []*core.Record
+	if err := cs.app.RecordQuery(constants.CollectionNotes).
+		AndWhere(dbx.HashExp{"workspace": job.GetString("source"), "id": ids}).
+		OrderBy("created ASC", "id ASC").
+		All(&sources); err != nil {
+		return fmt.Errorf("failed to find notes to copy: %w", err)
+	}
+
+	return cs.app.RunInTransaction(func(txApp core.App) error {
+		notes, err := txApp.FindCollectionByNameOrId(constants.CollectionNotes)
+		if err != nil {
+			return err
+		}
+
+		for _, source := range sources {
+			note := core.NewRecord(notes)
+			note.Set("title", source.GetString("title"))
+			note.Set("content", source.GetString("content"))
+			note.Set("color", source.GetString("color"))
+			note.Set("user", job.GetString("user"))
+			note.Set("workspace", job.GetString("workspace"))
+			if err := txApp.SaveWithContext(ctx, note); err != nil {
+				return fmt.Errorf("failed to copy note %s: %w", source.Id, err)
+			}
+		}
+
+		job.Set("processed", processed)
+		if err := txApp.Save(job); err != nil {
+			return fmt.Errorf("failed to save progress: %w", err)
+		}
+		return nil
+	})
+}
+
+// fail marks a job as failed and deletes the partially copied workspace
+func (cs *CloneService) fail(job *core.Record, cause error) {
+	log.Printf("Error: workspace clone job %s failed: %v", job.Id, cause)
+
+	if workspaceID := job.GetString("workspace"); workspaceID != "" {
+		if workspace, err := cs.app.FindRecordById(constants.CollectionWorkspaces, workspaceID); err == nil {
+			if err := cs.app.Delete(workspace); err != nil {
+				log.Printf("Error deleting partial workspace %s of clone job %s: %v", workspaceID, job.Id, err)
+			}
+		}
+	}
+
+	job.Set("workspace", "")
+	job.Set("status", CloneStatusFailed)
+	job.Set("error", "Copying the notes failed, the partial workspace was removed. Please try again.")
+	job.Set("finished_at", types.NowDateTime())
+	if err := cs.app.Save(job); err != nil {
+		log.Printf("Error saving failed workspace clone job %s: %v", job.Id, err)
+	}
+}
+
+// noteIDs returns the IDs of the notes to copy, oldest first
+// A nil selection returns every note of the workspace, selected IDs must all belong to it
+func (cs *CloneService) noteIDs(workspaceID string, selected []string) ([]string, error) {
+	query := cs.app.RecordQuery(constants.CollectionNotes).
+		Select("id").
+		AndWhere(dbx.HashExp{"workspace": workspaceID}).
+		OrderBy("created ASC", "id ASC")
+
+	var ids []any
+	if selected != nil {
+		unique := make(map[string]struct{}, len(selected))
+		ids = make([]any, 0, len(selected))
+		for _, id := range selected {
+			if _, ok := unique[id]; !ok {
+				unique[id] = struct{}{}
+				ids = append(ids, id)
+			}
+		}
+		if len(ids) == 0 {
+			return []string{}, nil
+		}
+		query = query.AndWhere(dbx.HashExp{"id": ids})
+	}
+
+	noteIDs := []string{}
+	if err := query.Column(&noteIDs); err != nil {
+		return nil, fmt.Errorf("failed to find notes of workspace %s: %w", workspaceID, err)
+	}
+
+	if selected != nil && len(noteIDs) != len(ids) {
+		return nil, ErrCloneNotes
+	}
+
+	return noteIDs, nil
+}
+
+// copyLogo reads the logo of a workspace from the app storage
+func (cs *CloneService) copyLogo(workspace *core.Record) (*filesystem.File, error) {
+	fsys, err := cs.app.NewFilesystem()
+	if err != nil {
+		return nil, err
+	}
+	defer fsys.Close()
+
+	name := workspace.GetString("logo")
+	reader, err := fsys.GetReader(workspace.BaseFilesPath() + "/" + name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open logo of workspace %s: %w", workspace.Id, err)
+	}
+	defer reader.Close()
+
+	content, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read logo of workspace %s: %w", workspace.Id, err)
+	}
+
+	return filesystem.NewFileFromBytes(content, "logo"+path.Ext(name))
+}
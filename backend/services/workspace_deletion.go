@@ -0,0 +1,192 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"pocketvue/config"
+	"pocketvue/constants"
+	"pocketvue/helpers"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+var (
+	// ErrWorkspaceDeleted is returned when deleting a workspace that is already deleted
+	ErrWorkspaceDeleted = errors.New("workspace is already deleted")
+
+	// ErrWorkspaceNotDeleted is returned when restoring a workspace that is not deleted
+	ErrWorkspaceNotDeleted = errors.New("workspace is not deleted")
+)
+
+// WorkspaceDeletionService soft deletes workspaces, restores them and purges them once the retention window ends
+// A deleted workspace keeps its data but is hidden by the collection API rules
+type WorkspaceDeletionService struct {
+	app   core.App
+	polar *PolarService
+}
+
+// NewWorkspaceDeletionService creates a new workspace deletion service instance
+func NewWorkspaceDeletionService(app core.App) *WorkspaceDeletionService {
+	return &WorkspaceDeletionService{
+		app:   app,
+		polar: NewPolarService(),
+	}
+}
+
+// WorkspacePurgeAt returns when a soft deleted workspace is purged
+func WorkspacePurgeAt(workspace *core.Record) time.Time {
+	return workspace.GetDateTime("deleted_at").Time().Add(config.Current().WorkspaceRetention)
+}
+
+// SoftDelete marks a workspace deleted and stops its billing at the end of the current period
+// Pending invites and transfers of the workspace are cancelled, restoring does not bring them back
+// The workspace records whether the deletion cancelled the subscription, so Restore only resumes that one
+func (wds *WorkspaceDeletionService) SoftDelete(ctx context.Context, workspace, user *core.Record) error {
+	if helpers.IsWorkspaceDeleted(workspace) {
+		return ErrWorkspaceDeleted
+	}
+
+	// Cancel before marking the workspace deleted so a Polar failure leaves nothing half done
+	canceled := false
+	if subscriptionID := workspace.GetString("subscription_id"); subscriptionID != "" && !wds.isCanceling(subscriptionID) {
+		var err error
+		if canceled, err = wds.polar.SetSubscriptionCancelAtPeriodEnd(subscriptionID, true); err != nil {
+			return err
+		}
+	}
+
+	workspace.Set("deletion_canceled_subscription", canceled)
+	workspace.Set("deleted_at", types.NowDateTime())
+	workspace.Set("deleted_by", user.Id)
+	if err := wds.app.SaveWithContext(ctx, workspace); err != nil {
+		return fmt.Errorf("failed to delete workspace %s: %w", workspace.Id, err)
+	}
+
+	wds.cancelPendingRequests(workspace.Id)
+
+	log.Printf("User %s deleted workspace %s, it will be purged after %s",
+		user.Id, workspace.Id, WorkspacePurgeAt(workspace).Format(time.RFC3339))
+	return nil
+}
+
+// Restore undoes the soft deletion of a workspace and resumes its subscription if the deletion cancelled it
+// A subscription the customer cancelled before deleting the workspace stays cancelled
+func (wds *WorkspaceDeletionService) Restore(ctx context.Context, workspace *core.Record) error {
+	if !helpers.IsWorkspaceDeleted(workspace) {
+		return ErrWorkspaceNotDeleted
+	}
+
+	if subscriptionID := workspace.GetString("subscription_id"); subscriptionID != "" && workspace.GetBool("deletion_canceled_subscription") {
+		if _, err := wds.polar.SetSubscriptionCancelAtPeriodEnd(subscriptionID, false); err != nil {
+			return err
+		}
+	}
+
+	workspace.Set("deletion_canceled_subscription", false)
+	workspace.Set("deleted_at", "")
+	workspace.Set("deleted_by", "")
+	if err := wds.app.SaveWithContext(ctx, workspace); err != nil {
+		return fmt.Errorf("failed to restore workspace %s: %w", workspace.Id, err)
+	}
+
+	log.Printf("Restored workspace %s", workspace.Id)
+	return nil
+}
+
+// isCanceling reports whether the stored subscription is already set to end with its current period
+func (wds *WorkspaceDeletionService) isCanceling(subscriptionID string) bool {
+	subscription, err := wds.app.FindRecordById(constants.CollectionSubscriptions, subscriptionID)
+	return err == nil && subscription.GetBool("cancel_at_period_end")
+}
+
+// PurgeExpired permanently deletes the workspaces deleted longer ago than the retention window
+// Workspaces that fail to purge are retried on the next run
+func (wds *WorkspaceDeletionService) PurgeExpired() {
+	cutoff := time.Now().Add(-config.Current().WorkspaceRetention)
+	cutoffDate, _ := types.ParseDateTime(cutoff)
+
+	workspaces, err := wds.app.FindRecordsByFilter(
+		constants.CollectionWorkspaces,
+		"deleted_at != '' && deleted_at <= {:cutoff}",
+		"deleted_at",
+		100,
+		0,
+		dbx.Params{"cutoff": cutoffDate.String()},
+	)
+	if err != nil {
+		log.Printf("Error finding deleted workspaces to purge: %v", err)
+		return
+	}
+
+	for _, workspace := range workspaces {
+		if err := wds.purge(workspace); err != nil {
+			log.Printf("Error purging workspace %s: %v", workspace.Id, err)
+		}
+	}
+}
+
+// purge ends the workspace subscription and permanently deletes the workspace with its notes and files
+func (wds *WorkspaceDeletionService) purge(workspace *core.Record) error {
+	// The subscription was only scheduled to cancel, end it for good before the workspace disappears
+	if subscriptionID := workspace.GetString("subscription_id"); subscriptionID != "" {
+		if err := wds.polar.RevokeSubscription(subscriptionID); err != nil {
+			return err
+		}
+	}
+
+	err := wds.app.RunInTransaction(func(txApp core.App) error {
+		notes, err := txApp.FindAllRecords(constants.CollectionNotes, dbx.HashExp{"workspace": workspace.Id})
+		if err != nil {
+			return fmt.Errorf("failed to find notes: %w", err)
+		}
+		// Deleting records one by one also removes their files from storage
+		for _, note := range notes {
+			if err := txApp.Delete(note); err != nil {
+				return fmt.Errorf("failed to delete note %s: %w", note.Id, err)
+			}
+		}
+
+		// Members, slug history and other workspace records cascade, the logo file is removed with the record
+		return txApp.Delete(workspace)
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Purged workspace %s deleted at %s", workspace.Id, workspace.GetDateTime("deleted_at").String())
+	return nil
+}
+
+// cancelPendingRequests cancels the pending invites and ownership transfer of a workspace
+func (wds *WorkspaceDeletionService) cancelPendingRequests(workspaceID string) {
+	invites, err := wds.app.FindAllRecords(constants.CollectionWorkspaceInvites, dbx.HashExp{
+		"workspace": workspaceID,
+		"status":    InviteStatusPending,
+	})
+	if err != nil {
+		log.Printf("Error finding pending invites of workspace %s: %v", workspaceID, err)
+	}
+	inviteService := NewInviteService(wds.app)
+	for _, invite := range invites {
+		if err := inviteService.Revoke(invite); err != nil {
+			log.Printf("Error revoking invite %s: %v", invite.Id, err)
+		}
+	}
+
+	transferService := NewTransferService(wds.app)
+	transfer, err := transferService.FindPending(workspaceID)
+	if err != nil {
+		log.Printf("Error finding pending transfer of workspace %s: %v", workspaceID, err)
+		return
+	}
+	if transfer != nil {
+		if err := transferService.Cancel(transfer); err != nil {
+			log.Printf("Error cancelling transfer %s: %v", transfer.Id, err)
+		}
+	}
+}
@@ -0,0 +1,266 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"pocketvue/config"
+	"pocketvue/constants"
+	"strings"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// Workspace list scopes
+const (
+	WorkspaceScopeAll    = "all"
+	WorkspaceScopeOwned  = "owned"
+	WorkspaceScopeMember = "member"
+)
+
+// ErrInvalidWorkspaceScope is returned for an unknown workspace list scope
+var ErrInvalidWorkspaceScope = fmt.Errorf("scope must be one of %s, %s or %s", WorkspaceScopeAll, WorkspaceScopeOwned, WorkspaceScopeMember)
+
+// freePlanName is the plan name of workspaces without a subscription
+const freePlanName = "Free"
+
+// workspaceListSorts maps the accepted sort values to their ORDER BY clause
+var workspaceListSorts = map[string]string{
+	"name":     "LOWER(w.name) ASC",
+	"-name":    "LOWER(w.name) DESC",
+	"created":  "w.created ASC",
+	"-created": "w.created DESC",
+	"updated":  "w.updated ASC",
+	"-updated": "w.updated DESC",
+	"notes":    "note_count ASC",
+	"-notes":   "note_count DESC",
+}
+
+// WorkspaceListQuery selects and orders the workspaces of a user
+type WorkspaceListQuery struct {
+	// Scope is one of the WorkspaceScope* constants
+	Scope string
+	// Search matches the name or slug
+	Search string
+	// Sort is one of the keys of workspaceListSorts
+	Sort   string
+	Limit  int
+	Offset int
+}
+
+// WorkspacePlan describes the plan of a workspace
+// Seats is the seat limit of the workspace, 0 means unlimited
+type WorkspacePlan struct {
+	Name           string `json:"name"`
+	ProductID      string `json:"product_id"`
+	SubscriptionID string `json:"subscription_id"`
+	Status         string `json:"status"`
+	Seats          int    `json:"seats"`
+}
+
+// WorkspaceListItem is a workspace as seen by one of its members
+type WorkspaceListItem struct {
+	ID        string        `json:"id"`
+	Name      string        `json:"name"`
+	Slug      string        `json:"slug"`
+	Domain    string        `json:"domain"`
+	Logo      string        `json:"logo"`
+	Owner     string        `json:"owner"`
+	Role      string        `json:"role"`
+	NoteCount int           `json:"note_count"`
+	Plan      WorkspacePlan `json:"plan"`
+	Created   string        `json:"created"`
+	Updated   string        `json:"updated"`
+}
+
+// workspaceListRow is a row of the workspace list query
+type workspaceListRow struct {
+	ID             string         `db:"id"`
+	Name           string         `db:"name"`
+	Slug           string         `db:"slug"`
+	Domain         string         `db:"domain"`
+	Logo           string         `db:"logo"`
+	Owner          string         `db:"user"`
+	SubscriptionID string         `db:"subscription_id"`
+	Seats          int            `db:"seats"`
+	MemberRole     sql.NullString `db:"member_role"`
+	NoteCount      int            `db:"note_count"`
+	Created        types.DateTime `db:"created"`
+	Updated        types.DateTime `db:"updated"`
+}
+
+// WorkspaceListService lists the workspaces a user owns or is a member of
+type WorkspaceListService struct {
+	app core.App
+}
+
+// NewWorkspaceListService creates a new workspace list service instance
+func NewWorkspaceListService(app core.App) *WorkspaceListService {
+	return &WorkspaceListService{
+		app: app,
+	}
+}
+
+// ValidWorkspaceListSort reports whether sort is an accepted sort value
+func ValidWorkspaceListSort(sort string) bool {
+	_, ok := workspaceListSorts[sort]
+	return ok
+}
+
+// List returns a page of the workspaces of a user and the total number of matching workspaces
+// Deleted workspaces are left out
+func (wls *WorkspaceListService) List(userID string, query WorkspaceListQuery) ([]WorkspaceListItem, int, error) {
+	where, err := workspaceListConditions(userID, query)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var total int
+	if err := wls.baseQuery(userID).
+		Select("COUNT(*)").
+		Where(where).
+		Row(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count workspaces of user %s: %w", userID, err)
+	}
+
+	orderBy, ok := workspaceListSorts[query.Sort]
+	if !ok {
+		orderBy = workspaceListSorts["name"]
+	}
+
+	var rows []workspaceListRow
+	if err := wls.baseQuery(userID).
+		Select(
+			"w.id", "w.name", "w.slug", "w.domain", "w.logo", "w.user", "w.subscription_id", "w.seats",
+			"w.created", "w.updated", "m.role AS member_role",
+		).
+		AndSelect(fmt.Sprintf("(SELECT COUNT(*) FROM %s n WHERE n.workspace = w.id) AS note_count", constants.CollectionNotes)).
+		Where(where).
+		OrderBy(orderBy, "w.id ASC").
+		Limit(int64(query.Limit)).
+		Offset(int64(query.Offset)).
+		All(&rows); err != nil {
+		return nil, 0, fmt.Errorf("failed to list workspaces of user %s: %w", userID, err)
+	}
+
+	plans, err := wls.findPlans(rows)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	items := make([]WorkspaceListItem, 0, len(rows))
+	for _, row := range rows {
+		role := row.MemberRole.String
+		if row.Owner == userID {
+			role = constants.WorkspaceRoleOwner
+		}
+
+		items = append(items, WorkspaceListItem{
+			ID:        row.ID,
+			Name:      row.Name,
+			Slug:      row.Slug,
+			Domain:    row.Domain,
+			Logo:      row.Logo,
+			Owner:     row.Owner,
+			Role:      role,
+			NoteCount: row.NoteCount,
+			Plan:      plans[row.ID],
+			Created:   row.Created.String(),
+			Updated:   row.Updated.String(),
+		})
+	}
+
+	return items, total, nil
+}
+
+// baseQuery selects the workspaces joined with the membership of the user
+func (wls *WorkspaceListService) baseQuery(userID string) *dbx.SelectQuery {
+	return wls.app.DB().
+		Select().
+		From(constants.CollectionWorkspaces+" w").
+		LeftJoin(
+			constants.CollectionWorkspaceMembers+" m",
+			dbx.NewExp("m.workspace = w.id AND m.user = {:user}", dbx.Params{"user": userID}),
+		)
+}
+
+// workspaceListConditions builds the WHERE clause of the workspace list query
+func workspaceListConditions(userID string, query WorkspaceListQuery) (dbx.Expression, error) {
+	owned := dbx.HashExp{"w.user": userID}
+	member := dbx.And(dbx.NewExp("m.id IS NOT NULL"), dbx.Not(owned))
+
+	conditions := []dbx.Expression{dbx.HashExp{"w.deleted_at": ""}}
+
+	switch query.Scope {
+	case WorkspaceScopeAll, "":
+		conditions = append(conditions, dbx.Or(owned, dbx.NewExp("m.id IS NOT NULL")))
+	case WorkspaceScopeOwned:
+		conditions = append(conditions, owned)
+	case WorkspaceScopeMember:
+		conditions = append(conditions, member)
+	default:
+		return nil, ErrInvalidWorkspaceScope
+	}
+
+	if search := strings.TrimSpace(query.Search); search != "" {
+		conditions = append(conditions, dbx.Or(dbx.Like("w.name", search), dbx.Like("w.slug", search)))
+	}
+
+	return dbx.And(conditions...), nil
+}
+
+// findPlans returns the plan of every listed workspace, keyed by workspace ID
+// Workspaces without a subscription are on the free plan
+func (wls *WorkspaceListService) findPlans(rows []workspaceListRow) (map[string]WorkspacePlan, error) {
+	plans := make(map[string]WorkspacePlan, len(rows))
+
+	subscriptionIDs := []string{}
+	for _, row := range rows {
+		if row.SubscriptionID != "" {
+			subscriptionIDs = append(subscriptionIDs, row.SubscriptionID)
+		}
+	}
+
+	subscriptions := map[string]*core.Record{}
+	products := map[string]*core.Record{}
+	if len(subscriptionIDs) > 0 {
+		records, err := wls.app.FindRecordsByIds(constants.CollectionSubscriptions, subscriptionIDs)
+		if err != nil {
+			return nil, fmt.Errorf("failed to find workspace subscriptions: %w", err)
+		}
+
+		productIDs := []string{}
+		for _, record := range records {
+			subscriptions[record.Id] = record
+			productIDs = append(productIDs, record.GetString("product_id"))
+		}
+
+		productRecords, err := wls.app.FindRecordsByIds(constants.CollectionPolarProducts, productIDs)
+		if err != nil {
+			return nil, fmt.Errorf("failed to find workspace products: %w", err)
+		}
+		for _, product := range productRecords {
+			products[product.Id] = product
+		}
+	}
+
+	for _, row := range rows {
+		if row.SubscriptionID == "" {
+			plans[row.ID] = WorkspacePlan{Name: freePlanName, Seats: config.Current().FreeWorkspaceSeats}
+			continue
+		}
+
+		plan := WorkspacePlan{SubscriptionID: row.SubscriptionID, Seats: row.Seats}
+		if subscription, ok := subscriptions[row.SubscriptionID]; ok {
+			plan.ProductID = subscription.GetString("product_id")
+			plan.Status = subscription.GetString("status")
+			if product, ok := products[plan.ProductID]; ok {
+				plan.Name = product.GetString("name")
+			}
+		}
+		plans[row.ID] = plan
+	}
+
+	return plans, nil
+}
@@ -0,0 +1,249 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"pocketvue/types"
+	"reflect"
+	"strings"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// ErrSettingsNotObject is returned when settings or a settings patch are not a JSON object
+var ErrSettingsNotObject = errors.New("settings must be a JSON object")
+
+// workspaceSettingsMigrations upgrade stored settings documents one version at a time,
+// the migration at index i turns a version i document into a version i+1 document
+// A new settings version appends its migration here and bumps types.WorkspaceSettingsVersion
+var workspaceSettingsMigrations = []func(doc map[string]any){
+	// Version 0 documents were saved without a version and already use the version 1 layout
+	func(doc map[string]any) {},
+}
+
+// WorkspaceSettingsService reads and updates the settings of workspaces
+type WorkspaceSettingsService struct {
+	app core.App
+}
+
+// NewWorkspaceSettingsService creates a new workspace settings service instance
+func NewWorkspaceSettingsService(app core.App) *WorkspaceSettingsService {
+	return &WorkspaceSettingsService{
+		app: app,
+	}
+}
+
+// Get returns the effective settings of a workspace, with defaults for settings it never changed
+func (wss *WorkspaceSettingsService) Get(workspace *core.Record) (types.WorkspaceSettings, error) {
+	return ParseWorkspaceSettings([]byte(workspace.GetString("settings")))
+}
+
+// Update applies a JSON merge patch (RFC 7386) to the settings of a workspace and saves them
+// Settings set to null in the patch are reset to their defaults
+func (wss *WorkspaceSettingsService) Update(ctx context.Context, workspace *core.Record, patch []byte) (types.WorkspaceSettings, error) {
+	current, err := wss.Get(workspace)
+	if err != nil {
+		return types.WorkspaceSettings{}, err
+	}
+
+	settings, err := MergeWorkspaceSettings(current, patch)
+	if err != nil {
+		return types.WorkspaceSettings{}, err
+	}
+
+	workspace.Set("settings", settings)
+	if err := wss.app.SaveWithContext(ctx, workspace); err != nil {
+		return types.WorkspaceSettings{}, fmt.Errorf("failed to save workspace settings: %w", err)
+	}
+
+	return settings, nil
+}
+
+// ParseWorkspaceSettings migrates a stored settings document to the current version,
+// fills in the defaults of missing settings and validates the result
+// An empty document returns the defaults, invalid settings return validation.Errors
+func ParseWorkspaceSettings(raw []byte) (types.WorkspaceSettings, error) {
+	trimmed := bytes.TrimSpace(raw)
+	if len(trimmed) == 0 || string(trimmed) == "null" {
+		return types.DefaultWorkspaceSettings(), nil
+	}
+
+	doc, err := decodeSettingsObject(trimmed)
+	if err != nil {
+		return types.WorkspaceSettings{}, err
+	}
+
+	if err := migrateWorkspaceSettings(doc); err != nil {
+		return types.WorkspaceSettings{}, err
+	}
+
+	return decodeWorkspaceSettings(doc)
+}
+
+// MergeWorkspaceSettings applies a JSON merge patch (RFC 7386) to settings and validates the result
+func MergeWorkspaceSettings(settings types.WorkspaceSettings, patch []byte) (types.WorkspaceSettings, error) {
+	changes, err := decodeSettingsObject(bytes.TrimSpace(patch))
+	if err != nil {
+		return types.WorkspaceSettings{}, err
+	}
+
+	doc, err := settingsDocument(settings)
+	if err != nil {
+		return types.WorkspaceSettings{}, err
+	}
+
+	return decodeWorkspaceSettings(mergeSettingsPatch(doc, changes))
+}
+
+// migrateWorkspaceSettings upgrades a settings document to types.WorkspaceSettingsVersion
+func migrateWorkspaceSettings(doc map[string]any) error {
+	version := 0
+	if value, ok := doc["version"]; ok && value != nil {
+		number, ok := value.(float64)
+		if !ok || number < 0 || number != math.Trunc(number) {
+			return validation.Errors{
+				"version": validation.NewError("validation_invalid_settings_version", "must be a whole number"),
+			}
+		}
+		version = int(number)
+	}
+
+	if version > types.WorkspaceSettingsVersion {
+		return validation.Errors{
+			"version": validation.NewError(
+				"validation_unsupported_settings_version",
+				fmt.Sprintf("version %d is newer than the supported version %d", version, types.WorkspaceSettingsVersion),
+			),
+		}
+	}
+
+	for ; version < types.WorkspaceSettingsVersion; version++ {
+		workspaceSettingsMigrations[version](doc)
+	}
+	doc["version"] = types.WorkspaceSettingsVersion
+
+	return nil
+}
+
+// decodeWorkspaceSettings decodes a current version settings document onto the defaults and validates it
+func decodeWorkspaceSettings(doc map[string]any) (types.WorkspaceSettings, error) {
+	defaults, err := settingsDocument(types.DefaultWorkspaceSettings())
+	if err != nil {
+		return types.WorkspaceSettings{}, err
+	}
+	if errs := unknownSettings(doc, defaults); len(errs) > 0 {
+		return types.WorkspaceSettings{}, errs
+	}
+
+	raw, err := json.Marshal(doc)
+	if err != nil {
+		return types.WorkspaceSettings{}, fmt.Errorf("failed to encode settings: %w", err)
+	}
+
+	settings := types.DefaultWorkspaceSettings()
+	if err := json.Unmarshal(raw, &settings); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			return types.WorkspaceSettings{}, nestedSettingsError(
+				strings.Split(typeErr.Field, "."),
+				validation.NewError("validation_invalid_setting_type", "must be "+settingKind(typeErr.Type)),
+			)
+		}
+		return types.WorkspaceSettings{}, fmt.Errorf("failed to decode settings: %w", err)
+	}
+
+	if err := settings.Validate(); err != nil {
+		return types.WorkspaceSettings{}, err
+	}
+
+	return settings, nil
+}
+
+// unknownSettings reports the keys of a settings document that the schema does not declare
+func unknownSettings(doc map[string]any, known map[string]any) validation.Errors {
+	errs := validation.Errors{}
+	for key, value := range doc {
+		knownValue, ok := known[key]
+		if !ok {
+			errs[key] = validation.NewError("validation_unknown_setting", "is not a known setting")
+			continue
+		}
+
+		object, isObject := value.(map[string]any)
+		knownObject, knownIsObject := knownValue.(map[string]any)
+		if isObject && knownIsObject {
+			if nested := unknownSettings(object, knownObject); len(nested) > 0 {
+				errs[key] = nested
+			}
+		}
+	}
+	return errs
+}
+
+// mergeSettingsPatch applies the changes of a JSON merge patch to a settings document
+func mergeSettingsPatch(doc map[string]any, changes map[string]any) map[string]any {
+	for key, value := range changes {
+		if value == nil {
+			delete(doc, key)
+			continue
+		}
+
+		if object, ok := value.(map[string]any); ok {
+			target, _ := doc[key].(map[string]any)
+			if target == nil {
+				target = map[string]any{}
+			}
+			doc[key] = mergeSettingsPatch(target, object)
+			continue
+		}
+
+		doc[key] = value
+	}
+	return doc
+}
+
+// decodeSettingsObject decodes a JSON object
+func decodeSettingsObject(raw []byte) (map[string]any, error) {
+	var doc map[string]any
+	if err := json.Unmarshal(raw, &doc); err != nil || doc == nil {
+		return nil, ErrSettingsNotObject
+	}
+	return doc, nil
+}
+
+// settingsDocument converts settings to their JSON object representation
+func settingsDocument(settings types.WorkspaceSettings) (map[string]any, error) {
+	raw, err := json.Marshal(settings)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode settings: %w", err)
+	}
+	return decodeSettingsObject(raw)
+}
+
+// nestedSettingsError returns err as the error of the setting at path
+func nestedSettingsError(path []string, err error) validation.Errors {
+	if len(path) == 1 {
+		return validation.Errors{path[0]: err}
+	}
+	return validation.Errors{path[0]: nestedSettingsError(path[1:], err)}
+}
+
+// settingKind describes the JSON type a setting of type t expects
+func settingKind(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.String:
+		return "a string"
+	case reflect.Bool:
+		return "true or false"
+	case reflect.Int, reflect.Int64:
+		return "a whole number"
+	case reflect.Struct:
+		return "an object"
+	}
+	return "a " + t.Kind().String()
+}
@@ -0,0 +1,200 @@
+package services
+
+import (
+	"errors"
+	"pocketvue/types"
+	"reflect"
+	"testing"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+)
+
+func TestMergeWorkspaceSettings(t *testing.T) {
+	customized := types.DefaultWorkspaceSettings()
+	customized.Locale = "de-CH"
+	customized.Timezone = "Europe/Zurich"
+	customized.Notes.DefaultColor = "blue"
+	customized.Notifications.MemberJoined = false
+	customized.Notifications.WeeklyDigest = true
+
+	tests := []struct {
+		name     string
+		patch    string
+		want     func(settings *types.WorkspaceSettings)
+		errField string
+		notJSON  bool
+	}{
+		{
+			name:  "empty patch keeps the settings",
+			patch: `{}`,
+			want:  func(settings *types.WorkspaceSettings) {},
+		},
+		{
+			name:  "changes a setting",
+			patch: `{"timezone": "America/New_York"}`,
+			want:  func(settings *types.WorkspaceSettings) { settings.Timezone = "America/New_York" },
+		},
+		{
+			name:  "changes a nested setting and keeps its siblings",
+			patch: `{"notifications": {"weekly_digest": false}}`,
+			want:  func(settings *types.WorkspaceSettings) { settings.Notifications.WeeklyDigest = false },
+		},
+		{
+			name:  "null resets a setting to its default",
+			patch: `{"locale": null}`,
+			want:  func(settings *types.WorkspaceSettings) { settings.Locale = "en" },
+		},
+		{
+			name:  "null resets a nested setting to its default",
+			patch: `{"notifications": {"member_joined": null}}`,
+			want:  func(settings *types.WorkspaceSettings) { settings.Notifications.MemberJoined = true },
+		},
+		{
+			name:  "null resets a group of settings to their defaults",
+			patch: `{"notes": null, "notifications": null}`,
+			want: func(settings *types.WorkspaceSettings) {
+				settings.Notes = types.DefaultWorkspaceSettings().Notes
+				settings.Notifications = types.DefaultWorkspaceSettings().Notifications
+			},
+		},
+		{
+			name:  "null version stays the current version",
+			patch: `{"version": null}`,
+			want:  func(settings *types.WorkspaceSettings) {},
+		},
+		{name: "unknown setting", patch: `{"theme": "dark"}`, errField: "theme"},
+		{name: "unknown nested setting", patch: `{"notes": {"font": "serif"}}`, errField: "notes"},
+		{name: "wrong type", patch: `{"notifications": {"weekly_digest": "yes"}}`, errField: "notifications"},
+		{name: "object replaced by a value", patch: `{"notes": "blue"}`, errField: "notes"},
+		{name: "invalid value", patch: `{"timezone": "Mars/Olympus"}`, errField: "timezone"},
+		{name: "other version", patch: `{"version": 2}`, errField: "version"},
+		{name: "array", patch: `[]`, notJSON: true},
+		{name: "null patch", patch: `null`, notJSON: true},
+		{name: "malformed", patch: `{"locale":`, notJSON: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := MergeWorkspaceSettings(customized, []byte(tt.patch))
+			if tt.notJSON {
+				if !errors.Is(err, ErrSettingsNotObject) {
+					t.Fatalf("expected ErrSettingsNotObject, got %v", err)
+				}
+				return
+			}
+			if tt.errField != "" {
+				assertSettingsError(t, err, tt.errField)
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			want := customized
+			tt.want(&want)
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("got %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestMigrateWorkspaceSettings(t *testing.T) {
+	tests := []struct {
+		name     string
+		doc      map[string]any
+		errField string
+	}{
+		{name: "without a version", doc: map[string]any{"locale": "fr"}},
+		{name: "null version", doc: map[string]any{"version": nil}},
+		{name: "version 0", doc: map[string]any{"version": float64(0)}},
+		{name: "current version", doc: map[string]any{"version": float64(types.WorkspaceSettingsVersion)}},
+		{name: "newer version", doc: map[string]any{"version": float64(types.WorkspaceSettingsVersion + 1)}, errField: "version"},
+		{name: "negative version", doc: map[string]any{"version": float64(-1)}, errField: "version"},
+		{name: "fractional version", doc: map[string]any{"version": 0.5}, errField: "version"},
+		{name: "string version", doc: map[string]any{"version": "1"}, errField: "version"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := migrateWorkspaceSettings(tt.doc)
+			if tt.errField != "" {
+				assertSettingsError(t, err, tt.errField)
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tt.doc["version"] != types.WorkspaceSettingsVersion {
+				t.Fatalf("migrated document has version %v, want %d", tt.doc["version"], types.WorkspaceSettingsVersion)
+			}
+		})
+	}
+}
+
+func TestWorkspaceSettingsMigrationsCoverEveryVersion(t *testing.T) {
+	if len(workspaceSettingsMigrations) != types.WorkspaceSettingsVersion {
+		t.Fatalf("got %d settings migrations for settings version %d", len(workspaceSettingsMigrations), types.WorkspaceSettingsVersion)
+	}
+}
+
+func TestParseWorkspaceSettings(t *testing.T) {
+	defaults := types.DefaultWorkspaceSettings()
+
+	tests := []struct {
+		name     string
+		raw      string
+		want     func(settings *types.WorkspaceSettings)
+		errField string
+	}{
+		{name: "empty", raw: ``, want: func(settings *types.WorkspaceSettings) {}},
+		{name: "null", raw: `null`, want: func(settings *types.WorkspaceSettings) {}},
+		{
+			name: "version 0 document gets the defaults of missing settings",
+			raw:  `{"locale": "fr", "notes": {"default_color": "rose"}}`,
+			want: func(settings *types.WorkspaceSettings) {
+				settings.Locale = "fr"
+				settings.Notes.DefaultColor = "rose"
+			},
+		},
+		{
+			name: "current version document",
+			raw:  `{"version": 1, "notifications": {"weekly_digest": true}}`,
+			want: func(settings *types.WorkspaceSettings) { settings.Notifications.WeeklyDigest = true },
+		},
+		{name: "newer version", raw: `{"version": 99}`, errField: "version"},
+		{name: "unknown setting", raw: `{"version": 1, "theme": "dark"}`, errField: "theme"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseWorkspaceSettings([]byte(tt.raw))
+			if tt.errField != "" {
+				assertSettingsError(t, err, tt.errField)
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			want := defaults
+			tt.want(&want)
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("got %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+// assertSettingsError fails the test unless err is a validation error of the setting field
+func assertSettingsError(t *testing.T, err error, field string) {
+	t.Helper()
+
+	var errs validation.Errors
+	if !errors.As(err, &errs) {
+		t.Fatalf("expected validation errors for %q, got %v", field, err)
+	}
+	if _, ok := errs[field]; !ok {
+		t.Fatalf("expected a validation error for %q, got %v", field, errs)
+	}
+}
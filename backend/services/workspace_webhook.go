@@ -0,0 +1,596 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"pocketvue/config"
+	"pocketvue/constants"
+	"pocketvue/helpers"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/types"
+	svix "github.com/standard-webhooks/standard-webhooks/libraries/go"
+)
+
+// Workspace webhook events stored in the workspace_webhooks.events field
+const (
+	WebhookEventNoteCreated       = "note.created"
+	WebhookEventNoteUpdated       = "note.updated"
+	WebhookEventNoteDeleted       = "note.deleted"
+	WebhookEventMemberAdded       = "member.added"
+	WebhookEventMemberUpdated     = "member.updated"
+	WebhookEventMemberRemoved     = "member.removed"
+	WebhookEventWorkspaceUpdated  = "workspace.updated"
+	WebhookEventWorkspaceDeleted  = "workspace.deleted"
+	WebhookEventWorkspaceRestored = "workspace.restored"
+
+	// WebhookEventTest is only sent by test deliveries, endpoints cannot subscribe to it
+	WebhookEventTest = "webhook.test"
+)
+
+// WebhookEvents lists the events a webhook can subscribe to
+var WebhookEvents = []string{
+	WebhookEventNoteCreated,
+	WebhookEventNoteUpdated,
+	WebhookEventNoteDeleted,
+	WebhookEventMemberAdded,
+	WebhookEventMemberUpdated,
+	WebhookEventMemberRemoved,
+	WebhookEventWorkspaceUpdated,
+	WebhookEventWorkspaceDeleted,
+	WebhookEventWorkspaceRestored,
+}
+
+// Webhook delivery statuses stored in the webhook_deliveries.status field
+const (
+	DeliveryStatusPending   = "pending"
+	DeliveryStatusSucceeded = "succeeded"
+	DeliveryStatusFailed    = "failed"
+)
+
+const (
+	// webhookSecretPrefix starts every signing secret, as expected by Standard Webhooks libraries
+	webhookSecretPrefix = "whsec_"
+
+	// webhookSecretBytes is the number of random bytes of a signing secret
+	webhookSecretBytes = 32
+
+	// webhookTimeout limits how long a single delivery attempt may take
+	webhookTimeout = 10 * time.Second
+
+	// webhookResponseBodyLimit is the number of response body characters kept in the delivery log
+	webhookResponseBodyLimit = 1000
+
+	// webhookDeliveryLease is how long a claimed delivery is hidden from other runs,
+	// a delivery interrupted by a restart is retried once it passes
+	webhookDeliveryLease = 5 * time.Minute
+
+	// webhookDeliveryRetention is how long deliveries are kept in the delivery log
+	webhookDeliveryRetention = 30 * 24 * time.Hour
+
+	// webhookUserAgent is sent with every delivery
+	webhookUserAgent = "PocketVue-Webhooks/1.0"
+
+	// maxWorkspaceWebhooks is the number of webhooks a workspace can have
+	maxWorkspaceWebhooks = 10
+)
+
+// webhookRetrySchedule is the delay before each retry of a failed delivery
+// A delivery is attempted once more than there are entries before it is marked as failed
+var webhookRetrySchedule = []time.Duration{
+	time.Minute,
+	5 * time.Minute,
+	30 * time.Minute,
+	2 * time.Hour,
+	5 * time.Hour,
+	10 * time.Hour,
+	10 * time.Hour,
+}
+
+var (
+	// ErrWebhookURL is returned when a webhook is saved without a valid http or https URL
+	ErrWebhookURL = errors.New("url must be an absolute http or https URL")
+
+	// ErrWebhookEvents is returned when a webhook is saved without events or with unknown events
+	ErrWebhookEvents = fmt.Errorf("events must be one or more of %s", strings.Join(WebhookEvents, ", "))
+
+	// ErrWebhookLimit is returned when a workspace already has the maximum number of webhooks
+	ErrWebhookLimit = fmt.Errorf("a workspace can have at most %d webhooks", maxWorkspaceWebhooks)
+
+	// ErrWebhookAddress is returned when a delivery would connect to a local or private address in production
+	ErrWebhookAddress = errors.New("webhook URL resolves to a local or private address")
+)
+
+// WebhookInput contains the fields of a webhook that can be created or changed
+// Nil fields are left unchanged on update
+type WebhookInput struct {
+	URL          *string   `json:"url"`
+	Description  *string   `json:"description"`
+	Events       *[]string `json:"events"`
+	Enabled      *bool     `json:"enabled"`
+	RotateSecret bool      `json:"rotate_secret"`
+}
+
+// WebhookPayload is the body of a delivery, following the Standard Webhooks payload structure
+type WebhookPayload struct {
+	Type      string `json:"type"`
+	Timestamp string `json:"timestamp"`
+	Data      any    `json:"data"`
+}
+
+// WorkspaceWebhookService manages outgoing webhooks of workspaces and delivers their events
+// Deliveries are stored in the webhook_deliveries collection, signed with the secret of their webhook
+// and retried with backoff until they succeed or run out of attempts
+type WorkspaceWebhookService struct {
+	app    core.App
+	client *http.Client
+}
+
+// NewWorkspaceWebhookService creates a new workspace webhook service instance
+func NewWorkspaceWebhookService(app core.App) *WorkspaceWebhookService {
+	return &WorkspaceWebhookService{
+		app:    app,
+		client: newWebhookClient(),
+	}
+}
+
+// List returns the webhooks of a workspace, oldest first
+func (wws *WorkspaceWebhookService) List(workspaceID string) ([]*core.Record, error) {
+	return wws.app.FindRecordsByFilter(
+		constants.CollectionWorkspaceWebhooks,
+		"workspace = {:workspace}",
+		"created",
+		0,
+		0,
+		dbx.Params{"workspace": workspaceID},
+	)
+}
+
+// Create adds a webhook to a workspace and returns it with its signing secret
+// New webhooks are enabled unless input.Enabled is false
+func (wws *WorkspaceWebhookService) Create(workspace, user *core.Record, input WebhookInput) (*core.Record, string, error) {
+	if input.URL == nil {
+		return nil, "", ErrWebhookURL
+	}
+	if input.Events == nil {
+		return nil, "", ErrWebhookEvents
+	}
+
+	count, err := wws.app.CountRecords(constants.CollectionWorkspaceWebhooks, dbx.HashExp{"workspace": workspace.Id})
+	if err != nil {
+		return nil, "", err
+	}
+	if count >= maxWorkspaceWebhooks {
+		return nil, "", ErrWebhookLimit
+	}
+
+	collection, err := wws.app.FindCollectionByNameOrId(constants.CollectionWorkspaceWebhooks)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to find workspace_webhooks collection: %w", err)
+	}
+
+	webhook := core.NewRecord(collection)
+	webhook.Set("workspace", workspace.Id)
+	webhook.Set("created_by", user.Id)
+	webhook.Set("enabled", true)
+	input.RotateSecret = true
+
+	secret, err := wws.apply(webhook, input)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if err := wws.app.Save(webhook); err != nil {
+		return nil, "", fmt.Errorf("failed to save webhook of workspace %s: %w", workspace.Id, err)
+	}
+
+	log.Printf("User %s created webhook %s for workspace %s", user.Id, webhook.Id, workspace.Id)
+	return webhook, secret, nil
+}
+
+// Update changes a webhook and returns its new signing secret if input.RotateSecret is set
+func (wws *WorkspaceWebhookService) Update(webhook *core.Record, input WebhookInput) (string, error) {
+	secret, err := wws.apply(webhook, input)
+	if err != nil {
+		return "", err
+	}
+
+	if err := wws.app.Save(webhook); err != nil {
+		return "", fmt.Errorf("failed to save webhook %s: %w", webhook.Id, err)
+	}
+
+	return secret, nil
+}
+
+// Delete removes a webhook together with its delivery log
+func (wws *WorkspaceWebhookService) Delete(webhook *core.Record) error {
+	if err := wws.app.Delete(webhook); err != nil {
+		return fmt.Errorf("failed to delete webhook %s: %w", webhook.Id, err)
+	}
+	return nil
+}
+
+// Deliveries returns a page of the delivery log of a webhook, newest first, and the total number of deliveries
+func (wws *WorkspaceWebhookService) Deliveries(webhookID string, limit, offset int) ([]*core.Record, int64, error) {
+	total, err := wws.app.CountRecords(constants.CollectionWebhookDeliveries, dbx.HashExp{"webhook": webhookID})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	deliveries, err := wws.app.FindRecordsByFilter(
+		constants.CollectionWebhookDeliveries,
+		"webhook = {:webhook}",
+		"-created",
+		limit,
+		offset,
+		dbx.Params{"webhook": webhookID},
+	)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return deliveries, total, nil
+}
+
+// Dispatch queues an event for every enabled webhook of a workspace subscribed to it and delivers it in the background
+// Events of deleted workspaces are dropped, except for the deletion itself
+func (wws *WorkspaceWebhookService) Dispatch(workspaceID, event string, data any) error {
+	workspace, err := wws.app.FindRecordById(constants.CollectionWorkspaces, workspaceID)
+	if err != nil || (helpers.IsWorkspaceDeleted(workspace) && event != WebhookEventWorkspaceDeleted) {
+		return nil
+	}
+
+	webhooks, err := wws.app.FindRecordsByFilter(
+		constants.CollectionWorkspaceWebhooks,
+		"workspace = {:workspace} && enabled = true",
+		"created",
+		0,
+		0,
+		dbx.Params{"workspace": workspaceID},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to find webhooks of workspace %s: %w", workspaceID, err)
+	}
+
+	for _, webhook := range webhooks {
+		if !slices.Contains(webhook.GetStringSlice("events"), event) {
+			continue
+		}
+
+		delivery, err := wws.enqueue(webhook, event, data)
+		if err != nil {
+			return err
+		}
+		go wws.deliver(webhook, delivery, true)
+	}
+
+	return nil
+}
+
+// SendTest delivers a webhook.test event to a webhook right away and returns the delivery
+// Test deliveries are not retried
+func (wws *WorkspaceWebhookService) SendTest(webhook *core.Record) (*core.Record, error) {
+	delivery, err := wws.enqueue(webhook, WebhookEventTest, map[string]string{
+		"workspace": webhook.GetString("workspace"),
+		"webhook":   webhook.Id,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	wws.deliver(webhook, delivery, false)
+	return delivery, nil
+}
+
+// ProcessPending retries every pending delivery whose retry time has passed
+func (wws *WorkspaceWebhookService) ProcessPending() {
+	deliveries, err := wws.app.FindRecordsByFilter(
+		constants.CollectionWebhookDeliveries,
+		"status = {:status} && next_attempt <= {:now}",
+		"next_attempt",
+		100,
+		0,
+		dbx.Params{"status": DeliveryStatusPending, "now": types.NowDateTime()},
+	)
+	if err != nil {
+		log.Printf("Error finding pending webhook deliveries: %v", err)
+		return
+	}
+
+	for _, delivery := range deliveries {
+		webhook, err := wws.app.FindRecordById(constants.CollectionWorkspaceWebhooks, delivery.GetString("webhook"))
+		if err != nil {
+			log.Printf("Error finding webhook of delivery %s: %v", delivery.Id, err)
+			continue
+		}
+
+		// Retries stop when their webhook is disabled
+		if !webhook.GetBool("enabled") {
+			delivery.Set("status", DeliveryStatusFailed)
+			delivery.Set("error", "webhook was disabled")
+			delivery.Set("next_attempt", nil)
+			if err := wws.app.Save(delivery); err != nil {
+				log.Printf("Error saving webhook delivery %s: %v", delivery.Id, err)
+			}
+			continue
+		}
+
+		wws.deliver(webhook, delivery, true)
+	}
+}
+
+// PruneDeliveries deletes deliveries older than the delivery log retention
+func (wws *WorkspaceWebhookService) PruneDeliveries() {
+	cutoff := types.NowDateTime().Add(-webhookDeliveryRetention)
+
+	_, err := wws.app.DB().Delete(constants.CollectionWebhookDeliveries, dbx.And(
+		dbx.NewExp("created < {:cutoff}", dbx.Params{"cutoff": cutoff}),
+		dbx.HashExp{"status": []any{DeliveryStatusSucceeded, DeliveryStatusFailed}},
+	)).Execute()
+	if err != nil {
+		log.Printf("Error pruning webhook deliveries: %v", err)
+	}
+}
+
+// apply validates input and sets it on a webhook
+// It returns the new signing secret if input.RotateSecret is set
+func (wws *WorkspaceWebhookService) apply(webhook *core.Record, input WebhookInput) (string, error) {
+	if input.URL != nil {
+		target := strings.TrimSpace(*input.URL)
+		if err := validateWebhookURL(target); err != nil {
+			return "", err
+		}
+		webhook.Set("url", target)
+	}
+
+	if input.Events != nil {
+		if err := validateWebhookEvents(*input.Events); err != nil {
+			return "", err
+		}
+		webhook.Set("events", *input.Events)
+	}
+
+	if input.Description != nil {
+		webhook.Set("description", strings.TrimSpace(*input.Description))
+	}
+
+	if input.Enabled != nil {
+		webhook.Set("enabled", *input.Enabled)
+	}
+
+	var secret string
+	if input.RotateSecret {
+		var err error
+		secret, err = newWebhookSecret()
+		if err != nil {
+			return "", err
+		}
+		webhook.Set("secret", secret)
+	}
+
+	return secret, nil
+}
+
+// enqueue stores a pending delivery of an event
+// It is due right away, so a delivery interrupted by a restart is picked up by ProcessPending
+func (wws *WorkspaceWebhookService) enqueue(webhook *core.Record, event string, data any) (*core.Record, error) {
+	collection, err := wws.app.FindCollectionByNameOrId(constants.CollectionWebhookDeliveries)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find webhook_deliveries collection: %w", err)
+	}
+
+	delivery := core.NewRecord(collection)
+	delivery.Set("webhook", webhook.Id)
+	delivery.Set("workspace", webhook.GetString("workspace"))
+	delivery.Set("event", event)
+	delivery.Set("payload", WebhookPayload{
+		Type:      event,
+		Timestamp: time.Now().UTC().Format(time.RFC3339),
+		Data:      data,
+	})
+	delivery.Set("status", DeliveryStatusPending)
+	delivery.Set("next_attempt", types.NowDateTime())
+
+	if err := wws.app.Save(delivery); err != nil {
+		return nil, fmt.Errorf("failed to queue %s delivery for webhook %s: %w", event, webhook.Id, err)
+	}
+
+	return delivery, nil
+}
+
+// claim takes a due pending delivery for this attempt by pushing its next attempt past webhookDeliveryLease
+// A new delivery and overlapping ProcessPending runs may pick up the same delivery, only one attempt sends it
+func (wws *WorkspaceWebhookService) claim(delivery *core.Record) (bool, error) {
+	now := types.NowDateTime()
+	result, err := wws.app.DB().Update(
+		constants.CollectionWebhookDeliveries,
+		dbx.Params{"next_attempt": now.Add(webhookDeliveryLease)},
+		dbx.And(
+			dbx.HashExp{"id": delivery.Id, "status": DeliveryStatusPending},
+			dbx.NewExp("next_attempt <= {:now}", dbx.Params{"now": now}),
+		),
+	).Execute()
+	if err != nil {
+		return false, err
+	}
+
+	claimed, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return claimed == 1, nil
+}
+
+// deliver makes one attempt of a delivery and records its outcome, unless another attempt claimed it
+// Failed attempts are scheduled for a retry if retry is set and attempts are left
+func (wws *WorkspaceWebhookService) deliver(webhook, delivery *core.Record, retry bool) {
+	claimed, err := wws.claim(delivery)
+	if err != nil {
+		log.Printf("Error claiming webhook delivery %s: %v", delivery.Id, err)
+		return
+	}
+	if !claimed {
+		return
+	}
+
+	started := time.Now()
+	status, body, err := wws.send(webhook, delivery)
+
+	attempts := delivery.GetInt("attempts") + 1
+	delivery.Set("attempts", attempts)
+	delivery.Set("response_status", status)
+	delivery.Set("response_body", body)
+	delivery.Set("duration_ms", time.Since(started).Milliseconds())
+
+	if err == nil && status >= 200 && status < 300 {
+		delivery.Set("status", DeliveryStatusSucceeded)
+		delivery.Set("error", "")
+		delivery.Set("next_attempt", nil)
+		delivery.Set("delivered_at", types.NowDateTime())
+	} else {
+		if err == nil {
+			err = fmt.Errorf("endpoint responded with status %d", status)
+		}
+		delivery.Set("error", err.Error())
+
+		if retry && attempts <= len(webhookRetrySchedule) {
+			delivery.Set("next_attempt", time.Now().Add(webhookRetrySchedule[attempts-1]))
+		} else {
+			delivery.Set("status", DeliveryStatusFailed)
+			delivery.Set("next_attempt", nil)
+		}
+	}
+
+	if saveErr := wws.app.Save(delivery); saveErr != nil {
+		log.Printf("Error saving webhook delivery %s: %v", delivery.Id, saveErr)
+	}
+}
+
+// send posts the payload of a delivery to its webhook, signed with the webhook secret
+// The message ID stays the same across retries so receivers can deduplicate deliveries
+func (wws *WorkspaceWebhookService) send(webhook, delivery *core.Record) (int, string, error) {
+	signer, err := svix.NewWebhook(webhook.GetString("secret"))
+	if err != nil {
+		return 0, "", fmt.Errorf("invalid webhook secret: %w", err)
+	}
+
+	payload := []byte(delivery.GetString("payload"))
+	messageID := "msg_" + delivery.Id
+	timestamp := time.Now()
+
+	signature, err := signer.Sign(messageID, timestamp, payload)
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to sign payload: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), webhookTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.GetString("url"), strings.NewReader(string(payload)))
+	if err != nil {
+		return 0, "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", webhookUserAgent)
+	req.Header.Set("Webhook-Id", messageID)
+	req.Header.Set("Webhook-Timestamp", fmt.Sprintf("%d", timestamp.Unix()))
+	req.Header.Set("Webhook-Signature", signature)
+
+	resp, err := wws.client.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, webhookResponseBodyLimit*4))
+	if err != nil {
+		return resp.StatusCode, "", fmt.Errorf("failed to read response: %w", err)
+	}
+
+	return resp.StatusCode, truncateAuditText(strings.ToValidUTF8(string(body), ""), webhookResponseBodyLimit), nil
+}
+
+// newWebhookClient returns the HTTP client used for deliveries
+// It does not follow redirects and, in production, refuses to connect to local and private addresses
+func newWebhookClient() *http.Client {
+	dialer := &net.Dialer{
+		Timeout: webhookTimeout,
+		Control: webhookDialControl,
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &http.Client{
+		Timeout:   webhookTimeout,
+		Transport: transport,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// webhookDialControl checks the resolved address of every delivery connection
+// so endpoints cannot reach internal services, also not through DNS names
+func webhookDialControl(network, address string, _ syscall.RawConn) error {
+	if !config.IsProduction() {
+		return nil
+	}
+
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsMulticast() {
+		return ErrWebhookAddress
+	}
+	return nil
+}
+
+// validateWebhookURL checks that target is an absolute http or https URL
+func validateWebhookURL(target string) error {
+	parsed, err := url.Parse(target)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return ErrWebhookURL
+	}
+	return nil
+}
+
+// validateWebhookEvents checks that events is a non-empty list of known events
+func validateWebhookEvents(events []string) error {
+	if len(events) == 0 {
+		return ErrWebhookEvents
+	}
+	for _, event := range events {
+		if !slices.Contains(WebhookEvents, event) {
+			return ErrWebhookEvents
+		}
+	}
+	return nil
+}
+
+// newWebhookSecret returns a random signing secret in the whsec_<base64> format of Standard Webhooks
+func newWebhookSecret() (string, error) {
+	secret := make([]byte, webhookSecretBytes)
+	if _, err := rand.Read(secret); err != nil {
+		return "", fmt.Errorf("failed to generate webhook secret: %w", err)
+	}
+	return webhookSecretPrefix + base64.StdEncoding.EncodeToString(secret), nil
+}
@@ -0,0 +1,45 @@
+package types
+
+import "time"
+
+// BillingMetrics represents the billing metrics returned by the admin metrics endpoint
+// Amounts are in the smallest currency unit (e.g. cents) and grouped by currency
+type BillingMetrics struct {
+	From                 time.Time                  `json:"from"`
+	To                   time.Time                  `json:"to"`
+	Interval             string                     `json:"interval"`
+	MRR                  map[string]int             `json:"mrr"`
+	ActiveSubscriptions  int                        `json:"active_subscriptions"`
+	ActiveByProduct      []ProductSubscriptionCount `json:"active_by_product"`
+	NewSubscriptions     int                        `json:"new_subscriptions"`
+	ChurnedSubscriptions int                        `json:"churned_subscriptions"`
+	TrialsStarted        int                        `json:"trials_started"`
+	TrialsEnded          int                        `json:"trials_ended"`
+	TrialsConverted      int                        `json:"trials_converted"`
+	TrialConversionRate  float64                    `json:"trial_conversion_rate"`
+	FailedPayments       int                        `json:"failed_payments"`
+	Revenue              map[string]int             `json:"revenue"`
+	Periods              []BillingMetricsPeriod     `json:"periods"`
+}
+
+// ProductSubscriptionCount represents the number of active subscriptions of a product
+type ProductSubscriptionCount struct {
+	ProductID string `json:"product_id"`
+	Name      string `json:"name"`
+	Count     int    `json:"count"`
+}
+
+// BillingMetricsPeriod represents the billing metrics of a single period within the requested range
+// MRR and ActiveSubscriptions are measured at the end of the period
+type BillingMetricsPeriod struct {
+	Start                time.Time      `json:"start"`
+	End                  time.Time      `json:"end"`
+	MRR                  map[string]int `json:"mrr"`
+	ActiveSubscriptions  int            `json:"active_subscriptions"`
+	NewSubscriptions     int            `json:"new_subscriptions"`
+	ChurnedSubscriptions int            `json:"churned_subscriptions"`
+	TrialsStarted        int            `json:"trials_started"`
+	TrialsConverted      int            `json:"trials_converted"`
+	FailedPayments       int            `json:"failed_payments"`
+	Revenue              map[string]int `json:"revenue"`
+}
@@ -14,27 +14,31 @@ type WebhookEventMap map[string]interface{}
 
 // SubscriptionWebhookData represents subscription event data
 type SubscriptionWebhookData struct {
-	ID                 string                 `json:"id"`
-	CreatedAt          time.Time              `json:"created_at"`
-	ModifiedAt         time.Time              `json:"modified_at"`
-	Amount             int                    `json:"amount"`
-	Currency           string                 `json:"currency"`
-	RecurringInterval  string                 `json:"recurring_interval"`
-	Status             string                 `json:"status"`
-	CurrentPeriodStart time.Time              `json:"current_period_start"`
-	CurrentPeriodEnd   time.Time              `json:"current_period_end"`
-	CancelAtPeriodEnd  bool                   `json:"cancel_at_period_end"`
-	CanceledAt         *time.Time             `json:"canceled_at"`
-	StartedAt          *time.Time             `json:"started_at"`
-	EndsAt             *time.Time             `json:"ends_at"`
-	EndedAt            *time.Time             `json:"ended_at"`
-	CustomerID         string                 `json:"customer_id"`
-	ProductID          string                 `json:"product_id"`
-	DiscountID         *string                `json:"discount_id"`
-	CheckoutID         *string                `json:"checkout_id"`
-	Metadata           map[string]interface{} `json:"metadata"`
-	Customer           CustomerData           `json:"customer"`
-	Product            ProductData            `json:"product"`
+	ID                     string                 `json:"id"`
+	CreatedAt              time.Time              `json:"created_at"`
+	ModifiedAt             time.Time              `json:"modified_at"`
+	Amount                 int                    `json:"amount"`
+	Currency               string                 `json:"currency"`
+	RecurringInterval      string                 `json:"recurring_interval"`
+	RecurringIntervalCount int                    `json:"recurring_interval_count"`
+	Status                 string                 `json:"status"`
+	Seats                  *int                   `json:"seats"`
+	CurrentPeriodStart     time.Time              `json:"current_period_start"`
+	CurrentPeriodEnd       time.Time              `json:"current_period_end"`
+	TrialStart             *time.Time             `json:"trial_start"`
+	TrialEnd               *time.Time             `json:"trial_end"`
+	CancelAtPeriodEnd      bool                   `json:"cancel_at_period_end"`
+	CanceledAt             *time.Time             `json:"canceled_at"`
+	StartedAt              *time.Time             `json:"started_at"`
+	EndsAt                 *time.Time             `json:"ends_at"`
+	EndedAt                *time.Time             `json:"ended_at"`
+	CustomerID             string                 `json:"customer_id"`
+	ProductID              string                 `json:"product_id"`
+	DiscountID             *string                `json:"discount_id"`
+	CheckoutID             *string                `json:"checkout_id"`
+	Metadata               map[string]interface{} `json:"metadata"`
+	Customer               CustomerData           `json:"customer"`
+	Product                ProductData            `json:"product"`
 }
 
 // OrderWebhookData represents order event data
@@ -114,3 +118,16 @@ type ProductPrice struct {
 	PriceAmount       int       `json:"price_amount"`
 	Legacy            bool      `json:"legacy"`
 }
+
+// CheckoutWebhookData represents checkout event data (checkout.created, checkout.updated)
+type CheckoutWebhookData struct {
+	ID                 string                 `json:"id"`
+	CreatedAt          time.Time              `json:"created_at"`
+	ModifiedAt         time.Time              `json:"modified_at"`
+	Status             string                 `json:"status"`
+	ExpiresAt          time.Time              `json:"expires_at"`
+	ProductID          string                 `json:"product_id"`
+	CustomerID         *string                `json:"customer_id"`
+	ExternalCustomerID *string                `json:"external_customer_id"`
+	Metadata           map[string]interface{} `json:"metadata"`
+}
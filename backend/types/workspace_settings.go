@@ -0,0 +1,101 @@
+package types
+
+import (
+	"regexp"
+	"slices"
+	"time"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+
+	// Embeds the time zone database so timezones validate on hosts without zoneinfo
+	_ "time/tzdata"
+)
+
+// WorkspaceSettingsVersion is the version of the current workspace settings schema
+const WorkspaceSettingsVersion = 1
+
+// NoteColors lists the colors a note can default to
+var NoteColors = []string{
+	"neutral", "red", "orange", "amber", "yellow", "lime", "green", "emerald", "teal",
+	"cyan", "sky", "blue", "indigo", "violet", "purple", "fuchsia", "pink", "rose",
+}
+
+// localePattern matches BCP 47 language tags such as en, de-CH or zh-Hant-TW
+var localePattern = regexp.MustCompile(`^[a-z]{2,3}(-[A-Z][a-z]{3})?(-([A-Z]{2}|[0-9]{3}))?$`)
+
+// WorkspaceSettings is the settings document stored in the workspaces settings JSON field
+type WorkspaceSettings struct {
+	Version       int                           `json:"version"`
+	Locale        string                        `json:"locale"`
+	Timezone      string                        `json:"timezone"`
+	Notes         WorkspaceNoteSettings         `json:"notes"`
+	Notifications WorkspaceNotificationSettings `json:"notifications"`
+}
+
+// WorkspaceNoteSettings contains the note preferences of a workspace
+type WorkspaceNoteSettings struct {
+	DefaultColor string `json:"default_color"`
+}
+
+// WorkspaceNotificationSettings contains the email notification preferences of a workspace
+type WorkspaceNotificationSettings struct {
+	MemberJoined bool `json:"member_joined"`
+	WeeklyDigest bool `json:"weekly_digest"`
+}
+
+// DefaultWorkspaceSettings returns the settings of a workspace that never changed them
+func DefaultWorkspaceSettings() WorkspaceSettings {
+	return WorkspaceSettings{
+		Version:  WorkspaceSettingsVersion,
+		Locale:   "en",
+		Timezone: "UTC",
+		Notes: WorkspaceNoteSettings{
+			DefaultColor: "neutral",
+		},
+		Notifications: WorkspaceNotificationSettings{
+			MemberJoined: true,
+			WeeklyDigest: false,
+		},
+	}
+}
+
+// Validate checks the settings against the current schema
+func (s WorkspaceSettings) Validate() error {
+	return validation.ValidateStruct(&s,
+		validation.Field(&s.Version, validation.Required, validation.In(WorkspaceSettingsVersion).Error("must be the current settings version")),
+		validation.Field(&s.Locale, validation.Required, validation.Match(localePattern).Error("must be a language tag such as en or de-CH")),
+		validation.Field(&s.Timezone, validation.Required, validation.By(validateTimezone)),
+		validation.Field(&s.Notes),
+		validation.Field(&s.Notifications),
+	)
+}
+
+// Validate checks the note preferences
+func (s WorkspaceNoteSettings) Validate() error {
+	return validation.ValidateStruct(&s,
+		validation.Field(&s.DefaultColor, validation.Required, validation.By(validateNoteColor)),
+	)
+}
+
+// Validate checks the notification preferences, every combination is valid
+func (s WorkspaceNotificationSettings) Validate() error {
+	return nil
+}
+
+// validateTimezone checks that value is an IANA time zone name such as Europe/Zurich
+func validateTimezone(value any) error {
+	timezone, _ := value.(string)
+	if _, err := time.LoadLocation(timezone); err != nil || timezone == "Local" {
+		return validation.NewError("validation_invalid_timezone", "must be an IANA time zone such as Europe/Zurich")
+	}
+	return nil
+}
+
+// validateNoteColor checks that value is one of NoteColors
+func validateNoteColor(value any) error {
+	color, _ := value.(string)
+	if !slices.Contains(NoteColors, color) {
+		return validation.NewError("validation_invalid_note_color", "must be one of the note colors")
+	}
+	return nil
+}
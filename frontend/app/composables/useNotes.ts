@@ -6,11 +6,13 @@ export const useNotes = () => {
   const notes = useState<NotesRecord[]>('notes', () => [])
   let unsubscribeFn: (() => void) | null = null
 
-  const subscribeNotes = async () => {
+  // Notes are shared with every member of their workspace, only keep the ones of the given workspace
+  const subscribeNotes = async (workspaceId?: string) => {
     unsubscribeFn = await pb.collection('notes').subscribe('*', e => {
       console.log('Realtime event:', e.action, e.record.id)
 
       if (!notes.value) return
+      if (workspaceId && e.record.workspace !== workspaceId) return
 
       switch (e.action) {
         case 'create':
@@ -45,9 +47,10 @@ export const useNotes = () => {
     }
   }
 
-  const fetchNotes = async () => {
+  const fetchNotes = async (workspaceId?: string) => {
     return await pb.collection('notes').getFullList<NotesRecord>({
-      sort: '-created'
+      sort: '-created',
+      filter: workspaceId ? pb.filter('workspace = {:workspaceId}', { workspaceId }) : ''
     })
   }
 
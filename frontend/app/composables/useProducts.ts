@@ -11,6 +11,8 @@ export interface PolarProduct {
   trial_interval_count?: number
   polar_price_id: string
   features: readonly PolarProductFeature[]
+  sort_order?: number
+  is_highlighted?: boolean
 }
 
 export interface PolarProductFeature {
@@ -0,0 +1,91 @@
+export interface CloneJob {
+  id: string
+  status: 'pending' | 'running' | 'succeeded' | 'failed'
+  total: number
+  processed: number
+  error: string
+  workspace_id: string
+  workspace_slug: string
+  source_id: string
+  template_id: string
+  created: string
+  finished_at: string
+}
+
+export interface CloneOptions {
+  name?: string
+  slug?: string
+  notes?: string[] | null
+  include_logo?: boolean
+  include_settings?: boolean
+}
+
+export const useWorkspaceClone = () => {
+  const { $api } = useNuxtApp()
+  const { fetchWorkspaces } = useWorkspaces()
+  const { setLastUsedWorkspace } = useWorkspacePreferences()
+
+  const job = ref<CloneJob | null>(null)
+
+  const progress = computed(() => {
+    if (!job.value?.total) return 0
+    return Math.round((job.value.processed / job.value.total) * 100)
+  })
+
+  // Large clones continue in the background, poll their progress until they finish
+  const waitForJob = async (started: CloneJob) => {
+    job.value = started
+    while (job.value.status === 'pending' || job.value.status === 'running') {
+      await new Promise(resolve => setTimeout(resolve, 1000))
+      job.value = await $api<CloneJob>(`api/workspace-clones/${started.id}`)
+    }
+    if (job.value.status === 'failed') {
+      throw new Error(job.value.error || 'The clone failed')
+    }
+    return job.value
+  }
+
+  const openWorkspace = async (finished: CloneJob) => {
+    await fetchWorkspaces()
+    setLastUsedWorkspace(finished.workspace_slug)
+    await navigateTo(`/${finished.workspace_slug}/dashboard`)
+  }
+
+  const cloneWorkspace = async (workspaceId: string, options: CloneOptions) => {
+    try {
+      const started = await $api<CloneJob>(
+        `api/workspaces/${workspaceId}/clone`,
+        { method: 'POST', body: options }
+      )
+      const finished = await waitForJob(started)
+      await openWorkspace(finished)
+      return finished
+    } finally {
+      job.value = null
+    }
+  }
+
+  const createFromTemplate = async (
+    templateId: string,
+    options: CloneOptions
+  ) => {
+    try {
+      const started = await $api<CloneJob>(
+        `api/workspace-templates/${templateId}/use`,
+        { method: 'POST', body: options }
+      )
+      const finished = await waitForJob(started)
+      await openWorkspace(finished)
+      return finished
+    } finally {
+      job.value = null
+    }
+  }
+
+  return {
+    job,
+    progress,
+    cloneWorkspace,
+    createFromTemplate
+  }
+}
@@ -7,6 +7,26 @@ import {
 export const useWorkspaceNavigation = () => {
   const { workspaces, fetchWorkspaces } = useWorkspaces()
   const { getLastUsedWorkspace } = useWorkspacePreferences()
+  // Slug of the workspace whose custom domain serves the app, null when on the main domain
+  const customDomainSlug = useState<string | null | undefined>(
+    'customDomainSlug',
+    () => undefined
+  )
+
+  const resolveCustomDomain = async () => {
+    if (customDomainSlug.value !== undefined) {
+      return customDomainSlug.value
+    }
+
+    const { $api } = useNuxtApp()
+    try {
+      const result = await $api<{ workspace_slug: string }>('api/domains/current')
+      customDomainSlug.value = result.workspace_slug
+    } catch {
+      customDomainSlug.value = null
+    }
+    return customDomainSlug.value
+  }
 
   const ensureWorkspacesLoaded = async () => {
     if (workspaces.value.length === 0) {
@@ -25,6 +45,12 @@ export const useWorkspaceNavigation = () => {
       return navigateTo('/new-workspace')
     }
 
+    // On a custom domain, open the workspace it belongs to
+    const domainSlug = await resolveCustomDomain()
+    if (domainSlug && findWorkspaceBySlug(workspaces.value, domainSlug)) {
+      return redirectToWorkspace(domainSlug)
+    }
+
     const defaultWorkspace = getDefaultWorkspace(
       workspaces.value,
       getLastUsedWorkspace()
@@ -44,6 +70,7 @@ export const useWorkspaceNavigation = () => {
     getDefaultWorkspace: () =>
       getDefaultWorkspace(workspaces.value, getLastUsedWorkspace()),
     redirectToWorkspace,
-    redirectToDefaultWorkspace
+    redirectToDefaultWorkspace,
+    resolveCustomDomain
   }
 }
@@ -1,5 +1,13 @@
 // Reserved slugs that cannot be used for workspace creation
+// Mirrors backend/constants/slugs.go, the backend enforces the list
 export const BLOCKED_SLUGS = [
+  // App routes
+  'new-workspace',
+  'register',
+  'login',
+  'logout',
+  'invite',
+
   // App/System reserved
   'admin',
   'super-admin',
@@ -33,6 +33,22 @@ export default defineNuxtRouteMiddleware(async to => {
     workspace = findWorkspaceBySlug(to.params.workspaceSlug as string)
   }
 
+  // The slug may be a previous slug of a renamed workspace, redirect to the current one
+  if (!workspace) {
+    const currentSlug = await resolveRenamedSlug(to.params.workspaceSlug as string)
+    if (currentSlug) {
+      const oldPrefix = `/${to.params.workspaceSlug}`
+      return navigateTo(
+        {
+          path: `/${currentSlug}${to.path.slice(oldPrefix.length)}`,
+          query: to.query,
+          hash: to.hash
+        },
+        { replace: true }
+      )
+    }
+  }
+
   if (!workspace) {
     throw createError({
       statusCode: 404,
@@ -40,3 +56,16 @@ export default defineNuxtRouteMiddleware(async to => {
     })
   }
 })
+
+const resolveRenamedSlug = async (slug: string): Promise<string | null> => {
+  const { $api } = useNuxtApp()
+  try {
+    const result = await $api<{ slug: string; renamed: boolean }>(
+      'api/workspaces/resolve',
+      { query: { slug } }
+    )
+    return result.renamed ? result.slug : null
+  } catch {
+    return null
+  }
+}